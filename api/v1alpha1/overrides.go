@@ -0,0 +1,99 @@
+package v1alpha1
+
+// ForDest returns the sync config as seen by the destination, with the
+// syncDelete, dryRun, suspend, filters, transforms and propagateMetadata of
+// the destination merged over those of the spec. The sync config is not
+// modified.
+func (s VaultSecretSync) ForDest(d *StoreConfig) VaultSecretSync {
+	if d == nil {
+		return s
+	}
+	if d.SyncDelete != nil {
+		s.Spec.SyncDelete = d.SyncDelete
+	}
+	if d.DryRun != nil {
+		s.Spec.DryRun = d.DryRun
+	}
+	if d.Suspend != nil {
+		s.Spec.Suspend = d.Suspend
+	}
+	if d.PropagateMetadata != nil {
+		s.Spec.PropagateMetadata = d.PropagateMetadata
+	}
+	if d.Filters != nil {
+		f := FilterConfig{}
+		if s.Spec.Filters != nil {
+			f = *s.Spec.Filters
+		}
+		if d.Filters.Regex != nil {
+			f.Regex = d.Filters.Regex
+		}
+		if d.Filters.Path != nil {
+			f.Path = d.Filters.Path
+		}
+		s.Spec.Filters = &f
+	}
+	if d.Transforms != nil {
+		t := TransformSpec{}
+		if s.Spec.Transforms != nil {
+			t = *s.Spec.Transforms
+		}
+		if d.Transforms.Include != nil {
+			t.Include = d.Transforms.Include
+		}
+		if d.Transforms.Exclude != nil {
+			t.Exclude = d.Transforms.Exclude
+		}
+		if d.Transforms.Rename != nil {
+			t.Rename = d.Transforms.Rename
+		}
+		if d.Transforms.Template != nil {
+			t.Template = d.Transforms.Template
+		}
+		s.Spec.Transforms = &t
+	}
+	return s
+}
+
+// anyDest returns true if f is true for the sync config as seen by any
+// destination, or by the spec if there are no destinations
+func (s VaultSecretSync) anyDest(f func(VaultSecretSyncSpec) bool) bool {
+	if len(s.Spec.Dest) == 0 {
+		return f(s.Spec)
+	}
+	for _, d := range s.Spec.Dest {
+		if f(s.ForDest(d).Spec) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// Suspended returns true if every destination is suspended
+func (s VaultSecretSync) Suspended() bool {
+	return !s.anyDest(func(spec VaultSecretSyncSpec) bool { return !isTrue(spec.Suspend) })
+}
+
+// DryRun returns true if every destination is a dry run
+func (s VaultSecretSync) DryRun() bool {
+	return !s.anyDest(func(spec VaultSecretSyncSpec) bool { return !isTrue(spec.DryRun) })
+}
+
+// PartialDryRun returns true if any destination is a dry run
+func (s VaultSecretSync) PartialDryRun() bool {
+	return s.anyDest(func(spec VaultSecretSyncSpec) bool { return isTrue(spec.DryRun) })
+}
+
+// PartialSuspend returns true if any destination is suspended
+func (s VaultSecretSync) PartialSuspend() bool {
+	return s.anyDest(func(spec VaultSecretSyncSpec) bool { return isTrue(spec.Suspend) })
+}
+
+// SyncsDeletes returns true if deletes are synced to any destination
+func (s VaultSecretSync) SyncsDeletes() bool {
+	return s.anyDest(func(spec VaultSecretSyncSpec) bool { return spec.SyncDelete == nil || *spec.SyncDelete })
+}
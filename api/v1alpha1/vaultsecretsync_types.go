@@ -17,8 +17,11 @@ import (
 // +kubebuilder:subresource:status
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:path=vaultsecretsyncs,scope=Namespaced,shortName=vss
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`,description="Whether the VaultSecretSync is synced"
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`,description="Current status of the VaultSecretSync"
 // +kubebuilder:printcolumn:name="SyncDestinations",type=integer,JSONPath=`.status.syncDestinations`,description="Number of destinations synced"
+// +kubebuilder:printcolumn:name="FailedDestinations",type=integer,JSONPath=`.status.failedDestinations`,priority=1,description="Number of destination secrets which failed to sync"
+// +kubebuilder:printcolumn:name="LastSync",type=date,JSONPath=`.status.lastSyncTime`,priority=1,description="Time of the last sync"
 
 // VaultSecretSync is the Schema for the vaultsecretsyncs API
 type VaultSecretSync struct {
@@ -42,6 +45,35 @@ type StoreConfig struct {
 	GitHub *github.GitHubClient  `json:"github,omitempty" yaml:"github,omitempty"`
 	Vault  *vault.VaultClient    `json:"vault,omitempty" yaml:"vault,omitempty"`
 	HTTP   *httpstore.HTTPClient `json:"http,omitempty" yaml:"http,omitempty"`
+	// SplitKeys writes each key of the source secret as its own destination
+	// secret holding the raw value of the key. Supported by aws and gcp.
+	SplitKeys bool `json:"splitKeys,omitempty" yaml:"splitKeys,omitempty"`
+	// KeyName is the Go template of the name of each key's secret, with the
+	// destination path as .Path and the key as .Key. Defaults to "{{.Path}}-{{.Key}}".
+	KeyName string `json:"keyName,omitempty" yaml:"keyName,omitempty"`
+	// SyncDelete, DryRun, Suspend, Filters and Transforms override those
+	// of the spec for this destination. Filters and transforms are merged
+	// over the spec by field, e.g. a destination which only sets rename
+	// keeps the include and exclude of the spec.
+	SyncDelete *bool          `yaml:"syncDelete,omitempty" json:"syncDelete,omitempty"`
+	DryRun     *bool          `yaml:"dryRun,omitempty" json:"dryRun,omitempty"`
+	Suspend    *bool          `yaml:"suspend,omitempty" json:"suspend,omitempty"`
+	Filters    *FilterConfig  `yaml:"filters,omitempty" json:"filters,omitempty"`
+	Transforms *TransformSpec `json:"transforms,omitempty"`
+	// PropagateMetadata replaces that of the spec for this destination
+	PropagateMetadata *MetadataConfig `yaml:"propagateMetadata,omitempty" json:"propagateMetadata,omitempty"`
+}
+
+// MetadataConfig selects the KV v2 custom_metadata of the source secrets
+// which is propagated to the destination secrets. Keys are mapped to the
+// charset of each store.
+type MetadataConfig struct {
+	// Include lists the metadata keys to propagate. All keys are propagated if empty.
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"`
+	// Exclude lists the metadata keys not to propagate
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+	// Prefix is prepended to the propagated keys, e.g. "vault-"
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 }
 
 type RegexpFilterConfig struct {
@@ -116,6 +148,235 @@ type NotificationSpec struct {
 	Slack   *SlackNotification   `json:"slack,omitempty"`
 }
 
+// DriftPolicy determines how drift detected in destinations is handled
+type DriftPolicy string
+
+const (
+	// DriftPolicyReport reports drifted keys without modifying the destination
+	DriftPolicyReport DriftPolicy = "report"
+	// DriftPolicyHeal reports drifted keys and overwrites the destination with the source
+	DriftPolicyHeal DriftPolicy = "heal"
+)
+
+// ConflictPolicy determines how existing destination secrets not owned by
+// the VaultSecretSync are handled
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyAdopt takes ownership of existing secrets without an owner,
+	// and refuses to write secrets owned by another VaultSecretSync
+	ConflictPolicyAdopt ConflictPolicy = "adopt"
+	// ConflictPolicyRefuse refuses to write any existing secret not owned by the VaultSecretSync
+	ConflictPolicyRefuse ConflictPolicy = "refuse"
+	// ConflictPolicyOverwrite writes existing secrets regardless of their owner
+	ConflictPolicyOverwrite ConflictPolicy = "overwrite"
+)
+
+// BidirectionalConflictPolicy determines which side of a bidirectional sync
+// wins when both sides of a secret changed since the last sync
+type BidirectionalConflictPolicy string
+
+const (
+	// BidirectionalConflictLastWriterWins syncs the side updated last
+	BidirectionalConflictLastWriterWins BidirectionalConflictPolicy = "lastWriterWins"
+	// BidirectionalConflictSource syncs the source side
+	BidirectionalConflictSource BidirectionalConflictPolicy = "source"
+	// BidirectionalConflictDest syncs the destination side
+	BidirectionalConflictDest BidirectionalConflictPolicy = "dest"
+	// BidirectionalConflictHalt fails the sync of the secret until the
+	// conflict is resolved, e.g. with a force-sync from the source
+	BidirectionalConflictHalt BidirectionalConflictPolicy = "halt"
+)
+
+// BidirectionalConfig pairs the vault source with its vault destination, so
+// that changes to either side are synced to the other
+type BidirectionalConfig struct {
+	// ConflictPolicy determines which side wins when both sides of a secret
+	// changed since the last sync. Defaults to "lastWriterWins".
+	// +kubebuilder:validation:Enum=lastWriterWins;source;dest;halt
+	ConflictPolicy BidirectionalConflictPolicy `yaml:"conflictPolicy,omitempty" json:"conflictPolicy,omitempty"`
+}
+
+// RotationGenerator generates the new value of a rotated key
+type RotationGenerator string
+
+const (
+	// RotationGeneratorPassword generates a random password
+	RotationGeneratorPassword RotationGenerator = "password"
+	// RotationGeneratorHTTP requests the new value, such as an API key, from an HTTP endpoint
+	RotationGeneratorHTTP RotationGenerator = "http"
+	// RotationGeneratorRSA generates an RSA keypair
+	RotationGeneratorRSA RotationGenerator = "rsa"
+	// RotationGeneratorEd25519 generates an Ed25519 keypair
+	RotationGeneratorEd25519 RotationGenerator = "ed25519"
+)
+
+// RotationHTTP is an HTTP request made by a rotation
+type RotationHTTP struct {
+	URL string `yaml:"url" json:"url"`
+	// Method defaults to POST
+	Method  string            `yaml:"method,omitempty" json:"method,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// HeaderSecret is the name of a kubernetes secret, in the namespace of
+	// the VaultSecretSync or as namespace/name, whose keys are added as headers
+	HeaderSecret string `yaml:"headerSecret,omitempty" json:"headerSecret,omitempty"`
+	Body         string `yaml:"body,omitempty" json:"body,omitempty"`
+	// Field is the dot separated field of the JSON response holding the
+	// generated value, e.g. "data.key". Defaults to the whole response body.
+	Field string `yaml:"field,omitempty" json:"field,omitempty"`
+	// Timeout of the request. Defaults to 30s.
+	Timeout *metav1.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// RotationKey is a key of the source secret regenerated on each rotation
+type RotationKey struct {
+	// Key is the key of the source secret. Keypairs write the PEM encoded
+	// private key to it.
+	Key string `yaml:"key" json:"key"`
+	// +kubebuilder:validation:Enum=password;http;rsa;ed25519
+	Generator RotationGenerator `yaml:"generator" json:"generator"`
+	// Length is the length of a password. Defaults to 32.
+	Length int `yaml:"length,omitempty" json:"length,omitempty"`
+	// Charset are the characters of a password. Defaults to letters and digits.
+	Charset string `yaml:"charset,omitempty" json:"charset,omitempty"`
+	// Bits is the size of an RSA key. Defaults to 4096.
+	Bits int `yaml:"bits,omitempty" json:"bits,omitempty"`
+	// PublicKey is the key the PEM encoded public key of a keypair is
+	// written to. Defaults to <key>_public.
+	PublicKey string `yaml:"publicKey,omitempty" json:"publicKey,omitempty"`
+	// HTTP is the request of the http generator
+	HTTP *RotationHTTP `yaml:"http,omitempty" json:"http,omitempty"`
+}
+
+// RotationConfig regenerates keys of the source secret on a schedule. The
+// rotated secret is written to Vault as a new version and synced to every
+// destination.
+type RotationConfig struct {
+	// Schedule is a cron expression of when to rotate, e.g. "0 3 * * sun"
+	Schedule string `yaml:"schedule" json:"schedule"`
+	// TimeZone is the IANA time zone of the schedule, e.g. "Europe/London". Defaults to UTC.
+	TimeZone string        `yaml:"timeZone,omitempty" json:"timeZone,omitempty"`
+	Keys     []RotationKey `yaml:"keys" json:"keys"`
+	// Overlap keeps the previous value of each rotated key as
+	// <key>_previous for the duration after a rotation, e.g. "24h", so that
+	// consumers can accept both values while they move to the new one
+	Overlap *metav1.Duration `yaml:"overlap,omitempty" json:"overlap,omitempty"`
+	// Verify is requested once the rotated secret is synced to every
+	// destination. If the request fails, the previous secret is written
+	// to Vault again and synced.
+	Verify *RotationHTTP `yaml:"verify,omitempty" json:"verify,omitempty"`
+}
+
+// RotationStatus is the observed state of the rotation of the source secret
+type RotationStatus struct {
+	// LastRotationTime is when the keys were last rotated
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// LastAttemptTime is when a rotation was last attempted. The next
+	// rotation is scheduled after it.
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+	// LastError is the error of the last rotation attempt, if it failed
+	LastError string `json:"lastError,omitempty"`
+	// RolledBack is true if the last rotation attempt failed and the
+	// previous secret was restored
+	RolledBack bool `json:"rolledBack,omitempty"`
+	// OverlapUntil is when the previous values of the rotated keys are removed
+	OverlapUntil *metav1.Time `json:"overlapUntil,omitempty"`
+}
+
+// Condition types of a VaultSecretSync
+const (
+	// ConditionReady is true when the last sync succeeded and no destination is failed or drifted
+	ConditionReady = "Ready"
+	// ConditionSynced is true when the last sync succeeded
+	ConditionSynced = "Synced"
+	// ConditionDegraded is true when the last sync failed, destinations failed, or drift was found
+	ConditionDegraded = "Degraded"
+	// ConditionSuspended is true when the VaultSecretSync is suspended
+	ConditionSuspended = "Suspended"
+	// ConditionDryRun is true when the VaultSecretSync is a dry run
+	ConditionDryRun = "DryRun"
+)
+
+// Condition reasons of a VaultSecretSync
+const (
+	ConditionReasonSynced             = "Synced"
+	ConditionReasonSyncFailed         = "SyncFailed"
+	ConditionReasonRetrying           = "Retrying"
+	ConditionReasonTimeout            = "Timeout"
+	ConditionReasonDrifted            = "Drifted"
+	ConditionReasonDestinationsFailed = "DestinationsFailed"
+	ConditionReasonSuspended          = "Suspended"
+	ConditionReasonDryRun             = "DryRun"
+	ConditionReasonEnabled            = "Enabled"
+	ConditionReasonDeletesHalted      = "DeletesHalted"
+	ConditionReasonOutsideSyncWindow  = "OutsideSyncWindow"
+)
+
+// RetryPolicy configures retries of failed syncs
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts to sync an event,
+	// including the first attempt. 1 disables retries.
+	// +kubebuilder:validation:Minimum=1
+	MaxAttempts *int `yaml:"maxAttempts,omitempty" json:"maxAttempts,omitempty"`
+	// InitialBackoff is the delay before the first retry, doubled on each
+	// subsequent retry and jittered
+	InitialBackoff *metav1.Duration `yaml:"initialBackoff,omitempty" json:"initialBackoff,omitempty"`
+	// MaxBackoff is the maximum delay between retries
+	MaxBackoff *metav1.Duration `yaml:"maxBackoff,omitempty" json:"maxBackoff,omitempty"`
+}
+
+// SyncWindowKind determines whether syncs are allowed or denied during a sync window
+type SyncWindowKind string
+
+const (
+	// SyncWindowAllow allows syncs during the window. If any allow windows
+	// are configured, syncs are held outside of them.
+	SyncWindowAllow SyncWindowKind = "allow"
+	// SyncWindowDeny holds syncs during the window, even within an allow window
+	SyncWindowDeny SyncWindowKind = "deny"
+)
+
+// SyncWindow is a recurring window in which syncs are allowed or denied
+type SyncWindow struct {
+	// +kubebuilder:validation:Enum=allow;deny
+	Kind SyncWindowKind `yaml:"kind" json:"kind"`
+	// Schedule is a cron expression of the start of the window, e.g. "0 22 * * mon-fri"
+	Schedule string `yaml:"schedule" json:"schedule"`
+	// Duration is the length of the window, e.g. "2h"
+	Duration metav1.Duration `yaml:"duration" json:"duration"`
+	// TimeZone is the IANA time zone of the schedule, e.g. "Europe/London". Defaults to UTC.
+	TimeZone string `yaml:"timeZone,omitempty" json:"timeZone,omitempty"`
+}
+
+// HeldEvent is a sync event held until a sync window opens
+type HeldEvent struct {
+	Operation string `json:"operation"`
+	Path      string `json:"path,omitempty"`
+	Manual    bool   `json:"manual,omitempty"`
+	// Force writes destinations even if unchanged
+	Force bool `json:"force,omitempty"`
+	// DriftCheck checks destinations for drift
+	DriftCheck bool `json:"driftCheck,omitempty"`
+	// PendingDeletes only runs the due pending deletes
+	PendingDeletes bool `json:"pendingDeletes,omitempty"`
+	// SourceVersion is the KV v2 version of the source secrets to sync
+	SourceVersion int `json:"sourceVersion,omitempty"`
+	// Time is when the event was first held
+	Time metav1.Time `json:"time"`
+}
+
+// DeadLetter is a sync event which failed permanently or exhausted its retries
+type DeadLetter struct {
+	Operation string      `json:"operation"`
+	Path      string      `json:"path,omitempty"`
+	Manual    bool        `json:"manual,omitempty"`
+	Attempts  int         `json:"attempts"`
+	Error     string      `json:"error,omitempty"`
+	Time      metav1.Time `json:"time"`
+	// SourceVersion is the KV v2 version of the source secrets to sync
+	SourceVersion int `json:"sourceVersion,omitempty"`
+}
+
 // +kubebuilder:object:generate=true
 
 // VaultSecretSyncSpec defines the desired state of VaultSecretSync
@@ -129,6 +390,138 @@ type VaultSecretSyncSpec struct {
 	Transforms            *TransformSpec      `json:"transforms,omitempty"`
 	Notifications         []*NotificationSpec `json:"notifications,omitempty"`
 	NotificationsTemplate *string             `json:"notificationsTemplate,omitempty"`
+	// ResyncInterval is the interval at which a full sync is performed
+	// regardless of audit events, e.g. "1h". Defaults to the operator's
+	// resyncInterval. "0s" disables periodic resyncs.
+	ResyncInterval *metav1.Duration `yaml:"resyncInterval,omitempty" json:"resyncInterval,omitempty"`
+	// DriftPolicy enables drift detection on each periodic resync. Destinations
+	// which support reading secrets are compared with the source, and drifted
+	// keys are either reported or healed.
+	// +kubebuilder:validation:Enum=report;heal
+	DriftPolicy DriftPolicy `yaml:"driftPolicy,omitempty" json:"driftPolicy,omitempty"`
+	// Retry configures retries of failed syncs. Defaults to the operator's retry policy.
+	Retry *RetryPolicy `yaml:"retry,omitempty" json:"retry,omitempty"`
+	// Prune deletes destination secrets previously written by this sync which
+	// are no longer desired, e.g. when a regex source path no longer matches,
+	// a destination is removed, or a path rewrite changes. Orphaned secrets are
+	// only reported when prune is not set or dryRun is set.
+	Prune *bool `yaml:"prune,omitempty" json:"prune,omitempty"`
+	// ConflictPolicy determines how existing destination secrets which are not
+	// owned by this sync are handled. Destination secrets are stamped with
+	// their owner when written. Defaults to "adopt".
+	// +kubebuilder:validation:Enum=adopt;refuse;overwrite
+	ConflictPolicy ConflictPolicy `yaml:"conflictPolicy,omitempty" json:"conflictPolicy,omitempty"`
+	// Timeout is the maximum duration of a sync, e.g. "10m". Defaults to the
+	// operator's syncTimeout. "0s" disables the timeout.
+	Timeout *metav1.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// MaxDeletesPerSync is the maximum number of destination secrets a single
+	// sync may delete, including prunes. A sync which would delete more is
+	// halted and alerted instead of deleting anything. Defaults to the
+	// operator's maxDeletesPerSync. 0 disables the limit.
+	// +kubebuilder:validation:Minimum=0
+	MaxDeletesPerSync *int `yaml:"maxDeletesPerSync,omitempty" json:"maxDeletesPerSync,omitempty"`
+	// DeletionGracePeriod delays deletes of destination secrets, e.g. "24h".
+	// Deletes are listed in status as pending until the grace period passes,
+	// and are cancelled if the secret is written again or the VaultSecretSync
+	// is annotated with cancel-pending-deletes. Defaults to the operator's
+	// deletionGracePeriod. "0s" deletes immediately.
+	DeletionGracePeriod *metav1.Duration `yaml:"deletionGracePeriod,omitempty" json:"deletionGracePeriod,omitempty"`
+	// SyncWindows restrict when changes are applied to destinations. Changes
+	// outside the windows are held, and applied with the latest source state
+	// once a window opens. Annotate the VaultSecretSync with
+	// sync-window-override to apply held changes immediately.
+	SyncWindows []SyncWindow `yaml:"syncWindows,omitempty" json:"syncWindows,omitempty"`
+	// PropagateMetadata propagates the KV v2 custom_metadata of the source
+	// secrets to the destination secrets, as aws tags, gcp labels and
+	// annotations, vault custom_metadata and http headers
+	PropagateMetadata *MetadataConfig `yaml:"propagateMetadata,omitempty" json:"propagateMetadata,omitempty"`
+	// Bidirectional syncs changes to the destination back to the source. It
+	// requires a vault source and a single vault destination.
+	Bidirectional *BidirectionalConfig `yaml:"bidirectional,omitempty" json:"bidirectional,omitempty"`
+	// Rotation regenerates keys of the source secret on a schedule. It
+	// requires an exact source path. Annotate the VaultSecretSync with
+	// rotate-now to rotate immediately.
+	Rotation *RotationConfig `yaml:"rotation,omitempty" json:"rotation,omitempty"`
+}
+
+// DestinationStatus is the observed state of a single destination secret
+type DestinationStatus struct {
+	Driver string `json:"driver"`
+	// Location identifies the destination store, such as the vault address or aws region
+	Location string `json:"location,omitempty"`
+	// Path is the resolved path of the destination secret
+	Path            string      `json:"path"`
+	LastAttemptTime metav1.Time `json:"lastAttemptTime,omitempty"`
+	LastSuccessTime metav1.Time `json:"lastSuccessTime,omitempty"`
+	LastError       string      `json:"lastError,omitempty"`
+	// Hash is a salted hash of the last payload written to the destination
+	Hash string `json:"hash,omitempty"`
+}
+
+// PlanAction is the change a dry run would make to a destination secret
+type PlanAction string
+
+const (
+	// PlanActionCreate creates a destination secret which does not exist
+	PlanActionCreate PlanAction = "create"
+	// PlanActionUpdate updates a destination secret which differs from the source
+	PlanActionUpdate PlanAction = "update"
+	// PlanActionDelete deletes a destination secret
+	PlanActionDelete PlanAction = "delete"
+	// PlanActionNoOp leaves a destination secret which matches the source unchanged
+	PlanActionNoOp PlanAction = "no-op"
+	// PlanActionWrite writes a destination secret which cannot be read back
+	// to compare with the source
+	PlanActionWrite PlanAction = "write"
+)
+
+// PlannedChange is the change a dry run would make to a single destination
+// secret. Only key names are included, never values.
+type PlannedChange struct {
+	Driver string `json:"driver"`
+	// Location identifies the destination store, such as the vault address or aws region
+	Location string     `json:"location,omitempty"`
+	Path     string     `json:"path"`
+	Action   PlanAction `json:"action"`
+	// Added are the keys which would be added to the destination secret
+	Added []string `json:"added,omitempty"`
+	// Removed are the keys which would be removed from the destination secret
+	Removed []string `json:"removed,omitempty"`
+	// Changed are the keys whose values would change
+	Changed []string `json:"changed,omitempty"`
+	// Error is set if the change could not be planned, or would fail, e.g.
+	// because the destination secret is owned by another sync
+	Error string `json:"error,omitempty"`
+}
+
+// PlanSummary counts the planned changes by action
+type PlanSummary struct {
+	Create int `json:"create,omitempty"`
+	Update int `json:"update,omitempty"`
+	Delete int `json:"delete,omitempty"`
+	NoOp   int `json:"noOp,omitempty"`
+	Write  int `json:"write,omitempty"`
+	Errors int `json:"errors,omitempty"`
+}
+
+// Plan is the set of changes the last dry run would have made
+type Plan struct {
+	Time    metav1.Time `json:"time"`
+	Summary PlanSummary `json:"summary"`
+	// Changes are the planned changes. When there are too many to list,
+	// changes are kept before no-ops, and Truncated is set.
+	Changes   []PlannedChange `json:"changes,omitempty"`
+	Truncated bool            `json:"truncated,omitempty"`
+}
+
+// PendingDelete is a delete of a destination secret waiting out the deletion grace period
+type PendingDelete struct {
+	Driver string `json:"driver"`
+	// Location identifies the destination store, such as the vault address or aws region
+	Location string `json:"location,omitempty"`
+	Path     string `json:"path"`
+	// DeleteAfter is the time after which the secret is deleted
+	DeleteAfter metav1.Time `json:"deleteAfter"`
 }
 
 // +kubebuilder:object:generate=true
@@ -139,6 +532,35 @@ type VaultSecretSyncStatus struct {
 	LastSyncTime     metav1.Time `json:"lastSyncTime,omitempty"`
 	SyncDestinations int         `json:"syncDestinations,omitempty"`
 	Hash             string      `json:"hash,omitempty"`
+	// DeadLetters are the most recent sync events which could not be synced.
+	// Annotate the VaultSecretSync with redrive-dead-letters to retry them.
+	DeadLetters []DeadLetter `json:"deadLetters,omitempty"`
+	// Destinations is the status of each destination secret. When there are
+	// too many to list, failed and most recently synced destinations are kept.
+	Destinations []DestinationStatus `json:"destinations,omitempty"`
+	// FailedDestinations is the number of destination secrets which failed to sync
+	FailedDestinations int `json:"failedDestinations,omitempty"`
+	// ObservedGeneration is the generation of the spec last synced
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Plan is the set of changes the last dry run would have made. It is
+	// cleared once the VaultSecretSync is no longer a dry run.
+	Plan *Plan `json:"plan,omitempty"`
+	// PendingDeletes are the deletes waiting out the deletion grace period.
+	// When there are too many to list, the earliest deletes are kept.
+	PendingDeletes []PendingDelete `json:"pendingDeletes,omitempty"`
+	// PendingDeleteCount is the number of pending deletes
+	PendingDeleteCount int `json:"pendingDeleteCount,omitempty"`
+	// HeldEvents are the sync events held until a sync window opens, one per path
+	HeldEvents []HeldEvent `json:"heldEvents,omitempty"`
+	// NextSyncWindow is when the held events will be applied
+	NextSyncWindow *metav1.Time `json:"nextSyncWindow,omitempty"`
+	// Rotation is the state of the rotation of the source secret
+	Rotation *RotationStatus `json:"rotation,omitempty"`
+	// Conditions are the Ready, Synced, Degraded, Suspended and DryRun
+	// conditions of the VaultSecretSync
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
 	"github.com/robertlestak/vault-secret-sync/internal/backend"
@@ -37,6 +38,12 @@ func init() {
 	// set the log format
 	//log.SetFormatter(&log.JSONFormatter{})
 	backend.ManualTrigger = sync.ManualTrigger
+	backend.ResyncTrigger = sync.ResyncTrigger
+	backend.RedriveTrigger = sync.RedriveTrigger
+	backend.VersionTrigger = sync.VersionTrigger
+	backend.PendingDeletesTrigger = sync.PendingDeletesTrigger
+	backend.ReleaseTrigger = sync.ReleaseTrigger
+	backend.RotationTrigger = sync.RotationTrigger
 }
 
 func initQueue() error {
@@ -74,12 +81,27 @@ func initQueue() error {
 	return nil
 }
 
-func cleanup() {
+// shutdown stops accepting events, drains the in-flight syncs and requeues
+// the events which were not processed, then closes the queue
+func shutdown() {
 	l := log.WithFields(log.Fields{
-		"action": "cleanup",
+		"action": "shutdown",
 	})
 	l.Trace("start")
 	defer l.Trace("end")
+	l.WithField("drainTimeout", sync.DefaultDrainTimeout).Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), sync.DefaultDrainTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		l.WithError(err).Error("failed to stop event server")
+	}
+	sync.Shutdown(ctx)
+	if queue.Q != nil {
+		if err := queue.Q.Stop(); err != nil {
+			l.WithError(err).Error("failed to stop queue")
+		}
+	}
+	l.Info("shutdown complete")
 }
 
 func main() {
@@ -158,6 +180,82 @@ func main() {
 	if strings.Contains(strings.Join(startServers, ","), "operator") {
 		config.Config.Operator.WorkerPoolSize = cmp.Or(config.Config.Operator.WorkerPoolSize, 10)
 		config.Config.Operator.NumSubscriptions = cmp.Or(config.Config.Operator.NumSubscriptions, 10)
+		if config.Config.Operator.ResyncInterval != "" {
+			ri, err := time.ParseDuration(config.Config.Operator.ResyncInterval)
+			if err != nil {
+				l.Fatalf("invalid operator resyncInterval: %v", err)
+			}
+			backend.DefaultResyncInterval = ri
+		}
+		if rc := config.Config.Operator.Retry; rc != nil {
+			if rc.MaxAttempts > 0 {
+				sync.DefaultRetryMaxAttempts = rc.MaxAttempts
+			}
+			if rc.InitialBackoff != "" {
+				d, err := time.ParseDuration(rc.InitialBackoff)
+				if err != nil {
+					l.Fatalf("invalid operator retry initialBackoff: %v", err)
+				}
+				sync.DefaultRetryInitialBackoff = d
+			}
+			if rc.MaxBackoff != "" {
+				d, err := time.ParseDuration(rc.MaxBackoff)
+				if err != nil {
+					l.Fatalf("invalid operator retry maxBackoff: %v", err)
+				}
+				sync.DefaultRetryMaxBackoff = d
+			}
+		}
+		if config.Config.Operator.SyncTimeout != "" {
+			d, err := time.ParseDuration(config.Config.Operator.SyncTimeout)
+			if err != nil {
+				l.Fatalf("invalid operator syncTimeout: %v", err)
+			}
+			sync.DefaultSyncTimeout = d
+		}
+		if config.Config.Operator.CallTimeout != "" {
+			d, err := time.ParseDuration(config.Config.Operator.CallTimeout)
+			if err != nil {
+				l.Fatalf("invalid operator callTimeout: %v", err)
+			}
+			sync.DefaultCallTimeout = d
+		}
+		if config.Config.Operator.DrainTimeout != "" {
+			d, err := time.ParseDuration(config.Config.Operator.DrainTimeout)
+			if err != nil {
+				l.Fatalf("invalid operator drainTimeout: %v", err)
+			}
+			sync.DefaultDrainTimeout = d
+		}
+		if config.Config.Operator.Debounce != "" {
+			d, err := time.ParseDuration(config.Config.Operator.Debounce)
+			if err != nil {
+				l.Fatalf("invalid operator debounce: %v", err)
+			}
+			sync.DefaultDebounce = d
+		}
+		if m := config.Config.Operator.MaxDeletesPerSync; m != nil {
+			if *m < 0 {
+				l.Fatalf("invalid operator maxDeletesPerSync: %d", *m)
+			}
+			sync.DefaultMaxDeletesPerSync = *m
+		}
+		if config.Config.Operator.DeletionGracePeriod != "" {
+			d, err := time.ParseDuration(config.Config.Operator.DeletionGracePeriod)
+			if err != nil {
+				l.Fatalf("invalid operator deletionGracePeriod: %v", err)
+			}
+			sync.DefaultDeletionGracePeriod = d
+		}
+		if cc := config.Config.Operator.Concurrency; cc != nil {
+			if cc.Workers > 0 {
+				sync.DefaultWorkers = cc.Workers
+			}
+			if cc.RegexWorkers > 0 {
+				sync.DefaultRegexWorkers = cc.RegexWorkers
+			}
+			driver.SetLimits(cc.Limit, cc.Drivers)
+		}
 		go sync.Operator(
 			ctx,
 			config.Config.Operator.Backend.Params,
@@ -177,7 +275,13 @@ func main() {
 	// wait for a signal to stop
 	select {
 	case <-sigChan:
-		cleanup()
+		// a second signal exits without waiting for the drain
+		go func() {
+			<-sigChan
+			l.Warn("forced exit")
+			os.Exit(1)
+		}()
+		shutdown()
 		cancel()
 	case <-ctx.Done():
 	}
@@ -11,8 +11,11 @@ import (
 )
 
 var (
-	B             Backend
-	ManualTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) error
+	B              Backend
+	ManualTrigger  func(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) error
+	ResyncTrigger  func(ctx context.Context, cfg v1alpha1.VaultSecretSync) error
+	RedriveTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync, dl v1alpha1.DeadLetter) error
+	VersionTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync, version int) error
 )
 
 const (
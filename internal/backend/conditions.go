@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// setSyncConditions sets the conditions of the sync config from the sync
+// status of its last sync of the given spec generation. Failures caused by
+// timeouts have their own reason.
+func setSyncConditions(s *v1alpha1.VaultSecretSync, status SyncStatusString, generation int64, timedOut bool) {
+	set := func(t string, cs metav1.ConditionStatus, reason, message string) {
+		meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+			Type:               t,
+			Status:             cs,
+			ObservedGeneration: generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+	s.Status.ObservedGeneration = generation
+
+	suspended := s.Suspended()
+	if suspended {
+		set(v1alpha1.ConditionSuspended, metav1.ConditionTrue, v1alpha1.ConditionReasonSuspended, "sync is suspended")
+	} else {
+		set(v1alpha1.ConditionSuspended, metav1.ConditionFalse, v1alpha1.ConditionReasonEnabled, "sync is enabled")
+	}
+	dryRun := s.DryRun()
+	if dryRun {
+		set(v1alpha1.ConditionDryRun, metav1.ConditionTrue, v1alpha1.ConditionReasonDryRun, "destinations are not written")
+	} else {
+		set(v1alpha1.ConditionDryRun, metav1.ConditionFalse, v1alpha1.ConditionReasonEnabled, "destinations are written")
+	}
+
+	switch status {
+	case SyncStatusSuccess:
+		set(v1alpha1.ConditionSynced, metav1.ConditionTrue, v1alpha1.ConditionReasonSynced, "last sync succeeded")
+		if n := s.Status.FailedDestinations; n > 0 {
+			msg := fmt.Sprintf("%d destinations failed to sync", n)
+			set(v1alpha1.ConditionDegraded, metav1.ConditionTrue, v1alpha1.ConditionReasonDestinationsFailed, msg)
+			set(v1alpha1.ConditionReady, metav1.ConditionFalse, v1alpha1.ConditionReasonDestinationsFailed, msg)
+			return
+		}
+		set(v1alpha1.ConditionDegraded, metav1.ConditionFalse, v1alpha1.ConditionReasonSynced, "all destinations synced")
+		set(v1alpha1.ConditionReady, metav1.ConditionTrue, v1alpha1.ConditionReasonSynced, "all destinations synced")
+	case SyncStatusDrifted:
+		msg := "destinations have drifted from the source"
+		set(v1alpha1.ConditionSynced, metav1.ConditionTrue, v1alpha1.ConditionReasonSynced, "last sync succeeded")
+		set(v1alpha1.ConditionDegraded, metav1.ConditionTrue, v1alpha1.ConditionReasonDrifted, msg)
+		set(v1alpha1.ConditionReady, metav1.ConditionFalse, v1alpha1.ConditionReasonDrifted, msg)
+	case SyncStatusRetrying:
+		reason, msg := v1alpha1.ConditionReasonRetrying, "last sync failed and will be retried"
+		if timedOut {
+			reason, msg = v1alpha1.ConditionReasonTimeout, "last sync timed out and will be retried"
+		}
+		set(v1alpha1.ConditionSynced, metav1.ConditionFalse, reason, msg)
+		set(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reason, msg)
+		set(v1alpha1.ConditionReady, metav1.ConditionFalse, reason, msg)
+	case SyncStatusFailed:
+		reason, msg := v1alpha1.ConditionReasonSyncFailed, "last sync failed, see events and status.deadLetters"
+		if timedOut {
+			reason, msg = v1alpha1.ConditionReasonTimeout, "last sync timed out, see events and status.deadLetters"
+		}
+		set(v1alpha1.ConditionSynced, metav1.ConditionFalse, reason, msg)
+		set(v1alpha1.ConditionDegraded, metav1.ConditionTrue, reason, msg)
+		set(v1alpha1.ConditionReady, metav1.ConditionFalse, reason, msg)
+	case SyncStatusSuspended:
+		set(v1alpha1.ConditionReady, metav1.ConditionFalse, v1alpha1.ConditionReasonSuspended, "sync is suspended")
+	case SyncStatusDryRun:
+		set(v1alpha1.ConditionReady, metav1.ConditionFalse, v1alpha1.ConditionReasonDryRun, "destinations are not written")
+	case SyncStatusHeld:
+		set(v1alpha1.ConditionReady, metav1.ConditionFalse, v1alpha1.ConditionReasonOutsideSyncWindow, "changes are held until the sync window opens, see status.heldEvents")
+	}
+}
@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetSyncConditions(t *testing.T) {
+	s := &v1alpha1.VaultSecretSync{}
+	setSyncConditions(s, SyncStatusSuccess, 2, false)
+	assert.Equal(t, int64(2), s.Status.ObservedGeneration)
+	assert.True(t, meta.IsStatusConditionTrue(s.Status.Conditions, v1alpha1.ConditionReady))
+	assert.True(t, meta.IsStatusConditionTrue(s.Status.Conditions, v1alpha1.ConditionSynced))
+	assert.True(t, meta.IsStatusConditionFalse(s.Status.Conditions, v1alpha1.ConditionDegraded))
+	assert.True(t, meta.IsStatusConditionFalse(s.Status.Conditions, v1alpha1.ConditionSuspended))
+	assert.True(t, meta.IsStatusConditionFalse(s.Status.Conditions, v1alpha1.ConditionDryRun))
+	ready := meta.FindStatusCondition(s.Status.Conditions, v1alpha1.ConditionReady)
+	assert.Equal(t, int64(2), ready.ObservedGeneration)
+
+	// a successful sync with failed destinations is degraded
+	s.Status.FailedDestinations = 1
+	setSyncConditions(s, SyncStatusSuccess, 3, false)
+	ready = meta.FindStatusCondition(s.Status.Conditions, v1alpha1.ConditionReady)
+	assert.Equal(t, metav1.ConditionFalse, ready.Status)
+	assert.Equal(t, v1alpha1.ConditionReasonDestinationsFailed, ready.Reason)
+	assert.True(t, meta.IsStatusConditionTrue(s.Status.Conditions, v1alpha1.ConditionSynced))
+
+	s.Status.FailedDestinations = 0
+	setSyncConditions(s, SyncStatusFailed, 3, false)
+	assert.True(t, meta.IsStatusConditionFalse(s.Status.Conditions, v1alpha1.ConditionReady))
+	assert.True(t, meta.IsStatusConditionTrue(s.Status.Conditions, v1alpha1.ConditionDegraded))
+	synced := meta.FindStatusCondition(s.Status.Conditions, v1alpha1.ConditionSynced)
+	assert.Equal(t, v1alpha1.ConditionReasonSyncFailed, synced.Reason)
+
+	// timeouts are reported with their own reason
+	setSyncConditions(s, SyncStatusRetrying, 3, true)
+	synced = meta.FindStatusCondition(s.Status.Conditions, v1alpha1.ConditionSynced)
+	assert.Equal(t, v1alpha1.ConditionReasonTimeout, synced.Reason)
+
+	suspend := true
+	s.Spec.Suspend = &suspend
+	setSyncConditions(s, SyncStatusSuspended, 4, false)
+	assert.True(t, meta.IsStatusConditionTrue(s.Status.Conditions, v1alpha1.ConditionSuspended))
+	ready = meta.FindStatusCondition(s.Status.Conditions, v1alpha1.ConditionReady)
+	assert.Equal(t, v1alpha1.ConditionReasonSuspended, ready.Reason)
+	assert.Len(t, s.Status.Conditions, 5)
+}
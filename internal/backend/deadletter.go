@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MaxDeadLetters is the number of dead letters kept in the status of a sync config
+	MaxDeadLetters = 20
+	// maxDeadLetterError is the maximum length of the error kept in a dead letter
+	maxDeadLetterError = 1024
+)
+
+// appendDeadLetter appends the dead letter, dropping the oldest dead letters
+// once there are more than MaxDeadLetters
+func appendDeadLetter(dls []v1alpha1.DeadLetter, dl v1alpha1.DeadLetter) []v1alpha1.DeadLetter {
+	if len(dl.Error) > maxDeadLetterError {
+		dl.Error = dl.Error[:maxDeadLetterError]
+	}
+	dls = append(dls, dl)
+	if len(dls) > MaxDeadLetters {
+		dls = dls[len(dls)-MaxDeadLetters:]
+	}
+	return dls
+}
+
+// AddDeadLetter records a sync event which could not be synced in the status of the sync config
+func AddDeadLetter(ctx context.Context, sc v1alpha1.VaultSecretSync, dl v1alpha1.DeadLetter) error {
+	if B == nil {
+		return nil
+	}
+	switch B.Type() {
+	case BackendTypeKubernetes:
+		return addDeadLetterKube(ctx, sc, dl)
+	default:
+		return nil
+	}
+}
+
+func addDeadLetterKube(ctx context.Context, sc v1alpha1.VaultSecretSync, dl v1alpha1.DeadLetter) error {
+	l := log.WithFields(log.Fields{
+		"action":    "addDeadLetterKube",
+		"namespace": sc.Namespace,
+		"name":      sc.Name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s := &v1alpha1.VaultSecretSync{}
+		if err := Reconciler.Get(ctx, client.ObjectKey{Namespace: sc.Namespace, Name: sc.Name}, s); err != nil {
+			return err
+		}
+		s.Status.DeadLetters = appendDeadLetter(s.Status.DeadLetters, dl)
+		return Reconciler.Status().Update(ctx, s, client.FieldOwner("vault-secret-sync-controller"))
+	})
+	if err != nil {
+		l.Errorf("failed to add dead letter: %v", err)
+		return err
+	}
+	return nil
+}
+
+// redriveDeadLetters triggers a sync of every dead letter of the sync config
+// and clears them from its status
+func redriveDeadLetters(ctx context.Context, r *VaultSecretSyncReconciler, vaultSecretSync *v1alpha1.VaultSecretSync) error {
+	l := log.WithFields(log.Fields{
+		"action":    "redriveDeadLetters",
+		"namespace": vaultSecretSync.Namespace,
+		"name":      vaultSecretSync.Name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	dls := vaultSecretSync.Status.DeadLetters
+	for _, dl := range dls {
+		if err := RedriveTrigger(ctx, *vaultSecretSync, dl); err != nil {
+			return err
+		}
+	}
+	l.WithField("deadLetters", len(dls)).Debug("dead letters redriven")
+	vaultSecretSync.Status.DeadLetters = nil
+	return r.Status().Update(ctx, vaultSecretSync, client.FieldOwner("vault-secret-sync-controller"))
+}
@@ -0,0 +1,23 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendDeadLetter(t *testing.T) {
+	var dls []v1alpha1.DeadLetter
+	for i := 0; i < MaxDeadLetters+5; i++ {
+		dls = appendDeadLetter(dls, v1alpha1.DeadLetter{Path: fmt.Sprintf("kv/%d", i)})
+	}
+	assert.Len(t, dls, MaxDeadLetters)
+	assert.Equal(t, "kv/5", dls[0].Path)
+	assert.Equal(t, fmt.Sprintf("kv/%d", MaxDeadLetters+4), dls[len(dls)-1].Path)
+
+	dls = appendDeadLetter(nil, v1alpha1.DeadLetter{Error: strings.Repeat("x", 2*maxDeadLetterError)})
+	assert.Len(t, dls[0].Error, maxDeadLetterError)
+}
@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// MaxPendingDeletes is the number of pending deletes listed in the status
+	// of a sync config
+	MaxPendingDeletes = 100
+	// DeleteOnRemovalFinalizer holds a sync config annotated with
+	// delete-on-removal until its destination secrets are deleted
+	DeleteOnRemovalFinalizer = "vaultsecretsync.lestak.sh/delete-on-removal"
+	// pendingDeletesRetryInterval is the minimum interval between triggers of
+	// the pending deletes of a sync config, so that deletes which keep
+	// failing are not retried on every reconcile
+	pendingDeletesRetryInterval = time.Minute
+)
+
+var (
+	// PendingDeletesTrigger triggers the due pending deletes of the sync config
+	PendingDeletesTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync) error
+
+	pendingDeletesTriggered   = make(map[string]time.Time)
+	pendingDeletesTriggeredMu sync.Mutex
+
+	// removals are the sync configs whose delete-on-removal was triggered
+	removals   = make(map[types.UID]bool)
+	removalsMu sync.Mutex
+)
+
+// pendingDeleteStatus returns the status of the pending deletes, listing at
+// most MaxPendingDeletes of the earliest deletes, and the number of pending deletes
+func pendingDeleteStatus(tombstones []Tombstone) ([]v1alpha1.PendingDelete, int) {
+	var pending []v1alpha1.PendingDelete
+	for i, t := range tombstones {
+		if i == MaxPendingDeletes {
+			break
+		}
+		pending = append(pending, v1alpha1.PendingDelete{
+			Driver:      t.Driver,
+			Location:    t.Location,
+			Path:        t.Path,
+			DeleteAfter: metav1.NewTime(t.DeleteAfter),
+		})
+	}
+	return pending, len(tombstones)
+}
+
+// schedulePendingDeletes determines whether pending deletes of the sync
+// config are due at now, and how long until they should be checked again
+func schedulePendingDeletes(s v1alpha1.VaultSecretSync, now time.Time) (bool, time.Duration) {
+	name := InternalName(s.Namespace, s.Name)
+	pendingDeletesTriggeredMu.Lock()
+	defer pendingDeletesTriggeredMu.Unlock()
+	if len(s.Status.PendingDeletes) == 0 {
+		delete(pendingDeletesTriggered, name)
+		return false, 0
+	}
+	// pending deletes are listed earliest first
+	next := s.Status.PendingDeletes[0].DeleteAfter.Time
+	if now.Before(next) {
+		return false, next.Sub(now)
+	}
+	if last, ok := pendingDeletesTriggered[name]; ok && now.Sub(last) < pendingDeletesRetryInterval {
+		return false, pendingDeletesRetryInterval - now.Sub(last)
+	}
+	pendingDeletesTriggered[name] = now
+	return true, pendingDeletesRetryInterval
+}
+
+// clearPendingDeletes removes the pending deletes schedule for the named sync config
+func clearPendingDeletes(name string) {
+	pendingDeletesTriggeredMu.Lock()
+	defer pendingDeletesTriggeredMu.Unlock()
+	delete(pendingDeletesTriggered, name)
+}
+
+// triggerRemoval returns true the first time it is called for the sync config
+func triggerRemoval(uid types.UID) bool {
+	removalsMu.Lock()
+	defer removalsMu.Unlock()
+	if removals[uid] {
+		return false
+	}
+	removals[uid] = true
+	return true
+}
+
+func forgetRemoval(uid types.UID) {
+	removalsMu.Lock()
+	defer removalsMu.Unlock()
+	delete(removals, uid)
+}
+
+// Removing returns true if the sync config is being deleted and waits for
+// its destination secrets to be deleted
+func Removing(s v1alpha1.VaultSecretSync) bool {
+	return !s.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(&s, DeleteOnRemovalFinalizer)
+}
+
+// FinishRemoval releases a sync config being deleted once its destination
+// secrets have been deleted
+func FinishRemoval(ctx context.Context, s v1alpha1.VaultSecretSync) error {
+	l := log.WithFields(log.Fields{
+		"action":    "FinishRemoval",
+		"namespace": s.Namespace,
+		"name":      s.Name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	if Reconciler == nil {
+		return nil
+	}
+	return removeFinalizer(ctx, Reconciler, client.ObjectKey{Namespace: s.Namespace, Name: s.Name})
+}
+
+// removeFinalizer removes the delete-on-removal finalizer of the sync config
+func removeFinalizer(ctx context.Context, r *VaultSecretSyncReconciler, key client.ObjectKey) error {
+	l := log.WithFields(log.Fields{
+		"action":    "removeFinalizer",
+		"namespace": key.Namespace,
+		"name":      key.Name,
+	})
+	s := &v1alpha1.VaultSecretSync{}
+	if err := r.Get(ctx, key, s); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !controllerutil.RemoveFinalizer(s, DeleteOnRemovalFinalizer) {
+		return nil
+	}
+	if err := r.Update(ctx, s, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+		l.Errorf("failed to remove finalizer: %v", err)
+		return err
+	}
+	forgetRemoval(s.UID)
+	l.Debug("finalizer removed")
+	return nil
+}
+
+// cancelPendingDeletes cancels every pending delete of the sync config
+func cancelPendingDeletes(ctx context.Context, r *VaultSecretSyncReconciler, s *v1alpha1.VaultSecretSync) (int, error) {
+	inv, err := GetInventory(ctx, *s)
+	if err != nil {
+		return 0, err
+	}
+	n := inv.ClearTombstones()
+	if err := inv.Save(ctx); err != nil {
+		return 0, err
+	}
+	s.Status.PendingDeletes = nil
+	s.Status.PendingDeleteCount = 0
+	if err := r.Status().Update(ctx, s, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+		return 0, err
+	}
+	clearPendingDeletes(InternalName(s.Namespace, s.Name))
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPendingDeleteStatus(t *testing.T) {
+	now := time.Now()
+	var tombstones []Tombstone
+	for i := 0; i < MaxPendingDeletes+5; i++ {
+		tombstones = append(tombstones, Tombstone{
+			InventoryRecord: InventoryRecord{Driver: "aws", Path: "app"},
+			DeleteAfter:     now.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	pending, count := pendingDeleteStatus(tombstones)
+	assert.Len(t, pending, MaxPendingDeletes)
+	assert.Equal(t, MaxPendingDeletes+5, count)
+	assert.True(t, pending[0].DeleteAfter.Time.Equal(now))
+
+	pending, count = pendingDeleteStatus([]Tombstone{})
+	assert.Empty(t, pending)
+	assert.Zero(t, count)
+}
+
+func TestSchedulePendingDeletes(t *testing.T) {
+	now := time.Now()
+	s := v1alpha1.VaultSecretSync{ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "pending"}}
+	defer clearPendingDeletes(InternalName(s.Namespace, s.Name))
+
+	due, after := schedulePendingDeletes(s, now)
+	assert.False(t, due)
+	assert.Zero(t, after)
+
+	s.Status.PendingDeletes = []v1alpha1.PendingDelete{{Driver: "aws", Path: "app", DeleteAfter: metav1.NewTime(now.Add(time.Hour))}}
+	due, after = schedulePendingDeletes(s, now)
+	assert.False(t, due)
+	assert.Equal(t, time.Hour, after)
+
+	// due deletes are triggered at most once per retry interval
+	due, after = schedulePendingDeletes(s, now.Add(time.Hour))
+	assert.True(t, due)
+	assert.Equal(t, pendingDeletesRetryInterval, after)
+	due, _ = schedulePendingDeletes(s, now.Add(time.Hour+time.Second))
+	assert.False(t, due)
+	due, _ = schedulePendingDeletes(s, now.Add(time.Hour+pendingDeletesRetryInterval))
+	assert.True(t, due)
+}
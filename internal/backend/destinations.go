@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"sort"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+)
+
+const (
+	// MaxDestinationStatuses is the number of destination secrets listed in
+	// the status of a sync config. Regex syncs can write many more secrets.
+	MaxDestinationStatuses = 100
+	// maxDestinationError is the maximum length of a destination error kept in status
+	maxDestinationError = 256
+)
+
+// DestinationResult is the outcome of syncing a single destination secret
+type DestinationResult struct {
+	Status v1alpha1.DestinationStatus
+	// Deleted is true if the destination secret was deleted
+	Deleted bool
+}
+
+// SyncResult is the outcome of a sync for each destination secret it touched
+type SyncResult struct {
+	// Complete is true if the sync covered every destination secret of the
+	// sync config, in which case destinations not in the result are removed
+	Complete bool
+	// TimedOut is true if the sync, or a store operation, exceeded its timeout
+	TimedOut     bool
+	Destinations []DestinationResult
+	// Plan is the plan of a dry run
+	Plan *v1alpha1.Plan
+	// PendingDeletes are the deletes waiting out the deletion grace period,
+	// earliest first. Nil leaves the pending deletes in status unchanged.
+	PendingDeletes []Tombstone
+}
+
+func destinationKey(d v1alpha1.DestinationStatus) string {
+	return InventoryKey(d.Driver, d.Location, d.Path)
+}
+
+// mergeDestinationStatus merges the sync result into the current destination
+// statuses, returning the new statuses and the number of failed destinations
+func mergeDestinationStatus(current []v1alpha1.DestinationStatus, result *SyncResult) ([]v1alpha1.DestinationStatus, int) {
+	byKey := make(map[string]v1alpha1.DestinationStatus)
+	if !result.Complete {
+		for _, d := range current {
+			byKey[destinationKey(d)] = d
+		}
+	}
+	for _, r := range result.Destinations {
+		k := destinationKey(r.Status)
+		if r.Deleted {
+			delete(byKey, k)
+			continue
+		}
+		d := r.Status
+		if len(d.LastError) > maxDestinationError {
+			d.LastError = d.LastError[:maxDestinationError]
+		}
+		if prev, ok := byKey[k]; ok && d.LastSuccessTime.IsZero() {
+			// a failed attempt keeps the last successful write
+			d.LastSuccessTime = prev.LastSuccessTime
+			d.Hash = prev.Hash
+		}
+		byKey[k] = d
+	}
+	dests := make([]v1alpha1.DestinationStatus, 0, len(byKey))
+	failed := 0
+	for _, d := range byKey {
+		if d.LastError != "" {
+			failed++
+		}
+		dests = append(dests, d)
+	}
+	if len(dests) > MaxDestinationStatuses {
+		// keep failed destinations, then the most recently attempted
+		sort.Slice(dests, func(i, j int) bool {
+			if (dests[i].LastError != "") != (dests[j].LastError != "") {
+				return dests[i].LastError != ""
+			}
+			return dests[i].LastAttemptTime.After(dests[j].LastAttemptTime.Time)
+		})
+		dests = dests[:MaxDestinationStatuses]
+	}
+	sort.Slice(dests, func(i, j int) bool {
+		return destinationKey(dests[i]) < destinationKey(dests[j])
+	})
+	if len(dests) == 0 {
+		dests = nil
+	}
+	return dests, failed
+}
@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMergeDestinationStatus(t *testing.T) {
+	success := metav1.NewTime(time.Now().Add(-time.Hour))
+	current := []v1alpha1.DestinationStatus{
+		{Driver: "vault", Path: "kv/a", LastAttemptTime: success, LastSuccessTime: success, Hash: "a1"},
+		{Driver: "vault", Path: "kv/b", LastAttemptTime: success, LastSuccessTime: success, Hash: "b1"},
+	}
+	now := metav1.Now()
+	dests, failed := mergeDestinationStatus(current, &SyncResult{
+		Destinations: []DestinationResult{
+			{Status: v1alpha1.DestinationStatus{Driver: "vault", Path: "kv/a", LastAttemptTime: now, LastError: "denied"}},
+			{Status: v1alpha1.DestinationStatus{Driver: "vault", Path: "kv/b"}, Deleted: true},
+			{Status: v1alpha1.DestinationStatus{Driver: "aws", Path: "c", LastAttemptTime: now, LastSuccessTime: now, Hash: "c1"}},
+		},
+	})
+	assert.Equal(t, 1, failed)
+	assert.Len(t, dests, 2)
+	assert.Equal(t, "aws", dests[0].Driver)
+	// a failed attempt keeps the last successful write
+	assert.Equal(t, "denied", dests[1].LastError)
+	assert.Equal(t, "a1", dests[1].Hash)
+	assert.Equal(t, success, dests[1].LastSuccessTime)
+
+	// a complete result replaces destinations which are no longer synced
+	dests, failed = mergeDestinationStatus(dests, &SyncResult{
+		Complete: true,
+		Destinations: []DestinationResult{
+			{Status: v1alpha1.DestinationStatus{Driver: "vault", Path: "kv/a", LastAttemptTime: now, LastSuccessTime: now, Hash: "a2"}},
+		},
+	})
+	assert.Equal(t, 0, failed)
+	assert.Len(t, dests, 1)
+	assert.Equal(t, "a2", dests[0].Hash)
+}
+
+func TestMergeDestinationStatusLimit(t *testing.T) {
+	var result SyncResult
+	for i := 0; i < MaxDestinationStatuses+10; i++ {
+		d := v1alpha1.DestinationStatus{
+			Driver:          "vault",
+			Path:            fmt.Sprintf("kv/%03d", i),
+			LastAttemptTime: metav1.NewTime(time.Unix(int64(i), 0)),
+		}
+		if i == 0 {
+			d.LastError = "denied"
+		}
+		result.Destinations = append(result.Destinations, DestinationResult{Status: d})
+	}
+	dests, failed := mergeDestinationStatus(nil, &result)
+	assert.Equal(t, 1, failed)
+	assert.Len(t, dests, MaxDestinationStatuses)
+	// the failed destination is kept over more recent successes
+	assert.Equal(t, "kv/000", dests[0].Path)
+	assert.Equal(t, "kv/011", dests[1].Path)
+}
+
+func TestTruncatePlan(t *testing.T) {
+	p := &v1alpha1.Plan{}
+	for i := 0; i < MaxPlanChanges+10; i++ {
+		p.Changes = append(p.Changes, v1alpha1.PlannedChange{
+			Driver: "vault",
+			Path:   fmt.Sprintf("kv/%03d", i),
+			Action: v1alpha1.PlanActionNoOp,
+		})
+	}
+	p.Changes[MaxPlanChanges+5].Action = v1alpha1.PlanActionDelete
+	p.Changes[MaxPlanChanges+6].Error = "denied"
+
+	tp := truncatePlan(p)
+	assert.True(t, tp.Truncated)
+	assert.Len(t, tp.Changes, MaxPlanChanges)
+	assert.Len(t, p.Changes, MaxPlanChanges+10)
+	paths := make(map[string]bool)
+	for _, c := range tp.Changes {
+		paths[c.Path] = true
+	}
+	assert.True(t, paths[fmt.Sprintf("kv/%03d", MaxPlanChanges+5)])
+	assert.True(t, paths[fmt.Sprintf("kv/%03d", MaxPlanChanges+6)])
+
+	small := &v1alpha1.Plan{Changes: p.Changes[:1]}
+	assert.Same(t, small, truncatePlan(small))
+}
+
+func TestWritePlanKube(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	prev := Reconciler
+	Reconciler = &VaultSecretSyncReconciler{Client: c, APIReader: c, Scheme: scheme}
+	defer func() { Reconciler = prev }()
+	ctx := context.Background()
+	s := &v1alpha1.VaultSecretSync{}
+	s.Name, s.Namespace, s.UID = "app", "test", "uid"
+
+	p := &v1alpha1.Plan{Changes: []v1alpha1.PlannedChange{{Driver: "aws", Path: "a", Action: v1alpha1.PlanActionCreate}}}
+	require.NoError(t, writePlanKube(ctx, s, p))
+	p.Changes[0].Action = v1alpha1.PlanActionDelete
+	require.NoError(t, writePlanKube(ctx, s, p))
+
+	cm := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Namespace: "test", Name: "app-vss-plan"}, cm))
+	assert.Contains(t, cm.Data[planDataKey], `"action":"delete"`)
+	require.Len(t, cm.OwnerReferences, 1)
+	assert.Equal(t, "app", cm.OwnerReferences[0].Name)
+
+	require.NoError(t, deletePlanKube(ctx, s))
+	assert.True(t, apierrors.IsNotFound(c.Get(ctx, client.ObjectKey{Namespace: "test", Name: "app-vss-plan"}, cm)))
+	// deleting a missing plan is not an error
+	assert.NoError(t, deletePlanKube(ctx, s))
+}
@@ -0,0 +1,387 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// inventoryDataKey is the key in the state secret holding the inventory
+	inventoryDataKey = "inventory.json"
+	// inventoryTombstonesKey is the key in the state secret holding the
+	// pending deletes
+	inventoryTombstonesKey = "tombstones.json"
+	// inventorySecretSuffix is appended to the sync config name to name its state secret
+	inventorySecretSuffix = "-vss-state"
+)
+
+// InventoryRecord is a single destination secret written by a sync config
+type InventoryRecord struct {
+	Driver string `json:"driver"`
+	// Location identifies the destination store, such as the vault address or aws region
+	Location   string `json:"location,omitempty"`
+	Path       string `json:"path"`
+	SourcePath string `json:"sourcePath,omitempty"`
+	// SourceKey is the source secret key written, if the destination splits keys
+	SourceKey string `json:"sourceKey,omitempty"`
+	// Hash is a salted hash of the last payload written to the destination
+	Hash string `json:"hash,omitempty"`
+	// Metadata are the keys of the source metadata propagated by the last write
+	Metadata []string `json:"metadata,omitempty"`
+	// SourceVersion and DestVersion are the versions of the source and
+	// destination secrets of a bidirectional sync when they were last in sync
+	SourceVersion int `json:"sourceVersion,omitempty"`
+	DestVersion   int `json:"destVersion,omitempty"`
+}
+
+// Tombstone is a pending delete of a destination secret, which is deleted
+// once its grace period has passed unless cancelled
+type Tombstone struct {
+	InventoryRecord
+	DeleteAfter time.Time `json:"deleteAfter"`
+}
+
+// Key returns the key of the record in the inventory
+func (r InventoryRecord) Key() string {
+	return InventoryKey(r.Driver, r.Location, r.Path)
+}
+
+// equal returns true if the records are the same
+func (r InventoryRecord) equal(o InventoryRecord) bool {
+	return r.Driver == o.Driver && r.Location == o.Location && r.Path == o.Path &&
+		r.SourcePath == o.SourcePath && r.SourceKey == o.SourceKey && r.Hash == o.Hash &&
+		slices.Equal(r.Metadata, o.Metadata) && r.SourceVersion == o.SourceVersion && r.DestVersion == o.DestVersion
+}
+
+// StoreKey returns the key of the store the record was written to
+func (r InventoryRecord) StoreKey() string {
+	return StoreKey(r.Driver, r.Location)
+}
+
+// InventoryKey returns the key of a destination secret in the inventory
+func InventoryKey(driver, location, path string) string {
+	return driver + "|" + location + "|" + path
+}
+
+// StoreKey returns the key of a destination store in the inventory
+func StoreKey(driver, location string) string {
+	return driver + "|" + location
+}
+
+// Inventory is the compact state of a sync config, tracking every destination
+// secret it has written. It is persisted in a secret in the namespace of the
+// sync config, owned by the sync config so that it is removed with it.
+// Only the driver and location of each record are kept, not the config of
+// its store, as the state secret can be edited in the namespace of the sync
+// config. Records of stores removed from the sync config are dropped rather
+// than pruned.
+type Inventory struct {
+	mu         sync.Mutex
+	name       string
+	namespace  string
+	uid        types.UID
+	loaded     bool
+	dirty      bool
+	records    map[string]InventoryRecord
+	tombstones map[string]Tombstone
+}
+
+var (
+	inventories   = make(map[string]*Inventory)
+	inventoriesMu sync.Mutex
+)
+
+// InventorySecretName returns the name of the state secret of the sync config
+func InventorySecretName(name string) string {
+	return name + inventorySecretSuffix
+}
+
+// GetInventory returns the inventory of the sync config, loading it from
+// the state secret on first use. The operator is the only writer of the
+// inventory, so once loaded the in-memory copy is authoritative.
+func GetInventory(ctx context.Context, s v1alpha1.VaultSecretSync) (*Inventory, error) {
+	name := InternalName(s.Namespace, s.Name)
+	inventoriesMu.Lock()
+	inv, ok := inventories[name]
+	if !ok || inv.uid != s.UID {
+		// a sync config recreated with the same name starts a new inventory
+		inv = &Inventory{
+			name:       s.Name,
+			namespace:  s.Namespace,
+			uid:        s.UID,
+			records:    make(map[string]InventoryRecord),
+			tombstones: make(map[string]Tombstone),
+		}
+		inventories[name] = inv
+	}
+	inventoriesMu.Unlock()
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.loaded {
+		return inv, nil
+	}
+	if err := inv.load(ctx); err != nil {
+		return nil, err
+	}
+	inv.loaded = true
+	return inv, nil
+}
+
+// forgetInventory drops the in-memory inventory of the named sync config
+func forgetInventory(name string) {
+	inventoriesMu.Lock()
+	defer inventoriesMu.Unlock()
+	delete(inventories, name)
+}
+
+// load reads the inventory from the state secret. inv.mu must be held.
+func (inv *Inventory) load(ctx context.Context) error {
+	if Reconciler == nil {
+		return nil
+	}
+	sec := &corev1.Secret{}
+	err := Reconciler.APIReader.Get(ctx, client.ObjectKey{Namespace: inv.namespace, Name: InventorySecretName(inv.name)}, sec)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var records []InventoryRecord
+	if err := json.Unmarshal(sec.Data[inventoryDataKey], &records); err != nil {
+		return err
+	}
+	for _, r := range records {
+		inv.records[r.Key()] = r
+	}
+	if td, ok := sec.Data[inventoryTombstonesKey]; ok {
+		var tombstones []Tombstone
+		if err := json.Unmarshal(td, &tombstones); err != nil {
+			return err
+		}
+		for _, t := range tombstones {
+			inv.tombstones[t.Key()] = t
+		}
+	}
+	return nil
+}
+
+// Get returns the record for the destination secret
+func (inv *Inventory) Get(key string) (InventoryRecord, bool) {
+	if inv == nil {
+		return InventoryRecord{}, false
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	r, ok := inv.records[key]
+	return r, ok
+}
+
+// Put adds or replaces the record for the destination secret
+func (inv *Inventory) Put(r InventoryRecord) {
+	if inv == nil {
+		return
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if cur, ok := inv.records[r.Key()]; ok && cur.equal(r) {
+		return
+	}
+	inv.records[r.Key()] = r
+	inv.dirty = true
+}
+
+// Delete removes the record for the destination secret
+func (inv *Inventory) Delete(key string) {
+	if inv == nil {
+		return
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if _, ok := inv.records[key]; !ok {
+		return
+	}
+	delete(inv.records, key)
+	inv.dirty = true
+}
+
+// PutTombstone records a pending delete of the destination secret. A
+// secret which already has a pending delete keeps its earlier deadline.
+func (inv *Inventory) PutTombstone(t Tombstone) {
+	if inv == nil {
+		return
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if _, ok := inv.tombstones[t.Key()]; ok {
+		return
+	}
+	inv.tombstones[t.Key()] = t
+	inv.dirty = true
+}
+
+// CancelTombstone cancels the pending delete of the destination secret,
+// returning false if it had none
+func (inv *Inventory) CancelTombstone(key string) bool {
+	if inv == nil {
+		return false
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if _, ok := inv.tombstones[key]; !ok {
+		return false
+	}
+	delete(inv.tombstones, key)
+	inv.dirty = true
+	return true
+}
+
+// ClearTombstones cancels every pending delete, returning the number cancelled
+func (inv *Inventory) ClearTombstones() int {
+	if inv == nil {
+		return 0
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	n := len(inv.tombstones)
+	if n > 0 {
+		inv.tombstones = make(map[string]Tombstone)
+		inv.dirty = true
+	}
+	return n
+}
+
+// Tombstones returns the pending deletes, sorted by deadline then key
+func (inv *Inventory) Tombstones() []Tombstone {
+	if inv == nil {
+		return nil
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return inv.sortedTombstones()
+}
+
+// sortedTombstones returns the tombstones sorted by deadline then key. inv.mu must be held.
+func (inv *Inventory) sortedTombstones() []Tombstone {
+	tombstones := make([]Tombstone, 0, len(inv.tombstones))
+	for _, t := range inv.tombstones {
+		tombstones = append(tombstones, t)
+	}
+	sort.Slice(tombstones, func(i, j int) bool {
+		if !tombstones[i].DeleteAfter.Equal(tombstones[j].DeleteAfter) {
+			return tombstones[i].DeleteAfter.Before(tombstones[j].DeleteAfter)
+		}
+		return tombstones[i].Key() < tombstones[j].Key()
+	})
+	return tombstones
+}
+
+// Records returns all records in the inventory, sorted by key
+func (inv *Inventory) Records() []InventoryRecord {
+	if inv == nil {
+		return nil
+	}
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return inv.sortedRecords()
+}
+
+// sortedRecords returns the records sorted by key. inv.mu must be held.
+func (inv *Inventory) sortedRecords() []InventoryRecord {
+	records := make([]InventoryRecord, 0, len(inv.records))
+	for _, r := range inv.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key() < records[j].Key()
+	})
+	return records
+}
+
+// Save persists the inventory to the state secret if it has changed
+func (inv *Inventory) Save(ctx context.Context) error {
+	if inv == nil {
+		return nil
+	}
+	l := log.WithFields(log.Fields{
+		"action":    "Inventory.Save",
+		"name":      inv.name,
+		"namespace": inv.namespace,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if !inv.dirty {
+		return nil
+	}
+	if Reconciler == nil {
+		inv.dirty = false
+		return nil
+	}
+	records := inv.sortedRecords()
+	jd, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tombstones := inv.sortedTombstones()
+	td, err := json.Marshal(tombstones)
+	if err != nil {
+		return err
+	}
+	if err := inv.writeSecret(ctx, map[string][]byte{inventoryDataKey: jd, inventoryTombstonesKey: td}); err != nil {
+		l.WithError(err).Error("failed to save inventory")
+		return err
+	}
+	inv.dirty = false
+	l.WithField("records", len(inv.records)).Debug("inventory saved")
+	return nil
+}
+
+// writeSecret creates or updates the state secret. inv.mu must be held.
+func (inv *Inventory) writeSecret(ctx context.Context, data map[string][]byte) error {
+	sec := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: inv.namespace, Name: InventorySecretName(inv.name)}
+	err := Reconciler.APIReader.Get(ctx, key, sec)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if apierrors.IsNotFound(err) {
+		sec = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "vault-secret-sync",
+				},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: data,
+		}
+		owner := &v1alpha1.VaultSecretSync{}
+		owner.Name, owner.Namespace, owner.UID = inv.name, inv.namespace, inv.uid
+		if err := controllerutil.SetOwnerReference(owner, sec, Reconciler.Scheme); err != nil {
+			return err
+		}
+		return Reconciler.Create(ctx, sec, client.FieldOwner("vault-secret-sync-controller"))
+	}
+	if sec.Data == nil {
+		sec.Data = make(map[string][]byte)
+	}
+	for k, v := range data {
+		sec.Data[k] = v
+	}
+	return Reconciler.Update(ctx, sec, client.FieldOwner("vault-secret-sync-controller"))
+}
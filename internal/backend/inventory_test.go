@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInventory(t *testing.T) {
+	ctx := context.Background()
+	s := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "inventory", UID: "uid-1"},
+	}
+	inv, err := GetInventory(ctx, s)
+	assert.NoError(t, err)
+
+	r := InventoryRecord{Driver: "aws", Location: "us-east-1/", Path: "app", SourcePath: "kv/app", Hash: "h1"}
+	inv.Put(r)
+	assert.True(t, inv.dirty)
+	assert.NoError(t, inv.Save(ctx))
+	assert.False(t, inv.dirty)
+
+	// putting an identical record does not require a save
+	inv.Put(r)
+	assert.False(t, inv.dirty)
+
+	// the inventory is shared across syncs of the same config
+	again, err := GetInventory(ctx, s)
+	assert.NoError(t, err)
+	got, ok := again.Get(r.Key())
+	assert.True(t, ok)
+	assert.Equal(t, r, got)
+
+	// a recreated config starts with an empty inventory
+	s.UID = "uid-2"
+	recreated, err := GetInventory(ctx, s)
+	assert.NoError(t, err)
+	assert.Empty(t, recreated.Records())
+
+	recreated.Put(r)
+	recreated.Delete(r.Key())
+	assert.Empty(t, recreated.Records())
+
+	forgetInventory(InternalName(s.Namespace, s.Name))
+	var nilInv *Inventory
+	assert.NotPanics(t, func() {
+		nilInv.Put(r)
+		nilInv.Delete(r.Key())
+		assert.NoError(t, nilInv.Save(ctx))
+	})
+}
+
+func TestInventoryTombstones(t *testing.T) {
+	ctx := context.Background()
+	s := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: "tombstones", UID: "uid-1"},
+	}
+	inv, err := GetInventory(ctx, s)
+	assert.NoError(t, err)
+	defer forgetInventory(InternalName(s.Namespace, s.Name))
+
+	now := time.Now()
+	a := Tombstone{InventoryRecord: InventoryRecord{Driver: "aws", Path: "a"}, DeleteAfter: now.Add(2 * time.Hour)}
+	b := Tombstone{InventoryRecord: InventoryRecord{Driver: "aws", Path: "b"}, DeleteAfter: now.Add(time.Hour)}
+	inv.PutTombstone(a)
+	inv.PutTombstone(b)
+	assert.True(t, inv.dirty)
+
+	// a secret which already has a pending delete keeps its deadline
+	later := b
+	later.DeleteAfter = now.Add(3 * time.Hour)
+	inv.PutTombstone(later)
+	assert.Equal(t, []Tombstone{b, a}, inv.Tombstones())
+
+	assert.True(t, inv.CancelTombstone(b.Key()))
+	assert.False(t, inv.CancelTombstone(b.Key()))
+	assert.Equal(t, []Tombstone{a}, inv.Tombstones())
+	assert.Equal(t, 1, inv.ClearTombstones())
+	assert.Empty(t, inv.Tombstones())
+}
@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/logical"
 	log "github.com/sirupsen/logrus"
@@ -16,13 +18,16 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 
 	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
 	vaultv1alpha1 "github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/syncwindow"
 	zzap "go.uber.org/zap"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
 )
@@ -35,6 +40,9 @@ const (
 	SyncStatusFailed    SyncStatusString = "Failed"
 	SyncStatusDryRun    SyncStatusString = "DryRun"
 	SyncStatusSuspended SyncStatusString = "Suspended"
+	SyncStatusDrifted   SyncStatusString = "Drifted"
+	SyncStatusRetrying  SyncStatusString = "Retrying"
+	SyncStatusHeld      SyncStatusString = "Held"
 )
 
 var (
@@ -66,13 +74,15 @@ func (b *KubernetesBackend) Type() BackendType {
 	return BackendTypeKubernetes
 }
 
-func SetSyncStatus(ctx context.Context, sc v1alpha1.VaultSecretSync, status SyncStatusString) error {
+// SetSyncStatus sets the sync status of the sync config. If result is not nil,
+// the status of the destinations it touched is updated.
+func SetSyncStatus(ctx context.Context, sc v1alpha1.VaultSecretSync, status SyncStatusString, result *SyncResult) error {
 	if B == nil {
 		return nil
 	}
 	switch B.Type() {
 	case BackendTypeKubernetes:
-		return setSyncStatusKube(ctx, sc, status)
+		return setSyncStatusKube(ctx, sc, status, result)
 	default:
 		return nil
 	}
@@ -129,6 +139,88 @@ func AnnotationOperations(r *VaultSecretSyncReconciler, vaultSecretSync *v1alpha
 		}
 		r.Recorder.Event(vaultSecretSync, "Normal", "ManualTrigger", "Force-sync sync triggered")
 	}
+
+	// If it has a "redrive-dead-letters" annotation, sync the dead letters again and remove the annotation
+	if vaultSecretSync.ObjectMeta.Annotations["redrive-dead-letters"] != "" {
+		l.Debug("redrive annotation found, redriving dead letters")
+		delete(vaultSecretSync.ObjectMeta.Annotations, "redrive-dead-letters")
+		if err := r.Update(context.Background(), vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+			l.Errorf("failed to update object: %v", err)
+			return err
+		}
+		if err := redriveDeadLetters(context.Background(), r, vaultSecretSync); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "Redrive", "Failed to redrive dead letters")
+			return err
+		}
+		r.Recorder.Event(vaultSecretSync, "Normal", "Redrive", "Dead letters redriven")
+	}
+
+	// If it has a "cancel-pending-deletes" annotation, cancel the deletes waiting out the grace period
+	if vaultSecretSync.ObjectMeta.Annotations["cancel-pending-deletes"] != "" {
+		l.Debug("cancel-pending-deletes annotation found, cancelling pending deletes")
+		delete(vaultSecretSync.ObjectMeta.Annotations, "cancel-pending-deletes")
+		if err := r.Update(context.Background(), vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+			l.Errorf("failed to update object: %v", err)
+			return err
+		}
+		n, err := cancelPendingDeletes(context.Background(), r, vaultSecretSync)
+		if err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "CancelPendingDeletes", "Failed to cancel pending deletes")
+			return err
+		}
+		r.Recorder.Event(vaultSecretSync, "Normal", "CancelPendingDeletes", fmt.Sprintf("Cancelled %d pending deletes", n))
+	}
+
+	// If it has a "sync-window-override" annotation, apply the held events regardless of the sync windows
+	if vaultSecretSync.ObjectMeta.Annotations["sync-window-override"] != "" {
+		l.Debug("sync-window-override annotation found, releasing held events")
+		delete(vaultSecretSync.ObjectMeta.Annotations, "sync-window-override")
+		if err := r.Update(context.Background(), vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+			l.Errorf("failed to update object: %v", err)
+			return err
+		}
+		if err := releaseHeldEvents(context.Background(), r, vaultSecretSync, true); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "SyncWindowOverride", "Failed to release held events")
+			return err
+		}
+		r.Recorder.Event(vaultSecretSync, "Normal", "SyncWindowOverride", "Held events applied outside the sync windows")
+	}
+	// If it has a "sync-version" annotation, sync that version of the source secrets once and remove the annotation
+	if v := vaultSecretSync.ObjectMeta.Annotations["sync-version"]; v != "" {
+		l.Debugf("sync-version annotation found: %s", v)
+		delete(vaultSecretSync.ObjectMeta.Annotations, "sync-version")
+		if err := r.Update(context.Background(), vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+			l.Errorf("failed to update object: %v", err)
+			return err
+		}
+		version, err := strconv.Atoi(v)
+		if err != nil || version <= 0 {
+			// retrying will not fix the annotation, which has been removed
+			r.Recorder.Event(vaultSecretSync, "Warning", "SyncVersion", fmt.Sprintf("Invalid sync-version %q, must be a positive KV version number", v))
+		} else if err := VersionTrigger(context.Background(), *vaultSecretSync, version); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "SyncVersion", fmt.Sprintf("Failed to trigger sync of source version %d", version))
+			return err
+		} else {
+			r.Recorder.Event(vaultSecretSync, "Normal", "SyncVersion", fmt.Sprintf("Sync of source version %d triggered", version))
+		}
+	}
+	// If it has a "rotate-now" annotation, rotate the source secret once and remove the annotation
+	if vaultSecretSync.ObjectMeta.Annotations["rotate-now"] != "" {
+		l.Debug("rotate-now annotation found, rotating now")
+		delete(vaultSecretSync.ObjectMeta.Annotations, "rotate-now")
+		if err := r.Update(context.Background(), vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+			l.Errorf("failed to update object: %v", err)
+			return err
+		}
+		if vaultSecretSync.Spec.Rotation == nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "Rotation", "rotate-now requires spec.rotation")
+		} else if err := RotationTrigger(context.Background(), *vaultSecretSync, RotationPhaseRotate); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "Rotation", "Failed to trigger rotation")
+			return err
+		} else {
+			r.Recorder.Event(vaultSecretSync, "Normal", "Rotation", "Rotation triggered")
+		}
+	}
 	l.Debug("annotation operations complete")
 	return nil
 }
@@ -152,7 +244,7 @@ func createHash(s vaultv1alpha1.VaultSecretSync) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-func setSyncStatusKube(ctx context.Context, sc v1alpha1.VaultSecretSync, status SyncStatusString) error {
+func setSyncStatusKube(ctx context.Context, sc v1alpha1.VaultSecretSync, status SyncStatusString, result *SyncResult) error {
 	l := log.WithFields(log.Fields{
 		"action":    "setSyncStatusKube",
 		"status":    status,
@@ -162,28 +254,65 @@ func setSyncStatusKube(ctx context.Context, sc v1alpha1.VaultSecretSync, status
 	l.Trace("start")
 	defer l.Trace("end")
 	l.Debug("setting sync status")
-	s := &vaultv1alpha1.VaultSecretSync{}
-	err := Reconciler.Get(ctx, client.ObjectKey{Namespace: sc.Namespace, Name: sc.Name}, s)
-	if err != nil {
-		l.Errorf("failed to get object: %v", err)
-		return err
-	}
-	objHash, err := createHash(*s)
+	// status is written by every job of the sync config, so conflicts are
+	// retried against the current object rather than dropping the result
+	var s *vaultv1alpha1.VaultSecretSync
+	var planCleared bool
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s, planCleared = &vaultv1alpha1.VaultSecretSync{}, false
+		err := Reconciler.Get(ctx, client.ObjectKey{Namespace: sc.Namespace, Name: sc.Name}, s)
+		if err != nil {
+			l.Errorf("failed to get object: %v", err)
+			return err
+		}
+		objHash, err := createHash(*s)
+		if err != nil {
+			l.Errorf("failed to create hash: %v", err)
+			return err
+		}
+		// Prepare the patch
+		s.Status.Status = string(status)
+		s.Status.LastSyncTime = metav1.Now()
+		s.Status.SyncDestinations = len(s.Spec.Dest)
+		s.Status.Hash = objHash
+		if result != nil {
+			s.Status.Destinations, s.Status.FailedDestinations = mergeDestinationStatus(s.Status.Destinations, result)
+		}
+		if result != nil && result.PendingDeletes != nil {
+			s.Status.PendingDeletes, s.Status.PendingDeleteCount = pendingDeleteStatus(result.PendingDeletes)
+		}
+		if result != nil && result.Plan != nil {
+			s.Status.Plan = truncatePlan(result.Plan)
+		} else if !s.PartialDryRun() {
+			planCleared = s.Status.Plan != nil
+			s.Status.Plan = nil
+		}
+		// conditions are observed for the generation the sync ran with, which
+		// may be older than the current object if the spec changed since
+		generation := sc.Generation
+		if generation == 0 {
+			generation = s.Generation
+		}
+		setSyncConditions(s, status, generation, result != nil && result.TimedOut)
+		l.Debugf("updating status: %+v", s.Status)
+		return Reconciler.Status().Update(context.Background(), s, client.FieldOwner("vault-secret-sync-controller"))
+	})
 	if err != nil {
-		l.Errorf("failed to create hash: %v", err)
-		return err
-	}
-	// Prepare the patch
-	s.Status.Status = string(status)
-	s.Status.LastSyncTime = metav1.Now()
-	s.Status.SyncDestinations = len(s.Spec.Dest)
-	s.Status.Hash = objHash
-	l.Debugf("updating status: %+v", s.Status)
-	if err := Reconciler.Status().Update(context.Background(), s, client.FieldOwner("vault-secret-sync-controller")); err != nil {
 		l.Errorf("failed to update status: %v", err)
 		return err
 	}
 	l.Debug("status updated")
+	// the full plan is kept next to the sync config, as only part of it may
+	// fit in status
+	if result != nil && result.Plan != nil {
+		if err := writePlanKube(ctx, s, result.Plan); err != nil {
+			l.Errorf("failed to write plan: %v", err)
+		}
+	} else if planCleared {
+		if err := deletePlanKube(ctx, s); err != nil {
+			l.Errorf("failed to delete plan: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -210,6 +339,10 @@ func (r *VaultSecretSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		}
 		l.Trace("object not found")
 		internalName := InternalName(req.Namespace, req.Name)
+		clearResync(internalName)
+		clearRotation(internalName)
+		clearPendingDeletes(internalName)
+		forgetInventory(internalName)
 		if err := RemoveSyncConfig(internalName); err != nil {
 			l.Errorf("failed to remove sync config: %v", err)
 			return ctrl.Result{}, err
@@ -225,17 +358,22 @@ func (r *VaultSecretSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 
 	// Check if the object is being deleted
 	if !vaultSecretSync.ObjectMeta.DeletionTimestamp.IsZero() {
-		// The object is being deleted
-		if err := RemoveSyncConfig(InternalName(vaultSecretSync.Namespace, vaultSecretSync.Name)); err != nil {
-			l.Errorf("failed to remove sync config: %v", err)
-		}
-		if vaultSecretSync.ObjectMeta.Annotations["delete-on-removal"] == "true" {
-			if err := ManualTrigger(ctx, *vaultSecretSync, logical.DeleteOperation); err != nil {
-				r.Recorder.Event(vaultSecretSync, "Warning", "Deleting", "Failed to delete secret")
+		return r.reconcileDelete(ctx, vaultSecretSync)
+	}
+
+	// the delete-on-removal finalizer holds the object until its destination secrets are deleted
+	if vaultSecretSync.ObjectMeta.Annotations["delete-on-removal"] == "true" {
+		if controllerutil.AddFinalizer(vaultSecretSync, DeleteOnRemovalFinalizer) {
+			if err := r.Update(ctx, vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+				l.Errorf("failed to add finalizer: %v", err)
+				return ctrl.Result{}, err
 			}
 		}
-		r.Recorder.Event(vaultSecretSync, "Normal", "Deleted", "Finalizer operations completed and finalizer removed")
-		return ctrl.Result{}, nil
+	} else if controllerutil.RemoveFinalizer(vaultSecretSync, DeleteOnRemovalFinalizer) {
+		if err := r.Update(ctx, vaultSecretSync, client.FieldOwner("vault-secret-sync-controller")); err != nil {
+			l.Errorf("failed to remove finalizer: %v", err)
+			return ctrl.Result{}, err
+		}
 	}
 
 	// sanity check debug log the input object
@@ -284,9 +422,139 @@ func (r *VaultSecretSyncReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
+	// periodically resync to correct lost events and out-of-band destination changes
+	result := ctrl.Result{}
+	resyncDue, requeueAfter := scheduleResync(*vaultSecretSync, time.Now())
+	if resyncDue && !syncNow {
+		l.Debug("periodic resync due, syncing now")
+		if err := ResyncTrigger(ctx, *vaultSecretSync); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "Resync", "Failed to trigger periodic resync")
+		}
+	}
+	if requeueAfter > 0 {
+		l.WithField("requeueAfter", requeueAfter).Debug("scheduling next resync")
+		result.RequeueAfter = requeueAfter
+	}
+
+	// deletes waiting out the grace period are run once due
+	deletesDue, deletesAfter := schedulePendingDeletes(*vaultSecretSync, time.Now())
+	if deletesDue {
+		l.Debug("pending deletes due, deleting now")
+		if err := PendingDeletesTrigger(ctx, *vaultSecretSync); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "PendingDeletes", "Failed to trigger pending deletes")
+		}
+	}
+	if deletesAfter > 0 && (result.RequeueAfter == 0 || deletesAfter < result.RequeueAfter) {
+		l.WithField("requeueAfter", deletesAfter).Debug("scheduling pending deletes")
+		result.RequeueAfter = deletesAfter
+	}
+
+	// the source secret is rotated on the rotation schedule, and the previous
+	// values of the rotated keys removed once the overlap ends
+	phase, rotationAfter := scheduleRotation(*vaultSecretSync, time.Now())
+	if phase != "" {
+		l.WithField("phase", phase).Debug("rotation due")
+		if err := RotationTrigger(ctx, *vaultSecretSync, phase); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "Rotation", "Failed to trigger rotation")
+		}
+	}
+	if rotationAfter > 0 && (result.RequeueAfter == 0 || rotationAfter < result.RequeueAfter) {
+		l.WithField("requeueAfter", rotationAfter).Debug("scheduling next rotation")
+		result.RequeueAfter = rotationAfter
+	}
+
+	// held events are applied once a sync window opens
+	if len(vaultSecretSync.Status.HeldEvents) > 0 {
+		open, next, err := syncwindow.Open(vaultSecretSync.Spec.SyncWindows, time.Now())
+		switch {
+		case err != nil:
+			r.Recorder.Event(vaultSecretSync, "Warning", "SyncWindow", fmt.Sprintf("Invalid sync windows: %v", err))
+		case open:
+			l.Debug("sync window open, releasing held events")
+			if err := releaseHeldEvents(ctx, r, vaultSecretSync, false); err != nil {
+				r.Recorder.Event(vaultSecretSync, "Warning", "SyncWindow", "Failed to release held events")
+				return result, err
+			}
+		case !next.IsZero():
+			windowAfter := time.Until(next)
+			if result.RequeueAfter == 0 || windowAfter < result.RequeueAfter {
+				l.WithField("requeueAfter", windowAfter).Debug("scheduling next sync window")
+				result.RequeueAfter = windowAfter
+			}
+		}
+	}
+
 	l.Debug("reconcile complete")
 
-	return ctrl.Result{}, nil
+	return result, nil
+}
+
+// reconcileDelete handles a VaultSecretSync being deleted. With the
+// delete-on-removal finalizer, the sync config is kept until its destination
+// secrets are deleted, which releases the finalizer. Removing the
+// delete-on-removal annotation, or cancelling the pending deletes, releases
+// the object without deleting the remaining secrets.
+func (r *VaultSecretSyncReconciler) reconcileDelete(ctx context.Context, vaultSecretSync *vaultv1alpha1.VaultSecretSync) (ctrl.Result, error) {
+	l := log.WithFields(log.Fields{
+		"action":    "reconcileDelete",
+		"namespace": vaultSecretSync.Namespace,
+		"name":      vaultSecretSync.Name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	internalName := InternalName(vaultSecretSync.Namespace, vaultSecretSync.Name)
+	clearResync(internalName)
+	if !controllerutil.ContainsFinalizer(vaultSecretSync, DeleteOnRemovalFinalizer) {
+		clearPendingDeletes(internalName)
+		forgetInventory(internalName)
+		if err := RemoveSyncConfig(internalName); err != nil {
+			l.Errorf("failed to remove sync config: %v", err)
+		}
+		return ctrl.Result{}, nil
+	}
+	release := vaultSecretSync.ObjectMeta.Annotations["delete-on-removal"] != "true"
+	if vaultSecretSync.ObjectMeta.Annotations["cancel-pending-deletes"] != "" {
+		n, err := cancelPendingDeletes(ctx, r, vaultSecretSync)
+		if err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "CancelPendingDeletes", "Failed to cancel pending deletes")
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(vaultSecretSync, "Normal", "CancelPendingDeletes", fmt.Sprintf("Cancelled %d pending deletes", n))
+		release = true
+	}
+	if release {
+		l.Debug("delete-on-removal cancelled, releasing object")
+		if err := removeFinalizer(ctx, r, client.ObjectKeyFromObject(vaultSecretSync)); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(vaultSecretSync, "Normal", "Deleted", "Delete-on-removal cancelled and finalizer removed")
+		return ctrl.Result{}, nil
+	}
+	// the sync config stays registered so that the delete can be synced
+	if err := AddSyncConfig(*vaultSecretSync); err != nil {
+		l.Errorf("failed to add sync config: %v", err)
+		return ctrl.Result{}, err
+	}
+	if triggerRemoval(vaultSecretSync.UID) {
+		l.Debug("deleting destination secrets")
+		if err := ManualTrigger(ctx, *vaultSecretSync, logical.DeleteOperation); err != nil {
+			forgetRemoval(vaultSecretSync.UID)
+			r.Recorder.Event(vaultSecretSync, "Warning", "Deleting", "Failed to delete secret")
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(vaultSecretSync, "Normal", "Deleting", "Deleting destination secrets before removal")
+	}
+	result := ctrl.Result{}
+	deletesDue, deletesAfter := schedulePendingDeletes(*vaultSecretSync, time.Now())
+	if deletesDue {
+		if err := PendingDeletesTrigger(ctx, *vaultSecretSync); err != nil {
+			r.Recorder.Event(vaultSecretSync, "Warning", "PendingDeletes", "Failed to trigger pending deletes")
+		}
+	}
+	if deletesAfter > 0 {
+		result.RequeueAfter = deletesAfter
+	}
+	return result, nil
 }
 
 func (r *VaultSecretSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
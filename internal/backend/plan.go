@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// MaxPlanChanges is the number of planned changes listed in the status of
+// a sync config. The full plan is written to its plan config map.
+const MaxPlanChanges = 100
+
+const (
+	// planDataKey is the key in the plan config map holding the full plan
+	planDataKey = "plan.json"
+	// planConfigMapSuffix is appended to the sync config name to name its
+	// plan config map
+	planConfigMapSuffix = "-vss-plan"
+)
+
+// PlanConfigMapName returns the name of the config map holding the full
+// plan of the sync config
+func PlanConfigMapName(name string) string {
+	return name + planConfigMapSuffix
+}
+
+// planActionOrder orders the planned changes kept when the plan is truncated
+var planActionOrder = map[v1alpha1.PlanAction]int{
+	v1alpha1.PlanActionDelete: 0,
+	v1alpha1.PlanActionUpdate: 1,
+	v1alpha1.PlanActionCreate: 2,
+	v1alpha1.PlanActionWrite:  3,
+	v1alpha1.PlanActionNoOp:   4,
+}
+
+// truncatePlan returns a copy of the plan listing at most MaxPlanChanges
+// changes, keeping changes with errors, then deletes, updates and creates
+// before no-ops
+func truncatePlan(p *v1alpha1.Plan) *v1alpha1.Plan {
+	if p == nil || len(p.Changes) <= MaxPlanChanges {
+		return p
+	}
+	t := p.DeepCopy()
+	sort.SliceStable(t.Changes, func(i, j int) bool {
+		a, b := t.Changes[i], t.Changes[j]
+		if (a.Error != "") != (b.Error != "") {
+			return a.Error != ""
+		}
+		return planActionOrder[a.Action] < planActionOrder[b.Action]
+	})
+	t.Changes = t.Changes[:MaxPlanChanges]
+	sort.Slice(t.Changes, func(i, j int) bool {
+		return InventoryKey(t.Changes[i].Driver, t.Changes[i].Location, t.Changes[i].Path) <
+			InventoryKey(t.Changes[j].Driver, t.Changes[j].Location, t.Changes[j].Path)
+	})
+	t.Truncated = true
+	return t
+}
+
+// writePlanKube writes the full plan to the plan config map in the
+// namespace of the sync config, so that it can be read by whoever can read
+// config maps there. The config map is owned by the sync config.
+func writePlanKube(ctx context.Context, s *v1alpha1.VaultSecretSync, p *v1alpha1.Plan) error {
+	jd, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: s.Namespace, Name: PlanConfigMapName(s.Name)}
+	err = Reconciler.APIReader.Get(ctx, key, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "vault-secret-sync",
+				},
+			},
+			Data: map[string]string{planDataKey: string(jd)},
+		}
+		if err := controllerutil.SetOwnerReference(s, cm, Reconciler.Scheme); err != nil {
+			return err
+		}
+		return Reconciler.Create(ctx, cm, client.FieldOwner("vault-secret-sync-controller"))
+	}
+	cm.Data = map[string]string{planDataKey: string(jd)}
+	return Reconciler.Update(ctx, cm, client.FieldOwner("vault-secret-sync-controller"))
+}
+
+// deletePlanKube deletes the plan config map of the sync config
+func deletePlanKube(ctx context.Context, s *v1alpha1.VaultSecretSync) error {
+	cm := &corev1.ConfigMap{}
+	cm.Name, cm.Namespace = PlanConfigMapName(s.Name), s.Namespace
+	return client.IgnoreNotFound(Reconciler.Delete(ctx, cm))
+}
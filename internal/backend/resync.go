@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+)
+
+// resyncJitter is the fraction of the resync interval used to spread
+// periodic resyncs so that objects created together do not fire together
+const resyncJitter = 0.1
+
+var (
+	// DefaultResyncInterval is the resync interval used when a
+	// VaultSecretSync does not set spec.resyncInterval. Zero disables
+	// periodic resyncs by default.
+	DefaultResyncInterval time.Duration
+
+	resyncSchedule   = make(map[string]time.Time)
+	resyncScheduleMu sync.Mutex
+)
+
+// ResyncInterval returns the effective resync interval for the sync config
+func ResyncInterval(s v1alpha1.VaultSecretSync) time.Duration {
+	if s.Spec.ResyncInterval != nil {
+		return s.Spec.ResyncInterval.Duration
+	}
+	return DefaultResyncInterval
+}
+
+// jitterDuration returns a random duration in [0, d*resyncJitter)
+func jitterDuration(d time.Duration) time.Duration {
+	j := int64(float64(d) * resyncJitter)
+	if j <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(j))
+}
+
+// scheduleResync determines whether a periodic resync of the sync config is
+// due at now, and how long until the next resync. The first resync is based
+// on the last sync time recorded in status so that restarts of the operator
+// do not resync everything at once, and each schedule is jittered.
+func scheduleResync(s v1alpha1.VaultSecretSync, now time.Time) (bool, time.Duration) {
+	interval := ResyncInterval(s)
+	name := InternalName(s.Namespace, s.Name)
+	resyncScheduleMu.Lock()
+	defer resyncScheduleMu.Unlock()
+	if interval <= 0 {
+		delete(resyncSchedule, name)
+		return false, 0
+	}
+	next, ok := resyncSchedule[name]
+	if !ok {
+		next = now.Add(interval)
+		if !s.Status.LastSyncTime.IsZero() {
+			next = s.Status.LastSyncTime.Add(interval)
+		}
+		if next.Before(now) {
+			next = now
+		}
+		next = next.Add(jitterDuration(interval))
+		resyncSchedule[name] = next
+	}
+	// the interval was shortened since the resync was scheduled
+	if next.Sub(now) > interval+time.Duration(float64(interval)*resyncJitter) {
+		next = now.Add(jitterDuration(interval))
+		resyncSchedule[name] = next
+	}
+	if now.Before(next) {
+		return false, next.Sub(now)
+	}
+	next = now.Add(interval + jitterDuration(interval))
+	resyncSchedule[name] = next
+	return true, next.Sub(now)
+}
+
+// clearResync removes the resync schedule for the named sync config
+func clearResync(name string) {
+	resyncScheduleMu.Lock()
+	defer resyncScheduleMu.Unlock()
+	delete(resyncSchedule, name)
+}
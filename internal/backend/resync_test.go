@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func resyncTestConfig(name string, interval time.Duration, lastSync time.Time) v1alpha1.VaultSecretSync {
+	return v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test", Name: name},
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			ResyncInterval: &metav1.Duration{Duration: interval},
+		},
+		Status: v1alpha1.VaultSecretSyncStatus{
+			LastSyncTime: metav1.NewTime(lastSync),
+		},
+	}
+}
+
+func TestScheduleResyncDisabled(t *testing.T) {
+	s := resyncTestConfig("disabled", 0, time.Now())
+	due, after := scheduleResync(s, time.Now())
+	assert.False(t, due)
+	assert.Zero(t, after)
+}
+
+func TestScheduleResyncUsesDefaultInterval(t *testing.T) {
+	orig := DefaultResyncInterval
+	DefaultResyncInterval = time.Hour
+	defer func() { DefaultResyncInterval = orig }()
+
+	s := resyncTestConfig("default", 0, time.Time{})
+	s.Spec.ResyncInterval = nil
+	assert.Equal(t, time.Hour, ResyncInterval(s))
+	defer clearResync(InternalName(s.Namespace, s.Name))
+
+	due, after := scheduleResync(s, time.Now())
+	assert.False(t, due)
+	assert.GreaterOrEqual(t, after, time.Hour)
+}
+
+func TestScheduleResyncDueAfterInterval(t *testing.T) {
+	now := time.Now()
+	s := resyncTestConfig("due", time.Hour, now.Add(-30*time.Minute))
+	defer clearResync(InternalName(s.Namespace, s.Name))
+
+	due, after := scheduleResync(s, now)
+	assert.False(t, due)
+	assert.GreaterOrEqual(t, after, 30*time.Minute)
+	assert.Less(t, after, 36*time.Minute)
+
+	// reconciles triggered by status updates do not move the schedule
+	due, again := scheduleResync(s, now.Add(time.Minute))
+	assert.False(t, due)
+	assert.Equal(t, after-time.Minute, again)
+
+	due, after = scheduleResync(s, now.Add(after))
+	assert.True(t, due)
+	assert.GreaterOrEqual(t, after, time.Hour)
+	assert.Less(t, after, 66*time.Minute)
+}
+
+func TestScheduleResyncOverdueIsJittered(t *testing.T) {
+	now := time.Now()
+	var fired int
+	for i := 0; i < 50; i++ {
+		s := resyncTestConfig("overdue-"+string(rune('a'+i%26))+string(rune('a'+i/26)), time.Hour, now.Add(-2*time.Hour))
+		due, after := scheduleResync(s, now)
+		if due {
+			fired++
+		}
+		assert.Less(t, after, 6*time.Minute+time.Hour)
+		clearResync(InternalName(s.Namespace, s.Name))
+	}
+	// overdue objects are spread across the jitter window rather than all firing at once
+	assert.Less(t, fired, 50)
+}
@@ -0,0 +1,145 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/rotation"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RotationPhase is a step of the rotation of the source secret of a sync config
+type RotationPhase string
+
+const (
+	// RotationPhaseRotate generates new values for the rotated keys
+	RotationPhaseRotate RotationPhase = "rotate"
+	// RotationPhaseEndOverlap removes the previous values of the rotated keys
+	RotationPhaseEndOverlap RotationPhase = "endOverlap"
+)
+
+// RotationTrigger runs the phase of the rotation of the source secret of the sync config
+var RotationTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync, phase RotationPhase) error
+
+var (
+	// rotationTriggered is the time each phase of the rotation of each sync
+	// config was last triggered for, so that a phase which is due is only
+	// triggered once while its result is pending
+	rotationTriggered   = make(map[string]time.Time)
+	rotationTriggeredMu sync.Mutex
+)
+
+// scheduleRotation returns the phase of the rotation of the sync config due
+// at now, if any, and how long until the next phase is due. Rotations are
+// scheduled after the last attempt, or the creation of the sync config.
+func scheduleRotation(s v1alpha1.VaultSecretSync, now time.Time) (RotationPhase, time.Duration) {
+	rc := s.Spec.Rotation
+	name := InternalName(s.Namespace, s.Name)
+	if rc == nil || s.Suspended() {
+		clearRotation(name)
+		return "", 0
+	}
+	l := log.WithFields(log.Fields{
+		"action":    "scheduleRotation",
+		"namespace": s.Namespace,
+		"name":      s.Name,
+	})
+	last := s.CreationTimestamp.Time
+	var overlapUntil time.Time
+	if rs := s.Status.Rotation; rs != nil {
+		if rs.LastAttemptTime != nil {
+			last = rs.LastAttemptTime.Time
+		}
+		if rs.OverlapUntil != nil {
+			overlapUntil = rs.OverlapUntil.Time
+		}
+	}
+	if last.IsZero() {
+		last = now
+	}
+	next, err := rotation.Next(*rc, last)
+	if err != nil {
+		l.WithError(err).Warn("invalid rotation schedule")
+		return "", 0
+	}
+
+	rotationTriggeredMu.Lock()
+	defer rotationTriggeredMu.Unlock()
+	due := func(phase RotationPhase, at time.Time) bool {
+		if at.IsZero() || now.Before(at) {
+			return false
+		}
+		k := name + "|" + string(phase)
+		if rotationTriggered[k].Equal(at) {
+			return false
+		}
+		rotationTriggered[k] = at
+		return true
+	}
+	var phase RotationPhase
+	switch {
+	case due(RotationPhaseRotate, next):
+		phase = RotationPhaseRotate
+	case due(RotationPhaseEndOverlap, overlapUntil):
+		phase = RotationPhaseEndOverlap
+	}
+
+	var after time.Duration
+	for _, at := range []time.Time{next, overlapUntil} {
+		if d := at.Sub(now); d > 0 && (after == 0 || d < after) {
+			after = d
+		}
+	}
+	return phase, after
+}
+
+// clearRotation removes the rotation schedule for the named sync config
+func clearRotation(name string) {
+	rotationTriggeredMu.Lock()
+	defer rotationTriggeredMu.Unlock()
+	for _, phase := range []RotationPhase{RotationPhaseRotate, RotationPhaseEndOverlap} {
+		delete(rotationTriggered, name+"|"+string(phase))
+	}
+}
+
+// UpdateRotationStatus updates the rotation status of the sync config
+func UpdateRotationStatus(ctx context.Context, sc v1alpha1.VaultSecretSync, update func(rs *v1alpha1.RotationStatus)) error {
+	if B == nil {
+		return nil
+	}
+	switch B.Type() {
+	case BackendTypeKubernetes:
+		return updateRotationStatusKube(ctx, sc, update)
+	default:
+		return nil
+	}
+}
+
+func updateRotationStatusKube(ctx context.Context, sc v1alpha1.VaultSecretSync, update func(rs *v1alpha1.RotationStatus)) error {
+	l := log.WithFields(log.Fields{
+		"action":    "updateRotationStatusKube",
+		"namespace": sc.Namespace,
+		"name":      sc.Name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s := &v1alpha1.VaultSecretSync{}
+		if err := Reconciler.Get(ctx, client.ObjectKey{Namespace: sc.Namespace, Name: sc.Name}, s); err != nil {
+			return err
+		}
+		if s.Status.Rotation == nil {
+			s.Status.Rotation = &v1alpha1.RotationStatus{}
+		}
+		update(s.Status.Rotation)
+		return Reconciler.Status().Update(ctx, s, client.FieldOwner("vault-secret-sync-controller"))
+	})
+	if err != nil {
+		l.Errorf("failed to update rotation status: %v", err)
+	}
+	return err
+}
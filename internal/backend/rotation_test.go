@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestScheduleRotation(t *testing.T) {
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	s := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Name: "rotate", Namespace: "test", CreationTimestamp: metav1.NewTime(created)},
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Rotation: &v1alpha1.RotationConfig{Schedule: "0 3 * * *"},
+		},
+	}
+	defer clearRotation(InternalName(s.Namespace, s.Name))
+
+	phase, after := scheduleRotation(s, created.Add(time.Hour))
+	assert.Empty(t, phase)
+	assert.Equal(t, 14*time.Hour, after)
+
+	// a due rotation is triggered once
+	due := created.Add(16 * time.Hour)
+	phase, _ = scheduleRotation(s, due)
+	assert.Equal(t, RotationPhaseRotate, phase)
+	phase, _ = scheduleRotation(s, due)
+	assert.Empty(t, phase)
+
+	// the next rotation is scheduled after the last attempt, and the overlap
+	// ends when due
+	attempt := metav1.NewTime(due)
+	overlapUntil := metav1.NewTime(due.Add(2 * time.Hour))
+	s.Status.Rotation = &v1alpha1.RotationStatus{LastAttemptTime: &attempt, OverlapUntil: &overlapUntil}
+	phase, after = scheduleRotation(s, due.Add(time.Hour))
+	assert.Empty(t, phase)
+	assert.Equal(t, time.Hour, after)
+	phase, after = scheduleRotation(s, due.Add(2*time.Hour))
+	assert.Equal(t, RotationPhaseEndOverlap, phase)
+	assert.Equal(t, 21*time.Hour, after)
+
+	// suspended syncs are not rotated
+	suspend := true
+	s.Spec.Suspend = &suspend
+	phase, after = scheduleRotation(s, due.Add(48*time.Hour))
+	assert.Empty(t, phase)
+	assert.Zero(t, after)
+}
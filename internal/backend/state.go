@@ -25,35 +25,53 @@ func init() {
 	SyncMaps = make(map[TenantName]TenantSyncs)
 }
 
-func addToSyncMaps(config v1alpha1.VaultSecretSync) {
+// syncTenantNamespaces returns the tenant and namespace of each vault whose
+// events the sync config handles: the source, and the destination of a
+// bidirectional sync
+func syncTenantNamespaces(config v1alpha1.VaultSecretSync) [][2]string {
 	tenant, namespace, _ := SourceTenantNamespace(config)
-	tn := TenantName(tenant)
-	tns := TenantNamespace(namespace)
+	tns := [][2]string{{tenant, namespace}}
+	if config.Spec.Bidirectional != nil && len(config.Spec.Dest) == 1 && config.Spec.Dest[0] != nil && config.Spec.Dest[0].Vault != nil {
+		d := config.Spec.Dest[0].Vault
+		tn := [2]string{d.Address, cmp.Or(d.Namespace, "default")}
+		if d.Address != "" && tn != tns[0] {
+			tns = append(tns, tn)
+		}
+	}
+	return tns
+}
 
-	if _, ok := SyncMaps[tn]; !ok {
-		SyncMaps[tn] = make(TenantSyncs)
+func addToSyncMaps(config v1alpha1.VaultSecretSync) {
+	for _, t := range syncTenantNamespaces(config) {
+		tn := TenantName(t[0])
+		tns := TenantNamespace(t[1])
+
+		if _, ok := SyncMaps[tn]; !ok {
+			SyncMaps[tn] = make(TenantSyncs)
+		}
+		SyncMaps[tn][tns] = append(SyncMaps[tn][tns], config)
 	}
-	SyncMaps[tn][tns] = append(SyncMaps[tn][tns], config)
 }
 
 func removeFromSyncMaps(config v1alpha1.VaultSecretSync) {
-	tenant, namespace, _ := SourceTenantNamespace(config)
-	tn := TenantName(tenant)
-	tns := TenantNamespace(namespace)
-
-	if tenantSyncs, ok := SyncMaps[tn]; ok {
-		if namespaceSyncs, ok := tenantSyncs[tns]; ok {
-			for i, c := range namespaceSyncs {
-				if c.Name == config.Name && c.Namespace == config.Namespace {
-					SyncMaps[tn][tns] = append(namespaceSyncs[:i], namespaceSyncs[i+1:]...)
-					break
+	for _, t := range syncTenantNamespaces(config) {
+		tn := TenantName(t[0])
+		tns := TenantNamespace(t[1])
+
+		if tenantSyncs, ok := SyncMaps[tn]; ok {
+			if namespaceSyncs, ok := tenantSyncs[tns]; ok {
+				for i, c := range namespaceSyncs {
+					if c.Name == config.Name && c.Namespace == config.Namespace {
+						SyncMaps[tn][tns] = append(namespaceSyncs[:i], namespaceSyncs[i+1:]...)
+						break
+					}
+				}
+				if len(SyncMaps[tn][tns]) == 0 {
+					delete(tenantSyncs, tns)
+				}
+				if len(tenantSyncs) == 0 {
+					delete(SyncMaps, tn)
 				}
-			}
-			if len(SyncMaps[tn][tns]) == 0 {
-				delete(tenantSyncs, tns)
-			}
-			if len(tenantSyncs) == 0 {
-				delete(SyncMaps, tn)
 			}
 		}
 	}
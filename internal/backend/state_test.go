@@ -204,3 +204,38 @@ func TestAddSyncConfig_DuplicateAddressNamespacePath(t *testing.T) {
 	assert.Contains(t, result, syncConfig1)
 	assert.Contains(t, result, syncConfig2)
 }
+
+func TestAddSyncConfig_Bidirectional(t *testing.T) {
+	syncConfig := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1alpha1.ObjectMeta{
+			Name:      "pair",
+			Namespace: "namespace1",
+		},
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Source: &vault.VaultClient{
+				Address: "tenant1",
+			},
+			Dest: []*v1alpha1.StoreConfig{
+				{
+					Vault: &vault.VaultClient{
+						Address:   "tenant2",
+						Namespace: "namespace2",
+					},
+				},
+			},
+			Bidirectional: &v1alpha1.BidirectionalConfig{},
+		},
+	}
+
+	SyncConfigs = map[string]v1alpha1.VaultSecretSync{}
+	SyncMaps = make(map[TenantName]TenantSyncs)
+
+	assert.NoError(t, AddSyncConfig(syncConfig))
+
+	// events of both vaults are handled by the sync config
+	assert.Len(t, TenantNamespaceConfigs(event.VaultEvent{Address: "tenant1"}), 1)
+	assert.Len(t, TenantNamespaceConfigs(event.VaultEvent{Address: "tenant2", Namespace: "namespace2/"}), 1)
+
+	removeFromSyncMaps(syncConfig)
+	assert.Empty(t, TenantNamespaceConfigs(event.VaultEvent{Address: "tenant2", Namespace: "namespace2"}))
+}
@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MaxHeldEvents is the number of held events kept in the status of a sync
+// config. Once exceeded, held writes are replaced with a single full sync.
+const MaxHeldEvents = 100
+
+// ReleaseTrigger syncs a held event of the sync config. Overridden events
+// are synced regardless of the sync windows.
+var ReleaseTrigger func(ctx context.Context, cfg v1alpha1.VaultSecretSync, he v1alpha1.HeldEvent, override bool) error
+
+func heldEventKey(he v1alpha1.HeldEvent) string {
+	if he.Manual {
+		return "manual|" + he.Path
+	}
+	return "event|" + he.Path
+}
+
+// mergeHeldEvent merges the event into the held events, keeping one event
+// per path. As with coalesced events, the newer event determines the
+// operation, forced and drift checked syncs are kept, and the first held
+// time is kept. Returns false if the event was merged into a held event.
+func mergeHeldEvent(held []v1alpha1.HeldEvent, he v1alpha1.HeldEvent) ([]v1alpha1.HeldEvent, bool) {
+	k := heldEventKey(he)
+	for i, h := range held {
+		if heldEventKey(h) != k {
+			continue
+		}
+		if he.PendingDeletes && !h.PendingDeletes {
+			he.Operation = h.Operation
+		}
+		he.PendingDeletes = he.PendingDeletes && h.PendingDeletes
+		he.Force = he.Force || h.Force
+		he.DriftCheck = he.DriftCheck || h.DriftCheck
+		he.Time = h.Time
+		held[i] = he
+		return held, false
+	}
+	held = append(held, he)
+	if len(held) <= MaxHeldEvents {
+		return held, true
+	}
+	// a full sync writes the latest state of every secret, so only deletes
+	// need to be kept individually
+	full := v1alpha1.HeldEvent{Operation: string(logical.UpdateOperation), Manual: true, Time: held[0].Time}
+	var kept []v1alpha1.HeldEvent
+	for _, h := range held {
+		if h.Operation == string(logical.DeleteOperation) {
+			kept = append(kept, h)
+			continue
+		}
+		full.Force = full.Force || h.Force
+		full.DriftCheck = full.DriftCheck || h.DriftCheck
+	}
+	kept = append(kept, full)
+	if len(kept) > MaxHeldEvents {
+		log.WithField("action", "mergeHeldEvent").Warnf("dropping %d oldest held deletes", len(kept)-MaxHeldEvents)
+		kept = kept[len(kept)-MaxHeldEvents:]
+	}
+	return kept, true
+}
+
+// HoldEvent records a sync event held until the next sync window at next in
+// the status of the sync config, returning true if no event was held for
+// its path yet
+func HoldEvent(ctx context.Context, sc v1alpha1.VaultSecretSync, he v1alpha1.HeldEvent, next time.Time) (bool, error) {
+	if B == nil {
+		return false, nil
+	}
+	switch B.Type() {
+	case BackendTypeKubernetes:
+		return holdEventKube(ctx, sc, he, next)
+	default:
+		return false, nil
+	}
+}
+
+func holdEventKube(ctx context.Context, sc v1alpha1.VaultSecretSync, he v1alpha1.HeldEvent, next time.Time) (bool, error) {
+	l := log.WithFields(log.Fields{
+		"action":    "holdEventKube",
+		"namespace": sc.Namespace,
+		"name":      sc.Name,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	var added bool
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		s := &v1alpha1.VaultSecretSync{}
+		if err := Reconciler.Get(ctx, client.ObjectKey{Namespace: sc.Namespace, Name: sc.Name}, s); err != nil {
+			return err
+		}
+		s.Status.HeldEvents, added = mergeHeldEvent(s.Status.HeldEvents, he)
+		s.Status.NextSyncWindow = nil
+		if !next.IsZero() {
+			t := metav1.NewTime(next)
+			s.Status.NextSyncWindow = &t
+		}
+		s.Status.Status = string(SyncStatusHeld)
+		generation := sc.Generation
+		if generation == 0 {
+			generation = s.Generation
+		}
+		setSyncConditions(s, SyncStatusHeld, generation, false)
+		return Reconciler.Status().Update(ctx, s, client.FieldOwner("vault-secret-sync-controller"))
+	})
+	if err != nil {
+		l.Errorf("failed to hold event: %v", err)
+		return false, err
+	}
+	return added, nil
+}
+
+// releaseHeldEvents triggers a sync of every held event of the sync config
+// and clears them from its status. Overridden events are synced regardless
+// of the sync windows, and a full sync is triggered if no events are held.
+func releaseHeldEvents(ctx context.Context, r *VaultSecretSyncReconciler, vaultSecretSync *v1alpha1.VaultSecretSync, override bool) error {
+	l := log.WithFields(log.Fields{
+		"action":    "releaseHeldEvents",
+		"namespace": vaultSecretSync.Namespace,
+		"name":      vaultSecretSync.Name,
+		"override":  override,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	held := vaultSecretSync.Status.HeldEvents
+	if len(held) == 0 && override {
+		held = []v1alpha1.HeldEvent{{Operation: string(logical.UpdateOperation), Manual: true, Time: metav1.Now()}}
+	}
+	for _, he := range held {
+		if err := ReleaseTrigger(ctx, *vaultSecretSync, he, override); err != nil {
+			return err
+		}
+	}
+	l.WithField("heldEvents", len(held)).Debug("held events released")
+	if len(vaultSecretSync.Status.HeldEvents) == 0 && vaultSecretSync.Status.NextSyncWindow == nil {
+		return nil
+	}
+	vaultSecretSync.Status.HeldEvents = nil
+	vaultSecretSync.Status.NextSyncWindow = nil
+	return r.Status().Update(ctx, vaultSecretSync, client.FieldOwner("vault-secret-sync-controller"))
+}
@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeHeldEvent(t *testing.T) {
+	first := metav1.Now()
+	held, added := mergeHeldEvent(nil, v1alpha1.HeldEvent{Operation: string(logical.UpdateOperation), Path: "kv/app", Force: true, Time: first})
+	assert.True(t, added)
+
+	// a newer event on the same path replaces the operation, keeping the first held time
+	held, added = mergeHeldEvent(held, v1alpha1.HeldEvent{Operation: string(logical.DeleteOperation), Path: "kv/app", Time: metav1.Now()})
+	assert.False(t, added)
+	assert.Len(t, held, 1)
+	assert.Equal(t, string(logical.DeleteOperation), held[0].Operation)
+	assert.True(t, held[0].Force)
+	assert.Equal(t, first, held[0].Time)
+
+	// a manual sync is held separately from events on a path
+	held, added = mergeHeldEvent(held, v1alpha1.HeldEvent{Operation: string(logical.UpdateOperation), Path: "kv/app", Manual: true})
+	assert.True(t, added)
+	assert.Len(t, held, 2)
+}
+
+func TestMergeHeldEventOverflow(t *testing.T) {
+	var held []v1alpha1.HeldEvent
+	held, _ = mergeHeldEvent(held, v1alpha1.HeldEvent{Operation: string(logical.DeleteOperation), Path: "kv/gone"})
+	for i := 0; i < MaxHeldEvents; i++ {
+		held, _ = mergeHeldEvent(held, v1alpha1.HeldEvent{Operation: string(logical.UpdateOperation), Path: fmt.Sprintf("kv/app-%d", i), DriftCheck: i == 3})
+	}
+	// writes are collapsed into a full sync, deletes are kept
+	assert.Len(t, held, 2)
+	assert.Equal(t, "kv/gone", held[0].Path)
+	assert.Equal(t, string(logical.DeleteOperation), held[0].Operation)
+	assert.True(t, held[1].Manual)
+	assert.Empty(t, held[1].Path)
+	assert.True(t, held[1].DriftCheck)
+}
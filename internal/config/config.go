@@ -9,6 +9,7 @@ import (
 	"github.com/robertlestak/vault-secret-sync/internal/backend"
 	"github.com/robertlestak/vault-secret-sync/internal/queue"
 	"github.com/robertlestak/vault-secret-sync/internal/srvutils"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
@@ -35,10 +36,39 @@ type BackendConfig struct {
 }
 
 type OperatorConfig struct {
-	Enabled          *bool          `json:"enabled" yaml:"enabled"`
-	Backend          *BackendConfig `json:"backend" yaml:"backend"`
-	WorkerPoolSize   int            `json:"workerPoolSize" yaml:"workerPoolSize"`
-	NumSubscriptions int            `json:"numSubscriptions" yaml:"numSubscriptions"`
+	Enabled          *bool              `json:"enabled" yaml:"enabled"`
+	Backend          *BackendConfig     `json:"backend" yaml:"backend"`
+	WorkerPoolSize   int                `json:"workerPoolSize" yaml:"workerPoolSize"`
+	NumSubscriptions int                `json:"numSubscriptions" yaml:"numSubscriptions"`
+	ResyncInterval   string             `json:"resyncInterval" yaml:"resyncInterval"`
+	Retry            *RetryConfig       `json:"retry" yaml:"retry"`
+	SyncTimeout      string             `json:"syncTimeout" yaml:"syncTimeout"`
+	CallTimeout      string             `json:"callTimeout" yaml:"callTimeout"`
+	DrainTimeout     string             `json:"drainTimeout" yaml:"drainTimeout"`
+	Debounce         string             `json:"debounce" yaml:"debounce"`
+	Concurrency      *ConcurrencyConfig `json:"concurrency" yaml:"concurrency"`
+	// MaxDeletesPerSync is the default maximum number of destination secrets
+	// a single sync may delete. 0 disables the limit.
+	MaxDeletesPerSync   *int   `json:"maxDeletesPerSync" yaml:"maxDeletesPerSync"`
+	DeletionGracePeriod string `json:"deletionGracePeriod" yaml:"deletionGracePeriod"`
+}
+
+// ConcurrencyConfig configures the workers of each sync, and the limits of
+// store operations shared by all syncs. The embedded limit applies to all
+// drivers combined.
+type ConcurrencyConfig struct {
+	Workers      int `json:"workers" yaml:"workers"`
+	RegexWorkers int `json:"regexWorkers" yaml:"regexWorkers"`
+
+	driver.Limit `json:",inline" yaml:",inline"`
+	Drivers      map[driver.DriverName]driver.Limit `json:"drivers" yaml:"drivers"`
+}
+
+// RetryConfig is the default retry policy for failed syncs
+type RetryConfig struct {
+	MaxAttempts    int    `json:"maxAttempts" yaml:"maxAttempts"`
+	InitialBackoff string `json:"initialBackoff" yaml:"initialBackoff"`
+	MaxBackoff     string `json:"maxBackoff" yaml:"maxBackoff"`
 }
 
 type EmailNotificationConfig struct {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/robertlestak/vault-secret-sync/internal/backend"
 	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -142,3 +143,34 @@ func TestSetDefaults(t *testing.T) {
 	assert.NotNil(t, cfg.Operator.Backend)
 	assert.Equal(t, backend.BackendType("kubernetes"), cfg.Operator.Backend.Type)
 }
+
+func TestLoadFileConcurrency(t *testing.T) {
+	tempFile, err := os.CreateTemp("", "config.*.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.WriteString(`
+operator:
+  enabled: true
+  backend:
+    type: "kubernetes"
+  concurrency:
+    workers: 20
+    maxConcurrent: 50
+    requestsPerSecond: 100
+    drivers:
+      aws:
+        maxConcurrent: 10
+        requestsPerSecond: 20
+        burst: 40
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, tempFile.Close())
+
+	assert.NoError(t, LoadFile(tempFile.Name()))
+	cc := Config.Operator.Concurrency
+	assert.NotNil(t, cc)
+	assert.Equal(t, 20, cc.Workers)
+	assert.Equal(t, 50, cc.MaxConcurrent)
+	assert.Equal(t, float64(100), cc.RequestsPerSecond)
+	assert.Equal(t, driver.Limit{MaxConcurrent: 10, RequestsPerSecond: 20, Burst: 40}, cc.Drivers[driver.DriverNameAws])
+}
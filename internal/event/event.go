@@ -14,6 +14,22 @@ type VaultEvent struct {
 	Path      string            `json:"path"`
 	Operation logical.Operation `json:"operation"`
 	Manual    bool              `json:"manual"`
+	// DriftCheck compares destinations with the source before writing
+	DriftCheck bool `json:"driftCheck"`
+	// Force writes destinations even if their content is unchanged
+	Force bool `json:"force"`
+	// Attempt is the number of previous attempts to sync the event
+	Attempt int `json:"attempt"`
+	// PendingDeletes only runs the due pending deletes of the sync config
+	PendingDeletes bool `json:"pendingDeletes,omitempty"`
+	// IgnoreSyncWindow syncs the event even outside the sync windows
+	IgnoreSyncWindow bool `json:"ignoreSyncWindow,omitempty"`
+	// SourceVersion reads this KV v2 version of the source secrets rather
+	// than the version of spec.source
+	SourceVersion int `json:"sourceVersion,omitempty"`
+	// Rotation is the phase of the rotation of the source secret to run
+	// before syncing, if any
+	Rotation string `json:"rotation,omitempty"`
 }
 
 // AuditEvent contains a single AuditEvent as received by the operator
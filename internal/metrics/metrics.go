@@ -64,6 +64,42 @@ var (
 		Name: "vault_secret_sync_events_processed",
 		Help: "The number of events processed",
 	})
+	DriftDetected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_drift_detected",
+		Help: "The number of destinations found to have drifted from the source",
+	}, []string{"namespace", "name", "driver"})
+	SyncRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_sync_retries",
+		Help: "The number of failed syncs scheduled for retry",
+	}, []string{"namespace", "name"})
+	DeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_dead_letters",
+		Help: "The number of sync events which failed permanently or exhausted their retries",
+	}, []string{"namespace", "name"})
+	PrunedSecrets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_pruned_secrets",
+		Help: "The number of orphaned destination secrets deleted",
+	}, []string{"namespace", "name", "driver"})
+	DeletesHalted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_deletes_halted",
+		Help: "The number of syncs halted because they would delete more destination secrets than allowed",
+	}, []string{"namespace", "name"})
+	PendingDeletes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vault_secret_sync_pending_deletes",
+		Help: "The number of destination secret deletes waiting out the deletion grace period",
+	}, []string{"namespace", "name"})
+	EventsCoalesced = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "vault_secret_sync_events_coalesced",
+		Help: "The number of events coalesced into a pending event for the same path",
+	})
+	SyncTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_sync_timeouts",
+		Help: "The number of syncs which exceeded their timeout",
+	}, []string{"namespace", "name"})
+	StoreCallTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_secret_sync_store_call_timeouts",
+		Help: "The number of store operations which exceeded the call timeout",
+	}, []string{"driver"})
 	ManualSyncRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "vault_secret_sync_manual_sync_requests",
 		Help: "The number of manual sync requests",
@@ -98,6 +134,15 @@ func init() {
 	prometheus.MustRegister(SyncErrors)
 	prometheus.MustRegister(SyncsTotal)
 	prometheus.MustRegister(SyncStatus)
+	prometheus.MustRegister(DriftDetected)
+	prometheus.MustRegister(SyncRetries)
+	prometheus.MustRegister(DeadLetters)
+	prometheus.MustRegister(PrunedSecrets)
+	prometheus.MustRegister(DeletesHalted)
+	prometheus.MustRegister(PendingDeletes)
+	prometheus.MustRegister(EventsCoalesced)
+	prometheus.MustRegister(SyncTimeouts)
+	prometheus.MustRegister(StoreCallTimeouts)
 }
 
 func NewServiceHealth() *ServiceHealth {
@@ -54,6 +54,29 @@ func (u *UnboundedChannel) Receive(ctx context.Context) (interface{}, error) {
 	}
 }
 
+// PushFront returns an item to the front of the queue, e.g. when it was
+// received but could not be delivered
+func (u *UnboundedChannel) PushFront(v interface{}) {
+	u.mu.Lock()
+	u.list.PushFront(v)
+	u.mu.Unlock()
+	select {
+	case u.ready <- struct{}{}:
+	default:
+	}
+}
+
+// DrainAll removes and returns every item in the queue without blocking
+func (u *UnboundedChannel) DrainAll() []interface{} {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	var items []interface{}
+	for u.list.Len() > 0 {
+		items = append(items, u.list.Remove(u.list.Front()))
+	}
+	return items
+}
+
 // Len returns the current length of the queue
 func (u *UnboundedChannel) Len() int {
 	u.mu.Lock()
@@ -239,3 +239,21 @@ func TestUnboundedChannel_HighLoad(t *testing.T) {
 		t.Errorf("Expected %d total messages, got %d", totalEvents, totalReceived)
 	}
 }
+
+func TestUnboundedChannel_PushFrontDrainAll(t *testing.T) {
+	uc := NewUnboundedChannel()
+	uc.Send("b")
+	uc.Send("c")
+	uc.PushFront("a")
+
+	items := uc.DrainAll()
+	if fmt.Sprint(items) != "[a b c]" {
+		t.Errorf("Expected [a b c], got %v", items)
+	}
+	if uc.Len() != 0 {
+		t.Errorf("Expected length 0, got %d", uc.Len())
+	}
+	if items := uc.DrainAll(); len(items) != 0 {
+		t.Errorf("Expected no items, got %v", items)
+	}
+}
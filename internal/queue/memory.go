@@ -47,8 +47,12 @@ func (q *MemoryQueue) Publish(ctx context.Context, item event.VaultEvent) error
 		"driver": "memory",
 	})
 	l.Trace("start")
-	q.newEvents <- item
-	return nil
+	select {
+	case q.newEvents <- item:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (q *MemoryQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error) {
@@ -59,13 +63,30 @@ func (q *MemoryQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, err
 	l.Trace("start")
 	ch := make(chan event.VaultEvent)
 	go func() {
-		for v := range q.newEvents {
-			ch <- v
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v := <-q.newEvents:
+				select {
+				case ch <- v:
+				case <-ctx.Done():
+					l.Warn("dropping event received during shutdown")
+					return
+				}
+			}
 		}
 	}()
 	return ch, nil
 }
 
+// Requeue does nothing, as the memory queue only exists within the process
+// and its events are lost once the process exits
+func (q *MemoryQueue) Requeue(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
 func (q *MemoryQueue) eventClearer() {
 	l := log.WithFields(log.Fields{
 		"action": "eventClearer",
@@ -23,6 +23,7 @@ type NATSQueue struct {
 	eventsMutex gosync.Mutex
 	nc          *nats.Conn
 	eventQueue  *UnboundedChannel
+	consumers   gosync.WaitGroup
 }
 
 func NewNATSQueue() *NATSQueue {
@@ -97,7 +98,7 @@ func (q *NATSQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error
 	out := make(chan event.VaultEvent)
 
 	// Subscribe to NATS and send events to the unbounded queue
-	_, err := q.nc.Subscribe(q.Subject, func(m *nats.Msg) {
+	sub, err := q.nc.Subscribe(q.Subject, func(m *nats.Msg) {
 		var e event.VaultEvent
 		if err := json.Unmarshal(m.Data, &e); err != nil {
 			l.Errorf("error unmarshalling event: %v", err)
@@ -110,27 +111,23 @@ func (q *NATSQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error
 		return nil, fmt.Errorf("failed to subscribe to NATS: %v", err)
 	}
 
-	// Start the event distributor
+	// stop receiving messages once the subscription is stopped
+	q.consumers.Add(1)
 	go func() {
-		defer close(out)
-		for {
-			evt, err := q.eventQueue.Receive(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					return
-				}
-				l.Errorf("error receiving from queue: %v", err)
-				continue
-			}
-
-			select {
-			case out <- evt.(event.VaultEvent):
-			case <-ctx.Done():
-				return
-			}
+		defer q.consumers.Done()
+		<-ctx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			l.Errorf("error unsubscribing: %v", err)
 		}
 	}()
 
+	// Start the event distributor
+	q.consumers.Add(1)
+	go func() {
+		defer q.consumers.Done()
+		distribute(ctx, l, q.eventQueue, out)
+	}()
+
 	return out, nil
 }
 
@@ -139,6 +136,10 @@ func (q *NATSQueue) Push(evt event.VaultEvent) error {
 	return nil
 }
 
+func (q *NATSQueue) Requeue(ctx context.Context) (int, error) {
+	return requeuePending(ctx, &q.consumers, q.eventQueue, q.Publish)
+}
+
 func (q *NATSQueue) eventClearer() {
 	l := log.WithFields(log.Fields{
 		"action": "eventClearer",
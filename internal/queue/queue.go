@@ -3,6 +3,8 @@ package queue
 import (
 	"context"
 	"errors"
+	"fmt"
+	gosync "sync"
 	"time"
 
 	"github.com/robertlestak/vault-secret-sync/internal/event"
@@ -33,6 +35,63 @@ type Queue interface {
 	SeenEvent(string)
 	EventSeen(string) bool
 	Ping() error
+	// Requeue publishes the events which were received by stopped
+	// subscriptions, or pushed locally, but not yet processed, so that
+	// another replica can process them. It returns the number of events
+	// requeued. Subscriptions must be stopped by cancelling their context
+	// before requeueing.
+	Requeue(ctx context.Context) (int, error)
+}
+
+// requeuePending waits for the consumers of the queue to stop, then
+// publishes the events remaining in its local buffer
+func requeuePending(ctx context.Context, consumers *gosync.WaitGroup, pending *UnboundedChannel, publish func(context.Context, event.VaultEvent) error) (int, error) {
+	done := make(chan struct{})
+	go func() {
+		consumers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	var errs []error
+	n := 0
+	for _, v := range pending.DrainAll() {
+		if err := publish(ctx, v.(event.VaultEvent)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	if len(errs) > 0 {
+		return n, fmt.Errorf("errors: %v", errs)
+	}
+	return n, nil
+}
+
+// distribute delivers the events in the local buffer to out until ctx is
+// done. An event which could not be delivered is returned to the buffer.
+func distribute(ctx context.Context, l *log.Entry, pending *UnboundedChannel, out chan event.VaultEvent) {
+	defer close(out)
+	for {
+		evt, err := pending.Receive(ctx)
+		if err != nil {
+			if err == context.Canceled {
+				return
+			}
+			l.Errorf("error receiving from queue: %v", err)
+			continue
+		}
+
+		select {
+		case out <- evt.(event.VaultEvent):
+		case <-ctx.Done():
+			pending.PushFront(evt)
+			return
+		}
+	}
 }
 
 func NewQueue(t QueueType) (Queue, error) {
@@ -0,0 +1,76 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestDistributeReturnsUndelivered(t *testing.T) {
+	pending := NewUnboundedChannel()
+	pending.Send(event.VaultEvent{Path: "a"})
+	pending.Send(event.VaultEvent{Path: "b"})
+	out := make(chan event.VaultEvent)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		distribute(ctx, log.WithField("test", t.Name()), pending, out)
+		close(done)
+	}()
+
+	if evt := <-out; evt.Path != "a" {
+		t.Errorf("Expected event a, got %s", evt.Path)
+	}
+	// b is received from the buffer but never delivered
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	if _, ok := <-out; ok {
+		t.Error("Expected out to be closed")
+	}
+	items := pending.DrainAll()
+	if len(items) != 1 || items[0].(event.VaultEvent).Path != "b" {
+		t.Errorf("Expected event b to be returned to the buffer, got %v", items)
+	}
+}
+
+func TestRequeuePending(t *testing.T) {
+	pending := NewUnboundedChannel()
+	pending.Send(event.VaultEvent{Path: "a"})
+	pending.Send(event.VaultEvent{Path: "fail"})
+	pending.Send(event.VaultEvent{Path: "b"})
+	var published []string
+	publish := func(ctx context.Context, evt event.VaultEvent) error {
+		if evt.Path == "fail" {
+			return errors.New("publish failed")
+		}
+		published = append(published, evt.Path)
+		return nil
+	}
+
+	var consumers gosync.WaitGroup
+	consumers.Add(1)
+	// the buffer isn't requeued while the consumers are running
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := requeuePending(ctx, &consumers, pending, publish); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if pending.Len() != 3 {
+		t.Errorf("Expected length 3, got %d", pending.Len())
+	}
+
+	consumers.Done()
+	n, err := requeuePending(context.Background(), &consumers, pending, publish)
+	if err == nil {
+		t.Error("Expected publish error")
+	}
+	if n != 2 || len(published) != 2 || published[0] != "a" || published[1] != "b" {
+		t.Errorf("Expected a and b to be requeued, got %d %v", n, published)
+	}
+}
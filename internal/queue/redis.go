@@ -34,6 +34,7 @@ type RedisQueue struct {
 
 	client     *redis.Client
 	eventQueue *UnboundedChannel
+	consumers  gosync.WaitGroup
 }
 
 func NewRedisQueue() *RedisQueue {
@@ -125,14 +126,19 @@ func (q *RedisQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, erro
 	ch := make(chan event.VaultEvent)
 
 	// Redis consumer goroutine
+	q.consumers.Add(1)
 	go func() {
+		defer q.consumers.Done()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			default:
-				cmd := q.client.BLPop(0, "queue")
-				if cmd.Err() != nil {
+				// pop with a timeout so that the subscription can be stopped
+				cmd := q.client.BLPop(time.Second, "queue")
+				if cmd.Err() == redis.Nil {
+					continue
+				} else if cmd.Err() != nil {
 					l.Errorf("error in BLPOP: %v", cmd.Err())
 					time.Sleep(time.Second) // Back off on error
 					continue
@@ -151,24 +157,10 @@ func (q *RedisQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, erro
 	}()
 
 	// Event distributor goroutine
+	q.consumers.Add(1)
 	go func() {
-		defer close(ch)
-		for {
-			evt, err := q.eventQueue.Receive(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					return
-				}
-				l.Errorf("error receiving from queue: %v", err)
-				continue
-			}
-
-			select {
-			case ch <- evt.(event.VaultEvent):
-			case <-ctx.Done():
-				return
-			}
-		}
+		defer q.consumers.Done()
+		distribute(ctx, l, q.eventQueue, ch)
 	}()
 
 	return ch, nil
@@ -179,6 +171,10 @@ func (q *RedisQueue) Push(evt event.VaultEvent) error {
 	return nil
 }
 
+func (q *RedisQueue) Requeue(ctx context.Context) (int, error) {
+	return requeuePending(ctx, &q.consumers, q.eventQueue, q.Publish)
+}
+
 func (q *RedisQueue) eventClearer() {
 	l := log.WithFields(log.Fields{
 		"action": "eventClearer",
@@ -28,6 +28,7 @@ type SQSQueue struct {
 	eventsMutex gosync.Mutex
 
 	eventQueue *UnboundedChannel
+	consumers  gosync.WaitGroup
 }
 
 func NewSQSQueue() *SQSQueue {
@@ -99,7 +100,9 @@ func (q *SQSQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error)
 	out := make(chan event.VaultEvent)
 
 	// SQS consumer goroutine
+	q.consumers.Add(1)
 	go func() {
+		defer q.consumers.Done()
 		for {
 			select {
 			case <-ctx.Done():
@@ -112,7 +115,9 @@ func (q *SQSQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error)
 					WaitTimeSeconds:     0,
 				})
 
-				if err != nil {
+				if ctx.Err() != nil {
+					return
+				} else if err != nil {
 					l.Errorf("error receiving message: %v", err)
 					time.Sleep(time.Second) // Back off on error
 					continue
@@ -130,8 +135,9 @@ func (q *SQSQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error)
 
 				q.eventQueue.Send(e)
 
-				// Delete the message after processing
-				_, err = q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				// Delete the message once buffered. The buffer is requeued if the
+				// subscription is stopped, so the delete must not be cancelled.
+				_, err = q.client.DeleteMessage(context.WithoutCancel(ctx), &sqs.DeleteMessageInput{
 					QueueUrl:      &q.Url,
 					ReceiptHandle: result.Messages[0].ReceiptHandle,
 				})
@@ -143,24 +149,10 @@ func (q *SQSQueue) Subscribe(ctx context.Context) (chan event.VaultEvent, error)
 	}()
 
 	// Event distributor goroutine
+	q.consumers.Add(1)
 	go func() {
-		defer close(out)
-		for {
-			evt, err := q.eventQueue.Receive(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					return
-				}
-				l.Errorf("error receiving from queue: %v", err)
-				continue
-			}
-
-			select {
-			case out <- evt.(event.VaultEvent):
-			case <-ctx.Done():
-				return
-			}
-		}
+		defer q.consumers.Done()
+		distribute(ctx, l, q.eventQueue, out)
 	}()
 
 	return out, nil
@@ -171,6 +163,10 @@ func (q *SQSQueue) Push(evt event.VaultEvent) error {
 	return nil
 }
 
+func (q *SQSQueue) Requeue(ctx context.Context) (int, error) {
+	return requeuePending(ctx, &q.consumers, q.eventQueue, q.Publish)
+}
+
 func (q *SQSQueue) eventClearer() {
 	l := log.WithFields(log.Fields{
 		"action": "eventClearer",
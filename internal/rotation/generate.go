@@ -0,0 +1,97 @@
+package rotation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+)
+
+const (
+	defaultPasswordLength  = 32
+	defaultPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	defaultRSABits         = 4096
+)
+
+// generate returns the new values of the keys of the secret written by the
+// generator of the key
+func generate(ctx context.Context, namespace string, k v1alpha1.RotationKey) (map[string]string, error) {
+	switch k.Generator {
+	case v1alpha1.RotationGeneratorPassword:
+		p, err := password(k.Length, k.Charset)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{k.Key: p}, nil
+	case v1alpha1.RotationGeneratorHTTP:
+		if k.HTTP == nil {
+			return nil, errors.New("http is required by the http generator")
+		}
+		v, err := Request(ctx, namespace, *k.HTTP, nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{k.Key: v}, nil
+	case v1alpha1.RotationGeneratorRSA:
+		bits := k.Bits
+		if bits == 0 {
+			bits = defaultRSABits
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		return keypair(k, key, &key.PublicKey)
+	case v1alpha1.RotationGeneratorEd25519:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return keypair(k, key, pub)
+	}
+	return nil, errors.New("invalid generator " + string(k.Generator))
+}
+
+// password returns a random password of the length drawn from the charset
+func password(length int, charset string) (string, error) {
+	if length == 0 {
+		length = defaultPasswordLength
+	}
+	if charset == "" {
+		charset = defaultPasswordCharset
+	}
+	chars := []rune(charset)
+	max := big.NewInt(int64(len(chars)))
+	p := make([]rune, length)
+	for i := range p {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		p[i] = chars[n.Int64()]
+	}
+	return string(p), nil
+}
+
+// keypair returns the PKCS#8 private key and PKIX public key of a keypair,
+// PEM encoded
+func keypair(k v1alpha1.RotationKey, key, pub any) (map[string]string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pubDer, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		k.Key:        string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		publicKey(k): string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer})),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package rotation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/kubesecret"
+)
+
+const defaultRequestTimeout = 30 * time.Second
+
+// maxResponseSize bounds the response bodies read from rotation endpoints
+const maxResponseSize = 1 << 20
+
+// Request makes the HTTP request, returning the field of the JSON response,
+// or the whole response body if no field is set. body is sent if the
+// request sets no body of its own. Responses other than 2xx are errors.
+func Request(ctx context.Context, namespace string, h v1alpha1.RotationHTTP, body []byte) (string, error) {
+	timeout := defaultRequestTimeout
+	if h.Timeout != nil && h.Timeout.Duration > 0 {
+		timeout = h.Timeout.Duration
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	method := h.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	if h.Body != "" {
+		body = []byte(h.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	if h.HeaderSecret != "" {
+		sc, err := kubesecret.GetSecret(ctx, namespace, h.HeaderSecret)
+		if err != nil {
+			return "", fmt.Errorf("failed to get header secret: %w", err)
+		}
+		for k, v := range sc {
+			req.Header.Set(k, string(v))
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	rb, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the response may hold a generated value, so it is not included
+		return "", fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	if h.Field == "" {
+		return strings.TrimSpace(string(rb)), nil
+	}
+	return responseField(rb, h.Field)
+}
+
+// responseField returns the dot separated field of the JSON response
+func responseField(rb []byte, field string) (string, error) {
+	var v any
+	if err := json.Unmarshal(rb, &v); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	for _, f := range strings.Split(field, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("field %q not found in response", field)
+		}
+		if v, ok = m[f]; !ok {
+			return "", fmt.Errorf("field %q not found in response", field)
+		}
+	}
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case nil:
+		return "", fmt.Errorf("field %q is null in response", field)
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,130 @@
+// Package rotation generates the rotated values of the keys of a source
+// secret, and schedules its rotations
+package rotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	// the operator image may not ship a time zone database
+	_ "time/tzdata"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/syncwindow"
+)
+
+// PreviousSuffix is appended to a rotated key to hold its previous value
+// during the overlap after a rotation
+const PreviousSuffix = "_previous"
+
+// Next returns the first rotation of the schedule strictly after t
+func Next(rc v1alpha1.RotationConfig, t time.Time) (time.Time, error) {
+	s, err := syncwindow.Parse(rc.Schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("rotation: %v", err)
+	}
+	loc := time.UTC
+	if rc.TimeZone != "" {
+		if loc, err = time.LoadLocation(rc.TimeZone); err != nil {
+			return time.Time{}, fmt.Errorf("rotation: invalid timeZone: %v", err)
+		}
+	}
+	return s.Next(t.In(loc)), nil
+}
+
+// Validate returns an error if the rotation is invalid
+func Validate(rc v1alpha1.RotationConfig) error {
+	if _, err := Next(rc, time.Now()); err != nil {
+		return err
+	}
+	if len(rc.Keys) == 0 {
+		return errors.New("rotation: at least one key is required")
+	}
+	keys := make(map[string]bool)
+	for i, k := range rc.Keys {
+		if k.Key == "" {
+			return fmt.Errorf("rotation: keys[%d]: key is required", i)
+		}
+		switch k.Generator {
+		case v1alpha1.RotationGeneratorPassword:
+			if k.Length < 0 {
+				return fmt.Errorf("rotation: keys[%d]: length must be positive", i)
+			}
+		case v1alpha1.RotationGeneratorHTTP:
+			if k.HTTP == nil || k.HTTP.URL == "" {
+				return fmt.Errorf("rotation: keys[%d]: http.url is required by the http generator", i)
+			}
+		case v1alpha1.RotationGeneratorRSA:
+			if k.Bits != 0 && k.Bits < 2048 {
+				return fmt.Errorf("rotation: keys[%d]: bits must be at least 2048", i)
+			}
+		case v1alpha1.RotationGeneratorEd25519:
+		default:
+			return fmt.Errorf("rotation: keys[%d]: invalid generator %q", i, k.Generator)
+		}
+		for _, key := range rotatedKeys(k) {
+			if keys[key] {
+				return fmt.Errorf("rotation: keys[%d]: key %q is rotated more than once", i, key)
+			}
+			keys[key] = true
+		}
+	}
+	if rc.Verify != nil && rc.Verify.URL == "" {
+		return errors.New("rotation: verify.url is required")
+	}
+	return nil
+}
+
+// publicKey returns the key the public key of a keypair is written to
+func publicKey(k v1alpha1.RotationKey) string {
+	if k.PublicKey != "" {
+		return k.PublicKey
+	}
+	return k.Key + "_public"
+}
+
+// rotatedKeys returns the keys of the secret written by the generator of the key
+func rotatedKeys(k v1alpha1.RotationKey) []string {
+	switch k.Generator {
+	case v1alpha1.RotationGeneratorRSA, v1alpha1.RotationGeneratorEd25519:
+		return []string{k.Key, publicKey(k)}
+	}
+	return []string{k.Key}
+}
+
+// Rotate returns the secret with new values generated for each rotated key.
+// If overlap is true, the previous value of each rotated key is kept as
+// <key>_previous. The secret itself is not modified.
+func Rotate(ctx context.Context, namespace string, rc v1alpha1.RotationConfig, secret map[string]any, overlap bool) (map[string]any, error) {
+	rotated := EndOverlap(rc, secret)
+	for _, k := range rc.Keys {
+		values, err := generate(ctx, namespace, k)
+		if err != nil {
+			return nil, fmt.Errorf("rotation: %s: %w", k.Key, err)
+		}
+		for key, v := range values {
+			if prev, ok := secret[key]; ok && overlap {
+				rotated[key+PreviousSuffix] = prev
+			}
+			rotated[key] = v
+		}
+	}
+	return rotated, nil
+}
+
+// EndOverlap returns the secret without the previous values of the rotated
+// keys. The secret itself is not modified.
+func EndOverlap(rc v1alpha1.RotationConfig, secret map[string]any) map[string]any {
+	out := make(map[string]any, len(secret))
+	for k, v := range secret {
+		out[k] = v
+	}
+	for _, k := range rc.Keys {
+		for _, key := range rotatedKeys(k) {
+			delete(out, key+PreviousSuffix)
+		}
+	}
+	return out
+}
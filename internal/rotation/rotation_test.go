@@ -0,0 +1,131 @@
+package rotation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	rc := v1alpha1.RotationConfig{Schedule: "0 3 * * *", TimeZone: "Europe/London"}
+	next, err := Next(rc, time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 7, 2, 2, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestValidate(t *testing.T) {
+	key := v1alpha1.RotationKey{Key: "password", Generator: v1alpha1.RotationGeneratorPassword}
+	tests := []struct {
+		name string
+		rc   v1alpha1.RotationConfig
+		err  string
+	}{
+		{name: "valid", rc: v1alpha1.RotationConfig{Schedule: "@daily", Keys: []v1alpha1.RotationKey{key}}},
+		{name: "schedule", rc: v1alpha1.RotationConfig{Schedule: "daily", Keys: []v1alpha1.RotationKey{key}}, err: "invalid cron expression"},
+		{name: "no keys", rc: v1alpha1.RotationConfig{Schedule: "@daily"}, err: "at least one key"},
+		{name: "http", rc: v1alpha1.RotationConfig{Schedule: "@daily", Keys: []v1alpha1.RotationKey{
+			{Key: "token", Generator: v1alpha1.RotationGeneratorHTTP},
+		}}, err: "http.url is required"},
+		{name: "duplicate", rc: v1alpha1.RotationConfig{Schedule: "@daily", Keys: []v1alpha1.RotationKey{
+			{Key: "tls", Generator: v1alpha1.RotationGeneratorEd25519},
+			{Key: "tls_public", Generator: v1alpha1.RotationGeneratorPassword},
+		}}, err: "rotated more than once"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.rc)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	rc := v1alpha1.RotationConfig{Keys: []v1alpha1.RotationKey{
+		{Key: "password", Generator: v1alpha1.RotationGeneratorPassword, Length: 16, Charset: "ab"},
+		{Key: "signing", Generator: v1alpha1.RotationGeneratorEd25519},
+	}}
+	secret := map[string]any{"user": "app", "password": "old", "password_previous": "older"}
+
+	rotated, err := Rotate(ctx, "", rc, secret, true)
+	require.NoError(t, err)
+	assert.Equal(t, "app", rotated["user"])
+	assert.Regexp(t, "^[ab]{16}$", rotated["password"])
+	assert.Equal(t, "old", rotated["password_previous"])
+	// keys which did not exist have no previous value
+	assert.NotContains(t, rotated, "signing_previous")
+	assert.Equal(t, "old", secret["password"], "the secret is not modified")
+
+	block, _ := pem.Decode([]byte(rotated["signing"].(string)))
+	require.NotNil(t, block)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	block, _ = pem.Decode([]byte(rotated["signing_public"].(string)))
+	require.NotNil(t, block)
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, key.(ed25519.PrivateKey).Public(), pub)
+
+	// without an overlap previous values are removed
+	rotated, err = Rotate(ctx, "", rc, rotated, false)
+	require.NoError(t, err)
+	assert.NotContains(t, rotated, "password_previous")
+	assert.Equal(t, map[string]any{"user": "app", "password": "old"}, EndOverlap(rc, secret))
+}
+
+func TestGenerateRSA(t *testing.T) {
+	values, err := generate(context.Background(), "", v1alpha1.RotationKey{Key: "tls", Generator: v1alpha1.RotationGeneratorRSA, Bits: 2048, PublicKey: "tls.pub"})
+	require.NoError(t, err)
+	block, _ := pem.Decode([]byte(values["tls"]))
+	require.NotNil(t, block)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, 2048, key.(*rsa.PrivateKey).N.BitLen())
+	assert.Contains(t, values["tls.pub"], "PUBLIC KEY")
+}
+
+func TestRequest(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer admin" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":{"key":"k-123","id":7}}`))
+	}))
+	defer server.Close()
+
+	h := v1alpha1.RotationHTTP{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer admin"}, Field: "data.key"}
+	v, err := Request(ctx, "", h, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "k-123", v)
+
+	h.Field = "data"
+	v, err = Request(ctx, "", h, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"key":"k-123","id":7}`, v)
+
+	h.Field = "data.missing"
+	_, err = Request(ctx, "", h, nil)
+	assert.ErrorContains(t, err, `field "data.missing" not found`)
+
+	h.Headers = nil
+	_, err = Request(ctx, "", h, nil)
+	assert.ErrorContains(t, err, "status 401")
+	assert.False(t, strings.Contains(err.Error(), "k-123"))
+}
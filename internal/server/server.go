@@ -4,8 +4,10 @@ import (
 	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	gosync "sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -29,6 +31,12 @@ var (
 		logical.UpdateOperation,
 		logical.DeleteOperation,
 	}
+
+	// srv is the running event server
+	srv   *http.Server
+	srvMu gosync.Mutex
+	// events tracks the events which are being processed
+	events gosync.WaitGroup
 )
 
 func shouldFilterVaultEvent(event event.AuditEvent) bool {
@@ -212,7 +220,11 @@ func handleVaultEvents(w http.ResponseWriter, r *http.Request) {
 		}
 		// using a background context to ensure the event is processed even if the request is cancelled
 		ctx := context.Background()
-		go processVaultEvent(ctx, ve)
+		events.Add(1)
+		go func() {
+			defer events.Done()
+			processVaultEvent(ctx, ve)
+		}()
 	}
 	l.Trace("end")
 }
@@ -231,15 +243,55 @@ func EventServer(port int, tlsConfig *srvutils.TLSConfig) {
 	} else {
 		l.Infof("starting server on port %d", port)
 	}
-	srv, err := srvutils.SetupServer(r, port, tlsConfig)
+	s, err := srvutils.SetupServer(r, port, tlsConfig)
 	if err != nil {
 		l.Fatal(err)
 	}
+	srvMu.Lock()
+	srv = s
+	srvMu.Unlock()
 	metrics.RegisterServiceHealth("events", metrics.ServiceHealthStatusOK)
 	if tlsConfig != nil && tlsConfig.Cert != "" && tlsConfig.Key != "" {
-		l.Fatal(srv.ListenAndServeTLS(tlsConfig.Cert, tlsConfig.Key))
+		err = s.ListenAndServeTLS(tlsConfig.Cert, tlsConfig.Key)
 	} else {
-		l.Fatal(srv.ListenAndServe())
+		err = s.ListenAndServe()
 	}
 	metrics.RegisterServiceHealth("events", metrics.ServiceHealthStatusCritical)
+	if !errors.Is(err, http.ErrServerClosed) {
+		l.Fatal(err)
+	}
+	l.Info("server stopped")
+}
+
+// Shutdown stops accepting events, and waits until the events which were
+// already received have been queued, or the context is done
+func Shutdown(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action": "Shutdown",
+		"pkg":    "server",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	srvMu.Lock()
+	s := srv
+	srvMu.Unlock()
+	if s == nil {
+		return nil
+	}
+	if err := s.Shutdown(ctx); err != nil {
+		l.WithError(err).Error("failed to stop server")
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		events.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		l.Warn("timed out waiting for events to be queued")
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,307 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pairSide is a side of a secret synced bidirectionally
+type pairSide struct {
+	client  SyncClient
+	path    string
+	version int
+	updated time.Time
+}
+
+// validateBidirectional returns an error if the sync config is bidirectional
+// and cannot be synced both ways
+func validateBidirectional(sc v1alpha1.VaultSecretSync) error {
+	if sc.Spec.Bidirectional == nil {
+		return nil
+	}
+	if sc.Spec.Source == nil {
+		return errors.New("bidirectional requires a vault source")
+	}
+	if len(sc.Spec.Dest) != 1 || sc.Spec.Dest[0] == nil || sc.Spec.Dest[0].Vault == nil {
+		return errors.New("bidirectional requires a single vault destination")
+	}
+	d := sc.Spec.Dest[0]
+	switch {
+	case sc.Spec.Transforms != nil || d.Transforms != nil:
+		return errors.New("bidirectional cannot be set with transforms")
+	case d.SplitKeys:
+		return errors.New("bidirectional cannot be set with splitKeys")
+	case sc.Spec.Source.Merge || d.Vault.Merge || d.Vault.Replicate:
+		return errors.New("bidirectional cannot be set with merge or replicate")
+	case sc.Spec.Prune != nil && *sc.Spec.Prune:
+		return errors.New("bidirectional cannot be set with prune")
+	}
+	_, _, err := reversePaths(sc.Spec.Source.Path, d.Vault.Path)
+	return err
+}
+
+// reversePaths returns the source and destination paths of the reverse of a
+// bidirectional sync. Exact paths are swapped, and a source path ending in
+// the capture group (.*) paired with a destination path ending in $1 is
+// mirrored, e.g. kv/a/(.*) to kv/b/$1 is reversed to kv/b/(.*) to kv/a/$1.
+func reversePaths(sourcePath, destPath string) (string, string, error) {
+	exact := func(p string) bool {
+		return p != "" && !isRegexPath(p) && !isTemplatePath(p) && !strings.Contains(p, "$")
+	}
+	if exact(sourcePath) && exact(destPath) {
+		return destPath, sourcePath, nil
+	}
+	sp, sok := strings.CutSuffix(sourcePath, "(.*)")
+	dp, dok := strings.CutSuffix(destPath, "$1")
+	if sok && dok && exact(sp) && exact(dp) {
+		return dp + "(.*)", sp + "$1", nil
+	}
+	return "", "", errors.New("bidirectional requires exact paths, or a source path ending in (.*) and a destination path ending in $1")
+}
+
+// reverseConfig returns the sync config syncing the destination of the
+// bidirectional sync config to its source
+func reverseConfig(sc v1alpha1.VaultSecretSync) (v1alpha1.VaultSecretSync, error) {
+	if err := validateBidirectional(sc); err != nil {
+		return sc, err
+	}
+	rev := *sc.DeepCopy()
+	d := rev.Spec.Dest[0]
+	src, dst := rev.Spec.Source, d.Vault
+	sourcePath, destPath, err := reversePaths(src.Path, dst.Path)
+	if err != nil {
+		return sc, err
+	}
+	src.Path, dst.Path = destPath, sourcePath
+	src.Version, dst.Version = 0, 0
+	rev.Spec.Source, d.Vault = dst, src
+	return rev, nil
+}
+
+// reversedEvent returns true if the event is of the destination of the
+// bidirectional sync config, rather than its source. Manual events are of
+// the source.
+func reversedEvent(sc v1alpha1.VaultSecretSync, evt event.VaultEvent) bool {
+	if sc.Spec.Bidirectional == nil || evt.Manual || sourceEvent(sc, evt) {
+		return false
+	}
+	rev, err := reverseConfig(sc)
+	return err == nil && sourceEvent(rev, evt)
+}
+
+// pairJob returns the job of the event, reversed if the event is of the
+// destination of a bidirectional sync
+func pairJob(j SyncJob) SyncJob {
+	if !reversedEvent(j.SyncConfig, j.VaultEvent) {
+		return j
+	}
+	rev, err := reverseConfig(j.SyncConfig)
+	if err != nil {
+		return j
+	}
+	j.SyncConfig = rev
+	j.reversed = true
+	return j
+}
+
+// bidirectionalConflictPolicy returns the conflict policy of the bidirectional sync
+func bidirectionalConflictPolicy(j SyncJob) v1alpha1.BidirectionalConflictPolicy {
+	if b := j.SyncConfig.Spec.Bidirectional; b != nil && b.ConflictPolicy != "" {
+		return b.ConflictPolicy
+	}
+	return v1alpha1.BidirectionalConflictLastWriterWins
+}
+
+// pairDirection returns the side of the secret to sync from and the side to
+// sync to, or nil if neither side changed since the last sync. conflict is
+// true if both sides changed. A side deleted since the last sync is not
+// written again unless the other side changed. A forced sync syncs the
+// source to the destination, which also resolves a halted conflict.
+func pairDirection(j SyncJob, src, dst *pairSide, last backend.InventoryRecord) (from, to *pairSide, conflict bool) {
+	srcChanged := src.version != last.SourceVersion
+	dstChanged := dst.version != last.DestVersion
+	switch {
+	case src.version == 0 && dst.version == 0:
+		return nil, nil, false
+	case j.VaultEvent.Force && src.version > 0:
+		return src, dst, false
+	case dst.version == 0 && last.DestVersion > 0 && !srcChanged,
+		src.version == 0 && last.SourceVersion > 0 && !dstChanged:
+		return nil, nil, false
+	case dst.version == 0:
+		return src, dst, false
+	case src.version == 0:
+		return dst, src, false
+	case srcChanged && dstChanged:
+		return nil, nil, true
+	case srcChanged:
+		return src, dst, false
+	case dstChanged:
+		return dst, src, false
+	}
+	return nil, nil, false
+}
+
+// resolveConflict returns the side of the secret which wins the conflict
+// under the conflict policy of the job, or nil if the sync halts
+func resolveConflict(j SyncJob, src, dst *pairSide) (from, to *pairSide) {
+	switch bidirectionalConflictPolicy(j) {
+	case v1alpha1.BidirectionalConflictSource:
+		return src, dst
+	case v1alpha1.BidirectionalConflictDest:
+		return dst, src
+	case v1alpha1.BidirectionalConflictHalt:
+		return nil, nil
+	}
+	if dst.updated.After(src.updated) {
+		return dst, src
+	}
+	return src, dst
+}
+
+// readPairSecret reads the secret of the side, bypassing the source cache as
+// both sides of a pair may have the same path
+func readPairSecret(ctx context.Context, s *pairSide) ([]byte, error) {
+	var secret []byte
+	err := limited(ctx, s.client, func(ctx context.Context) error {
+		var err error
+		secret, err = s.client.GetSecret(ctx, s.path)
+		return err
+	})
+	return secret, driver.Classify(s.client, err)
+}
+
+// pairVersion reads the current version of the secret of the side
+func pairVersion(ctx context.Context, s *pairSide) error {
+	v, ok := s.client.(driver.Versioned)
+	if !ok {
+		return driver.Permanent(fmt.Errorf("bidirectional is not supported by the %s driver", s.client.Driver()))
+	}
+	err := limited(ctx, s.client, func(ctx context.Context) error {
+		var err error
+		s.version, s.updated, err = v.CurrentVersion(ctx, s.path)
+		return err
+	})
+	return driver.Classify(s.client, err)
+}
+
+// syncPair syncs the side of the secret which changed since the last sync to
+// the other side. Once both sides are in sync their versions are recorded,
+// so that an event of the write, which would otherwise sync the secret back,
+// finds them in sync. Conflicts, where both sides changed, are resolved by
+// the conflict policy unless both sides hold the same data.
+func syncPair(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePath, destPath string) error {
+	l := log.WithFields(log.Fields{
+		"action":      "syncPair",
+		"source.Path": sourcePath,
+		"dest.Path":   destPath,
+		"reversed":    j.reversed,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	fail := func(err error) error {
+		return handleCreateOneError(ctx, err, j, dest, sourcePath, destPath)
+	}
+	if shouldSuspend(ctx, j, dest, sourcePath, destPath) {
+		return nil
+	}
+
+	// src and dst are the source and destination of the sync config, even
+	// if the job is reversed
+	src, dst := &pairSide{client: source, path: sourcePath}, &pairSide{client: dest, path: destPath}
+	if j.reversed {
+		src, dst = dst, src
+	}
+	for _, s := range []*pairSide{src, dst} {
+		if err := pairVersion(ctx, s); err != nil {
+			return fail(err)
+		}
+	}
+	last, _ := j.inventory.Get(destInventoryKey(dst.client, dst.path))
+	from, to, conflict := pairDirection(j, src, dst, last)
+
+	var payload []byte
+	if conflict {
+		sp, err := readPairSecret(ctx, src)
+		if err != nil {
+			return fail(err)
+		}
+		dp, err := readPairSecret(ctx, dst)
+		if err != nil {
+			return fail(err)
+		}
+		if diffSecretKeys(sp, dp).Empty() {
+			l.Debug("both sides changed to the same data")
+		} else if from, to = resolveConflict(j, src, dst); from == nil {
+			backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "SyncConflict",
+				fmt.Sprintf("%s and %s both changed since the last sync, sync halted", src.path, dst.path))
+			return fail(driver.Permanent(fmt.Errorf("conflict: %s and %s both changed since the last sync, force-sync to sync the source to the destination", src.path, dst.path)))
+		} else {
+			backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "SyncConflict",
+				fmt.Sprintf("%s and %s both changed since the last sync, %s: %s overwritten by policy %s", src.path, dst.path, to.client.Driver(), to.path, bidirectionalConflictPolicy(j)))
+			payload = sp
+			if from == dst {
+				payload = dp
+			}
+		}
+	}
+
+	if from != nil {
+		if payload == nil {
+			var err error
+			if payload, err = readPairSecret(ctx, from); err != nil {
+				return fail(err)
+			}
+		}
+		if isDryRun(j) {
+			planWrite(ctx, j, to.client, to.path, payload)
+			return nil
+		}
+		cancelPendingDelete(ctx, j, to.client, to.path)
+		// both sides are peers, so neither is stamped with an owner
+		err := limited(ctx, to.client, func(ctx context.Context) error {
+			_, err := to.client.WriteSecret(ctx, metav1.ObjectMeta{}, to.path, payload)
+			return err
+		})
+		if err != nil {
+			return handleCreateOneError(ctx, driver.Classify(to.client, err), j, to.client, from.path, to.path)
+		}
+		if err := pairVersion(ctx, to); err != nil {
+			// the versions are recorded by the next sync, which finds both
+			// sides changed with the same data
+			l.WithError(err).Warn("unable to read version after write")
+			return handleCreateOneSuccess(ctx, j, to.client, from.path, to.path)
+		}
+	} else if !conflict && last.SourceVersion == src.version && last.DestVersion == dst.version {
+		l.Debug("secret in sync")
+		j.destinations.success(dest, destPath, "")
+		return nil
+	}
+
+	if isDryRun(j) {
+		return nil
+	}
+	j.inventory.Put(backend.InventoryRecord{
+		Driver:        string(dst.client.Driver()),
+		Location:      destLocation(dst.client),
+		Path:          dst.path,
+		SourcePath:    src.path,
+		SourceVersion: src.version,
+		DestVersion:   dst.version,
+	})
+	j.destinations.success(dest, destPath, "")
+	if from == nil {
+		return nil
+	}
+	return handleCreateOneSuccess(ctx, j, to.client, from.path, to.path)
+}
@@ -0,0 +1,169 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// versionedTestClient is a test store which versions its secrets like a kv
+// v2 engine, bumping the version of a secret on each write
+type versionedTestClient struct {
+	manualRegexTestClient
+	versions map[string]int
+	updated  map[string]time.Time
+}
+
+func (c *versionedTestClient) CurrentVersion(_ context.Context, path string) (int, time.Time, error) {
+	return c.versions[path], c.updated[path], nil
+}
+
+func (c *versionedTestClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secret []byte) ([]byte, error) {
+	c.set(path, secret, time.Now())
+	return c.manualRegexTestClient.WriteSecret(ctx, meta, path, secret)
+}
+
+// set writes a new version of the secret outside of a sync
+func (c *versionedTestClient) set(path string, secret []byte, updated time.Time) {
+	if c.secrets == nil {
+		c.secrets = make(map[string][]byte)
+		c.versions = make(map[string]int)
+		c.updated = make(map[string]time.Time)
+	}
+	c.secrets[path] = secret
+	c.versions[path]++
+	c.updated[path] = updated
+}
+
+func bidirectionalTestConfig(sourcePath, destPath string) v1alpha1.VaultSecretSync {
+	return v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Name: "pair", Namespace: "test"},
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Source: &vault.VaultClient{Address: "https://a:8200", Path: sourcePath},
+			Dest: []*v1alpha1.StoreConfig{
+				{Vault: &vault.VaultClient{Address: "https://b:8200", Path: destPath}},
+			},
+			Bidirectional: &v1alpha1.BidirectionalConfig{},
+		},
+	}
+}
+
+func TestReversePaths(t *testing.T) {
+	tests := []struct {
+		source, dest       string
+		revSource, revDest string
+		err                bool
+	}{
+		{source: "kv/app", dest: "kv/copy", revSource: "kv/copy", revDest: "kv/app"},
+		{source: "kv/a/(.*)", dest: "kv/b/$1", revSource: "kv/b/(.*)", revDest: "kv/a/$1"},
+		{source: "kv/a/(.*)", dest: "kv/b/{{ .Name }}", err: true},
+		{source: "kv/(.*)/app", dest: "kv/$1/app", err: true},
+		{source: "kv/a/*", dest: "kv/b", err: true},
+	}
+	for _, tt := range tests {
+		s, d, err := reversePaths(tt.source, tt.dest)
+		if tt.err {
+			assert.Error(t, err, tt.source)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.revSource, s)
+		assert.Equal(t, tt.revDest, d)
+	}
+}
+
+func TestValidateBidirectional(t *testing.T) {
+	assert.NoError(t, validateBidirectional(bidirectionalTestConfig("kv/app", "kv/app")))
+
+	sc := bidirectionalTestConfig("kv/app", "kv/app")
+	sc.Spec.Dest = append(sc.Spec.Dest, &v1alpha1.StoreConfig{Vault: &vault.VaultClient{Path: "kv/other"}})
+	assert.ErrorContains(t, validateBidirectional(sc), "single vault destination")
+
+	sc = bidirectionalTestConfig("kv/app", "kv/app")
+	sc.Spec.Dest[0].SplitKeys = true
+	assert.ErrorContains(t, validateBidirectional(sc), "splitKeys")
+
+	prune := true
+	sc = bidirectionalTestConfig("kv/app", "kv/app")
+	sc.Spec.Prune = &prune
+	assert.ErrorContains(t, validateBidirectional(sc), "prune")
+}
+
+func TestNeedsSyncBidirectional(t *testing.T) {
+	sc := bidirectionalTestConfig("kv/a/(.*)", "kv/b/$1")
+	evt := event.VaultEvent{Address: "https://b:8200", Path: "kv/data/b/app", Operation: logical.UpdateOperation}
+	assert.True(t, NeedsSync(sc, evt))
+
+	j := pairJob(SyncJob{VaultEvent: evt, SyncConfig: sc})
+	assert.True(t, j.reversed)
+	assert.Equal(t, "https://b:8200", j.SyncConfig.Spec.Source.Address)
+	assert.Equal(t, "kv/b/(.*)", j.SyncConfig.Spec.Source.Path)
+	assert.Equal(t, "kv/a/$1", j.SyncConfig.Spec.Dest[0].Vault.Path)
+
+	// events of the source are not reversed
+	evt.Address, evt.Path = "https://a:8200", "kv/data/a/app"
+	assert.False(t, pairJob(SyncJob{VaultEvent: evt, SyncConfig: sc}).reversed)
+
+	// events of the destination are ignored by one way syncs
+	sc.Spec.Bidirectional = nil
+	evt.Address, evt.Path = "https://b:8200", "kv/data/b/app"
+	assert.False(t, NeedsSync(sc, evt))
+}
+
+func TestSyncPair(t *testing.T) {
+	ctx := context.Background()
+	a, b := &versionedTestClient{}, &versionedTestClient{}
+	j := pruneTestJob(t, "pair", false, false)
+	j.SyncConfig.Spec.Prune = nil
+	j.SyncConfig.Spec.Bidirectional = &v1alpha1.BidirectionalConfig{}
+	// sync runs the job of an event of the source, or of the destination
+	sync := func(reversed bool) error {
+		jj := j
+		jj.reversed = reversed
+		if reversed {
+			return syncPair(ctx, jj, b, a, "kv/b", "kv/a")
+		}
+		return syncPair(ctx, jj, a, b, "kv/a", "kv/b")
+	}
+
+	// a new source secret is synced to the destination
+	a.set("kv/a", []byte(`{"v":"1"}`), time.Now())
+	assert.NoError(t, sync(false))
+	assert.Equal(t, `{"v":"1"}`, string(b.secrets["kv/b"]))
+
+	// the event of the write finds both sides in sync
+	assert.NoError(t, sync(true))
+	assert.Equal(t, 1, a.versions["kv/a"])
+	assert.Equal(t, 1, b.versions["kv/b"])
+
+	// a change to the destination is synced back to the source
+	b.set("kv/b", []byte(`{"v":"2"}`), time.Now())
+	assert.NoError(t, sync(true))
+	assert.Equal(t, `{"v":"2"}`, string(a.secrets["kv/a"]))
+	assert.NoError(t, sync(false))
+	assert.Equal(t, 2, b.versions["kv/b"])
+
+	// the last writer wins a conflict by default
+	now := time.Now()
+	a.set("kv/a", []byte(`{"v":"a"}`), now)
+	b.set("kv/b", []byte(`{"v":"b"}`), now.Add(time.Second))
+	assert.NoError(t, sync(false))
+	assert.Equal(t, `{"v":"b"}`, string(a.secrets["kv/a"]))
+
+	// conflicts halt the sync under the halt policy, until it is forced
+	j.SyncConfig.Spec.Bidirectional.ConflictPolicy = v1alpha1.BidirectionalConflictHalt
+	a.set("kv/a", []byte(`{"v":"a"}`), now)
+	b.set("kv/b", []byte(`{"v":"b"}`), now)
+	assert.ErrorContains(t, sync(true), "conflict")
+	assert.Equal(t, `{"v":"a"}`, string(a.secrets["kv/a"]))
+	j.VaultEvent.Force = true
+	assert.NoError(t, sync(false))
+	assert.Equal(t, `{"v":"a"}`, string(b.secrets["kv/b"]))
+}
@@ -24,7 +24,8 @@ func clientGenerator(ctx context.Context, j SyncJob) (*SyncClients, error) {
 	if err != nil {
 		l.Error(err)
 		j.Error = err
-		return nil, err
+		// the sync config is invalid and will fail again if retried
+		return nil, driver.Permanent(err)
 	}
 
 	cerr := scs.CreateClients(ctx)
@@ -147,6 +148,19 @@ func InitSyncConfigClients(sc v1alpha1.VaultSecretSync) (*SyncClients, error) {
 		l.WithField("dest", scs.Dest).Trace("added dest")
 
 	}
+	scs.stores = destStores(sc, scs)
+	if scs.splits, err = splitDests(sc, scs); err != nil {
+		l.Error(err)
+		return nil, err
+	}
+	if err := validateBidirectional(sc); err != nil {
+		l.Error(err)
+		return nil, err
+	}
+	if err := validateRotation(sc); err != nil {
+		l.Error(err)
+		return nil, err
+	}
 	l.Trace("end")
 	return scs, nil
 }
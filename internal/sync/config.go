@@ -3,7 +3,10 @@ package sync
 import (
 	"context"
 	"sync"
+	"text/template"
 
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -12,6 +15,11 @@ import (
 type SyncClients struct {
 	Source SyncClient
 	Dest   []SyncClient
+
+	// stores holds the spec config of each destination
+	stores map[SyncClient]*v1alpha1.StoreConfig
+	// splits holds the key name template of each destination which splits keys
+	splits map[SyncClient]*template.Template
 }
 
 // CreateClients will create a new Vault client for both the Source and Destination
@@ -28,13 +36,13 @@ func (sc *SyncClients) CreateClients(ctx context.Context) error {
 	cerr := sc.Source.Init(ctx)
 	if cerr != nil {
 		l.Error(cerr)
-		return cerr
+		return driver.Classify(sc.Source, cerr)
 	}
 	l.Trace("create client")
 	for _, d := range sc.Dest {
 		if cerr := d.Init(ctx); cerr != nil {
 			l.Error(cerr)
-			return cerr
+			return driver.Classify(d, cerr)
 		}
 	}
 	l.Trace("end")
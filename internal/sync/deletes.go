@@ -0,0 +1,277 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// DefaultMaxDeletesPerSync is the maximum number of destination secrets
+	// a single sync may delete when a VaultSecretSync does not set
+	// spec.maxDeletesPerSync. 0 disables the limit, so by default only the
+	// syncs which set spec.maxDeletesPerSync are limited.
+	DefaultMaxDeletesPerSync int
+	// DefaultDeletionGracePeriod delays deletes of destination secrets when
+	// a VaultSecretSync does not set spec.deletionGracePeriod. 0 deletes immediately.
+	DefaultDeletionGracePeriod time.Duration
+)
+
+// maxDeletes returns the maximum number of deletes of a sync of the sync config
+func maxDeletes(s v1alpha1.VaultSecretSync) int {
+	if s.Spec.MaxDeletesPerSync != nil {
+		return *s.Spec.MaxDeletesPerSync
+	}
+	return DefaultMaxDeletesPerSync
+}
+
+// deletionGracePeriod returns the deletion grace period of the sync config
+func deletionGracePeriod(s v1alpha1.VaultSecretSync) time.Duration {
+	if s.Spec.DeletionGracePeriod != nil {
+		return s.Spec.DeletionGracePeriod.Duration
+	}
+	return DefaultDeletionGracePeriod
+}
+
+// deleteBudget counts the deletes of a single sync job. Deletes which dry
+// run destinations only plan are counted separately, so that they do not
+// halt the deletes of the other destinations.
+type deleteBudget struct {
+	mu      sync.Mutex
+	used    int
+	planned int
+}
+
+// reserveDeletes reserves n deletes of the job before any of them are made,
+// so that a sync which would delete more destination secrets than allowed
+// is halted before deleting anything. A dry run only reports that it would
+// be halted, and a suspended sync deletes nothing.
+func reserveDeletes(ctx context.Context, j SyncJob, n int) error {
+	l := log.WithFields(log.Fields{
+		"action":    "reserveDeletes",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+		"deletes":   n,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	limit := maxDeletes(j.SyncConfig)
+	if n <= 0 || isSuspended(j) || limit <= 0 || j.deletes == nil {
+		return nil
+	}
+	j.deletes.mu.Lock()
+	defer j.deletes.mu.Unlock()
+	used := &j.deletes.used
+	if isDryRun(j) {
+		used = &j.deletes.planned
+	}
+	if *used+n <= limit {
+		*used += n
+		return nil
+	}
+	msg := fmt.Sprintf("sync would delete %d destination secrets, more than maxDeletesPerSync %d, no secrets were deleted", *used+n, limit)
+	if isDryRun(j) {
+		l.Warn("dry run: " + msg)
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", v1alpha1.ConditionReasonDeletesHalted, "dry run: "+msg)
+		return nil
+	}
+	l.Warn(msg)
+	metrics.DeletesHalted.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Inc()
+	backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", v1alpha1.ConditionReasonDeletesHalted, msg)
+	return driver.Permanent(fmt.Errorf("deletes halted: %s", msg))
+}
+
+// deletesFrom returns true if deletes of source secrets are synced to the
+// destination of the job, which is neither filtered nor excluded by syncDelete
+func deletesFrom(j SyncJob, dest SyncClient) bool {
+	return syncsDelete(j) && !shouldFilterSecret(j, j.SyncConfig.Spec.Source.GetPath(), dest.GetPath())
+}
+
+// reserveDestDeletes reserves the deletes of each destination, as seen by
+// the destination, before any of them are made
+func reserveDestDeletes(ctx context.Context, j SyncJob, dests []SyncClient, deletes map[SyncClient]int) error {
+	for _, d := range dests {
+		if err := reserveDeletes(ctx, destJob(j, d), deletes[d]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSecret deletes the destination secret, or, if the sync config has a
+// deletion grace period, records a pending delete of the secret which is
+// run once the grace period has passed
+func deleteSecret(ctx context.Context, j SyncJob, dest SyncClient, destPath string) error {
+	grace := deletionGracePeriod(j.SyncConfig)
+	if grace <= 0 {
+		err := limited(ctx, dest, func(ctx context.Context) error {
+			return dest.DeleteSecret(ctx, destPath)
+		})
+		if err != nil {
+			return err
+		}
+		recordDelete(j, dest, destPath)
+		return nil
+	}
+	if j.inventory == nil {
+		return driver.Permanent(fmt.Errorf("unable to record pending delete of %s: inventory unavailable", destPath))
+	}
+	key := destInventoryKey(dest, destPath)
+	r, ok := j.inventory.Get(key)
+	if !ok {
+		r = backend.InventoryRecord{Driver: string(dest.Driver()), Location: destLocation(dest), Path: destPath}
+	}
+	log.WithFields(log.Fields{
+		"action":   "deleteSecret",
+		"driver":   r.Driver,
+		"destPath": destPath,
+		"grace":    grace,
+	}).Debug("recording pending delete")
+	j.inventory.PutTombstone(backend.Tombstone{InventoryRecord: r, DeleteAfter: time.Now().Add(grace)})
+	recordDelete(j, dest, destPath)
+	return nil
+}
+
+// cancelPendingDelete cancels the pending delete of a destination secret
+// which is written again
+func cancelPendingDelete(ctx context.Context, j SyncJob, dest SyncClient, destPath string) {
+	if !j.inventory.CancelTombstone(destInventoryKey(dest, destPath)) {
+		return
+	}
+	log.WithFields(log.Fields{
+		"action":   "cancelPendingDelete",
+		"driver":   dest.Driver(),
+		"destPath": destPath,
+	}).Info("pending delete cancelled")
+	backend.WriteEvent(
+		ctx,
+		j.SyncConfig.Namespace,
+		j.SyncConfig.Name,
+		"Normal",
+		"PendingDeleteCancelled",
+		fmt.Sprintf("pending delete of %s: %s cancelled, the secret is desired again", dest.Driver(), destPath),
+	)
+}
+
+// processPendingDeletes deletes the destination secrets whose deletion
+// grace period has passed. Suspended and dry run destinations keep their
+// pending deletes.
+func processPendingDeletes(ctx context.Context, scs *SyncClients, j SyncJob) error {
+	l := log.WithFields(log.Fields{
+		"action":    "processPendingDeletes",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	if j.inventory == nil {
+		return nil
+	}
+	now := time.Now()
+	var due []backend.InventoryRecord
+	for _, t := range j.inventory.Tombstones() {
+		if now.Before(t.DeleteAfter) {
+			continue
+		}
+		if rj := recordJob(j, scs, t.InventoryRecord); isSuspended(rj) || isDryRun(rj) {
+			continue
+		}
+		due = append(due, t.InventoryRecord)
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	l = l.WithField("due", len(due))
+	clients := destClients(scs)
+	due, removed := unmanaged(clients, due)
+	if len(removed) > 0 {
+		// pending deletes are only made in stores which are still in the spec
+		msg := fmt.Sprintf("dropping %d pending deletes of stores no longer in spec.dest, delete them by hand: %s", len(removed), orphanSummary(removed))
+		l.Warn(msg)
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "PendingDeleteDropped", msg)
+		for _, r := range removed {
+			j.inventory.CancelTombstone(r.Key())
+		}
+	}
+	var errs []error
+	var deleted []backend.InventoryRecord
+	for _, r := range due {
+		d := clients[r.StoreKey()]
+		err := limited(ctx, d, func(ctx context.Context) error {
+			return d.DeleteSecret(ctx, r.Path)
+		})
+		if err != nil {
+			l.WithError(err).WithFields(log.Fields{"driver": r.Driver, "path": r.Path}).Error("failed to delete secret")
+			errs = append(errs, driver.Classify(d, err))
+			continue
+		}
+		j.inventory.CancelTombstone(r.Key())
+		deleted = append(deleted, r)
+	}
+	if len(deleted) > 0 {
+		backend.WriteEvent(
+			ctx,
+			j.SyncConfig.Namespace,
+			j.SyncConfig.Name,
+			"Normal",
+			"Deleted",
+			fmt.Sprintf("deleted %d destination secrets after the deletion grace period: %s", len(deleted), orphanSummary(deleted)),
+		)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	return nil
+}
+
+// finishRemoval releases a sync config being deleted with delete-on-removal
+// once its destination secrets, including pending deletes, are deleted
+func finishRemoval(ctx context.Context, j SyncJob) {
+	if !backend.Removing(j.SyncConfig) || len(j.inventory.Tombstones()) > 0 {
+		return
+	}
+	if j.VaultEvent.Operation != logical.DeleteOperation && !j.VaultEvent.PendingDeletes {
+		return
+	}
+	l := log.WithFields(log.Fields{
+		"action":    "finishRemoval",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+	})
+	// the inventory is saved before the sync config, which owns it, is released
+	if err := j.inventory.Save(ctx); err != nil {
+		l.WithError(err).Error("failed to save inventory")
+		return
+	}
+	if err := backend.FinishRemoval(ctx, j.SyncConfig); err != nil {
+		l.WithError(err).Error("failed to release sync config")
+	}
+}
+
+// PendingDeletesTrigger runs the due pending deletes of the sync config
+func PendingDeletesTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync) error {
+	l := log.WithFields(log.Fields{"action": "PendingDeletesTrigger"})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l = l.WithFields(log.Fields{"name": name})
+	l.Debug("pending deletes trigger")
+	evt := event.VaultEvent{
+		SyncName:       name,
+		Operation:      logical.UpdateOperation,
+		Manual:         true,
+		PendingDeletes: true,
+	}
+	return queue.Q.Push(evt)
+}
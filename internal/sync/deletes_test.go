@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReserveDeletes(t *testing.T) {
+	ctx := context.Background()
+	limit := 2
+	j := pruneTestJob(t, "reserve", true, false)
+	j.SyncConfig.Spec.MaxDeletesPerSync = &limit
+	j.deletes = &deleteBudget{}
+
+	assert.NoError(t, reserveDeletes(ctx, j, 1))
+	err := reserveDeletes(ctx, j, 2)
+	assert.Error(t, err)
+	assert.True(t, driver.IsPermanent(err))
+	// a halted reservation does not use the budget
+	assert.NoError(t, reserveDeletes(ctx, j, 1))
+
+	dryRun := true
+	j.SyncConfig.Spec.DryRun = &dryRun
+	assert.NoError(t, reserveDeletes(ctx, j, 10))
+
+	unlimited := 0
+	j.SyncConfig.Spec.DryRun = nil
+	j.SyncConfig.Spec.MaxDeletesPerSync = &unlimited
+	assert.NoError(t, reserveDeletes(ctx, j, 1000))
+}
+
+func TestReserveDeletesUnset(t *testing.T) {
+	j := pruneTestJob(t, "reserve-unset", true, false)
+	j.deletes = &deleteBudget{}
+	// without spec.maxDeletesPerSync deletes are not limited by default
+	assert.Equal(t, 0, maxDeletes(j.SyncConfig))
+	assert.NoError(t, reserveDeletes(context.Background(), j, 1000))
+}
+
+func TestPruneOrphansHaltsOverLimit(t *testing.T) {
+	ctx := context.Background()
+	limit := 1
+	dest := &manualRegexTestClient{}
+	scs := &SyncClients{Dest: []SyncClient{dest}}
+
+	j := pruneTestJob(t, "prune-halt", true, false)
+	j.SyncConfig.Spec.MaxDeletesPerSync = &limit
+	j.deletes = &deleteBudget{}
+	recordWrite(j, dest, "kv/a", "kv/a", "h")
+	recordWrite(j, dest, "kv/b", "kv/b", "h")
+	assert.Error(t, pruneOrphans(ctx, scs, j))
+	assert.Empty(t, dest.deletes)
+	assert.Len(t, j.inventory.Records(), 2)
+}
+
+func TestDeletionGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+	dest := &manualRegexTestClient{}
+	scs := &SyncClients{Source: source, Dest: []SyncClient{dest}}
+
+	j := pruneTestJob(t, "grace", true, false)
+	j.SyncConfig.Spec.DeletionGracePeriod = &metav1.Duration{Duration: time.Hour}
+	j.deletes = &deleteBudget{}
+	recordWrite(j, dest, "kv/a", "kv/a", "h")
+	recordWrite(j, dest, "kv/b", "kv/b", "h")
+
+	// orphans are tombstoned rather than deleted
+	assert.NoError(t, pruneOrphans(ctx, scs, j))
+	assert.Empty(t, dest.deletes)
+	assert.Empty(t, j.inventory.Records())
+	assert.Len(t, j.inventory.Tombstones(), 2)
+
+	// a tombstoned secret which is written again is no longer deleted
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/a"))
+	tombstones := j.inventory.Tombstones()
+	assert.Len(t, tombstones, 1)
+	assert.Equal(t, "kv/b", tombstones[0].Path)
+
+	// pending deletes are only run once due
+	assert.NoError(t, processPendingDeletes(ctx, scs, j))
+	assert.Empty(t, dest.deletes)
+	j.inventory.ClearTombstones()
+	j.inventory.PutTombstone(backend.Tombstone{InventoryRecord: tombstones[0].InventoryRecord, DeleteAfter: time.Now().Add(-time.Minute)})
+	assert.NoError(t, processPendingDeletes(ctx, scs, j))
+	assert.Equal(t, []string{"kv/b"}, dest.deletes)
+	assert.Empty(t, j.inventory.Tombstones())
+}
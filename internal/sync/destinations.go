@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// destinationTracker records the outcome for each destination secret
+// touched by a single sync job, and the destination secrets it should write
+type destinationTracker struct {
+	mu      sync.Mutex
+	results map[string]backend.DestinationResult
+	desired map[string]bool
+}
+
+func newDestinationTracker() *destinationTracker {
+	return &destinationTracker{
+		results: make(map[string]backend.DestinationResult),
+		desired: make(map[string]bool),
+	}
+}
+
+// want records that the destination secret is desired by the sync config,
+// whether or not it is written by this sync
+func (t *destinationTracker) want(dest SyncClient, destPath string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.desired[destInventoryKey(dest, destPath)] = true
+}
+
+// isDesired returns true if the destination secret with the inventory key is desired
+func (t *destinationTracker) isDesired(key string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.desired[key]
+}
+
+func (t *destinationTracker) record(dest SyncClient, destPath string, r backend.DestinationResult) {
+	if t == nil {
+		return
+	}
+	r.Status.Driver = string(dest.Driver())
+	r.Status.Location = destLocation(dest)
+	r.Status.Path = destPath
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.results[destInventoryKey(dest, destPath)] = r
+}
+
+// success records a successful write of the payload with the given hash
+func (t *destinationTracker) success(dest SyncClient, destPath, hash string) {
+	now := metav1.Now()
+	t.record(dest, destPath, backend.DestinationResult{
+		Status: v1alpha1.DestinationStatus{
+			LastAttemptTime: now,
+			LastSuccessTime: now,
+			Hash:            hash,
+		},
+	})
+}
+
+// failure records a failed write or delete of the destination secret
+func (t *destinationTracker) failure(dest SyncClient, destPath string, err error) {
+	t.record(dest, destPath, backend.DestinationResult{
+		Status: v1alpha1.DestinationStatus{
+			LastAttemptTime: metav1.Now(),
+			LastError:       err.Error(),
+		},
+	})
+}
+
+// deleted records the deletion of the destination secret
+func (t *destinationTracker) deleted(dest SyncClient, destPath string) {
+	t.record(dest, destPath, backend.DestinationResult{Deleted: true})
+}
+
+// fullSync returns true if the job syncs every destination secret of the
+// sync config. Drift checks only write drifted destinations, and suspended
+// syncs write nothing, so they are not full syncs.
+func fullSync(j SyncJob) bool {
+	return j.VaultEvent.Manual && !j.VaultEvent.PendingDeletes && !j.SyncConfig.Suspended() && driftPolicy(j) == "" &&
+		(j.VaultEvent.Operation == logical.CreateOperation || j.VaultEvent.Operation == logical.UpdateOperation)
+}
+
+// syncResult returns the recorded destinations of the job. A successful
+// full sync writes every destination, so its result replaces the status
+// of destinations which are no longer synced. Syncs with suspended or dry
+// run destinations do not write every destination.
+func syncResult(j SyncJob, err error) *backend.SyncResult {
+	if j.destinations == nil {
+		return nil
+	}
+	j.destinations.mu.Lock()
+	defer j.destinations.mu.Unlock()
+	r := &backend.SyncResult{
+		Complete: err == nil && !j.SyncConfig.PartialDryRun() && !j.SyncConfig.PartialSuspend() && fullSync(j),
+		TimedOut: driver.IsTimeout(err),
+		Plan:     planResult(j, err),
+	}
+	if j.inventory != nil {
+		r.PendingDeletes = j.inventory.Tombstones()
+		metrics.PendingDeletes.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Set(float64(len(r.PendingDeletes)))
+	}
+	for _, d := range j.destinations.results {
+		r.Destinations = append(r.Destinations, d)
+	}
+	return r
+}
@@ -0,0 +1,230 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDebounce is the window in which events for the same path are
+// coalesced into a single sync. 0 disables debouncing.
+var DefaultDebounce time.Duration
+
+// eventKey is the key by which events are ordered and coalesced. The KV v2
+// data and metadata paths of a secret share a key, so that a write and a
+// metadata update or delete of the secret are synced in order. Events which
+// target a single sync config are keyed separately from audit events for
+// the same path.
+func eventKey(evt event.VaultEvent) string {
+	return strings.Join([]string{evt.Address, evt.Namespace, strippedPath(evt.Path), evt.SyncName}, "|")
+}
+
+// coalesce merges a newer event for the same key into a pending event. The
+// newer event determines the operation, as the source is read when the sync
+// runs, while forced, drift checked and overridden syncs are kept, and the
+// lowest retry attempt wins. A pending source version is kept unless the
+// newer event pins another, so that a rollback is not replaced by a sync
+// of the latest version. Pending deletes are run by every sync, so an event
+// which only runs them is dropped in favour of a full event. A pending
+// rotation is kept, as a rotation also syncs every destination.
+func coalesce(pending, evt event.VaultEvent) event.VaultEvent {
+	if evt.Rotation == "" {
+		evt.Rotation = pending.Rotation
+	}
+	if evt.SourceVersion == 0 {
+		evt.SourceVersion = pending.SourceVersion
+	}
+	evt.Force = evt.Force || pending.Force
+	evt.DriftCheck = evt.DriftCheck || pending.DriftCheck
+	evt.IgnoreSyncWindow = evt.IgnoreSyncWindow || pending.IgnoreSyncWindow
+	if evt.PendingDeletes && !pending.PendingDeletes {
+		evt.Operation = pending.Operation
+	}
+	evt.PendingDeletes = evt.PendingDeletes && pending.PendingDeletes
+	if pending.Attempt < evt.Attempt {
+		evt.Attempt = pending.Attempt
+	}
+	return evt
+}
+
+// keyState is the state of the events of a single key
+type keyState struct {
+	// running is true while an event of the key is being synced
+	running bool
+	// pending is the event waiting to be synced, if any
+	pending *event.VaultEvent
+	// due is true once the debounce window of the pending event has passed
+	due   bool
+	timer *time.Timer
+}
+
+// dispatcher serializes the events of each key, so that an event is only
+// synced once the previous event for the same key is complete, and
+// coalesces the events received for a key within the debounce window, or
+// while an event of the key is being synced, into a single event. At most
+// capacity events are held at once, pending or running, so that once the
+// workers are saturated events are left in the queue rather than held in
+// memory, where they would be lost on a crash.
+type dispatcher struct {
+	mu       sync.Mutex
+	debounce time.Duration
+	keys     map[string]*keyState
+	closed   bool
+	// slots holds a token for each event held by the dispatcher
+	slots chan struct{}
+	// ready are the events which can be synced
+	ready chan event.VaultEvent
+	// drained is cancelled once the dispatcher is closed and every event
+	// has been synced
+	drained context.Context
+	drain   context.CancelFunc
+}
+
+// newDispatcher returns a dispatcher holding at most capacity events, which
+// is at least 1
+func newDispatcher(debounce time.Duration, capacity int) *dispatcher {
+	if capacity < 1 {
+		capacity = 1
+	}
+	drained, drain := context.WithCancel(context.Background())
+	return &dispatcher{
+		debounce: debounce,
+		keys:     make(map[string]*keyState),
+		slots:    make(chan struct{}, capacity),
+		ready:    make(chan event.VaultEvent, capacity),
+		drained:  drained,
+		drain:    drain,
+	}
+}
+
+// coalescePending merges the event into the pending event of its key,
+// returning false if the key has no pending event. d.mu must be held.
+func (d *dispatcher) coalescePending(k string, evt event.VaultEvent) bool {
+	st, ok := d.keys[k]
+	if !ok || st.pending == nil {
+		return false
+	}
+	metrics.EventsCoalesced.Inc()
+	log.WithFields(log.Fields{
+		"action": "dispatcher.submit",
+		"key":    k,
+	}).Debug("coalescing event")
+	merged := coalesce(*st.pending, evt)
+	st.pending = &merged
+	return true
+}
+
+// submit adds an event to the dispatcher. Events coalesced into a pending
+// event are added at once, otherwise submit blocks until the dispatcher
+// has capacity for the event.
+func (d *dispatcher) submit(evt event.VaultEvent) {
+	k := eventKey(evt)
+	d.mu.Lock()
+	if d.coalescePending(k, evt) {
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+	d.slots <- struct{}{}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// another event of the key may have been submitted while waiting
+	if d.coalescePending(k, evt) {
+		<-d.slots
+		return
+	}
+	st, ok := d.keys[k]
+	if !ok {
+		st = &keyState{}
+		d.keys[k] = st
+	}
+	st.pending = &evt
+	if d.debounce <= 0 || d.closed {
+		st.due = true
+		d.dispatch(k, st)
+		return
+	}
+	// the window starts at the first event of a burst, so that a steady
+	// stream of events for a key is still synced every window
+	st.timer = time.AfterFunc(d.debounce, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		st.timer = nil
+		st.due = true
+		d.dispatch(k, st)
+	})
+}
+
+// dispatch makes the pending event of the key ready if it is due and no
+// other event of the key is running. Every ready event holds a slot, so
+// ready never blocks. d.mu must be held.
+func (d *dispatcher) dispatch(k string, st *keyState) {
+	if st.running || !st.due || st.pending == nil {
+		return
+	}
+	evt := *st.pending
+	st.pending = nil
+	st.due = false
+	st.running = true
+	d.ready <- evt
+}
+
+// done marks the running event of the key complete, releasing its slot and
+// dispatching the next event of the key if it is due
+func (d *dispatcher) done(evt event.VaultEvent) {
+	k := eventKey(evt)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	<-d.slots
+	st, ok := d.keys[k]
+	if !ok {
+		return
+	}
+	st.running = false
+	if st.pending == nil {
+		delete(d.keys, k)
+		d.checkDrained()
+		return
+	}
+	d.dispatch(k, st)
+}
+
+// close stops waiting out the debounce windows and makes every pending
+// event ready. Events can no longer be received once close is called.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for k, st := range d.keys {
+		if st.timer != nil {
+			st.timer.Stop()
+			st.timer = nil
+		}
+		st.due = true
+		d.dispatch(k, st)
+	}
+	d.checkDrained()
+}
+
+// checkDrained signals the workers to exit once the dispatcher is closed
+// and empty. d.mu must be held.
+func (d *dispatcher) checkDrained() {
+	if d.closed && len(d.keys) == 0 {
+		d.drain()
+	}
+}
+
+// next returns the next ready event, or false once the dispatcher is drained
+func (d *dispatcher) next() (event.VaultEvent, bool) {
+	select {
+	case evt := <-d.ready:
+		return evt, true
+	case <-d.drained.Done():
+		return event.VaultEvent{}, false
+	}
+}
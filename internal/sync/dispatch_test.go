@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	pending := event.VaultEvent{Path: "kv/data/app", Operation: logical.UpdateOperation, Force: true, Attempt: 0, SourceVersion: 3}
+	evt := event.VaultEvent{Path: "kv/data/app", Operation: logical.DeleteOperation, DriftCheck: true, Attempt: 2}
+	c := coalesce(pending, evt)
+	assert.Equal(t, logical.Operation(logical.DeleteOperation), c.Operation)
+	assert.True(t, c.Force)
+	assert.True(t, c.DriftCheck)
+	assert.Equal(t, 0, c.Attempt)
+	// a pinned source version is kept unless the newer event pins another
+	assert.Equal(t, 3, c.SourceVersion)
+	evt.SourceVersion = 5
+	assert.Equal(t, 5, coalesce(pending, evt).SourceVersion)
+}
+
+func TestDispatcherSerializesKey(t *testing.T) {
+	d := newDispatcher(0, 10)
+	a := event.VaultEvent{Path: "kv/data/a", Operation: logical.CreateOperation}
+	b := event.VaultEvent{Path: "kv/data/b", Operation: logical.UpdateOperation}
+	d.submit(a)
+	d.submit(b)
+
+	first, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, a, first)
+	second, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, b, second)
+
+	// events received while a is running wait for it, and are coalesced
+	d.submit(event.VaultEvent{Path: "kv/data/a", Operation: logical.UpdateOperation})
+	d.submit(event.VaultEvent{Path: "kv/data/a", Operation: logical.DeleteOperation})
+	assert.Empty(t, d.ready)
+	d.done(first)
+	next, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, logical.Operation(logical.DeleteOperation), next.Operation)
+
+	d.done(second)
+	d.done(next)
+	assert.Empty(t, d.keys)
+
+	d.close()
+	_, ok = d.next()
+	assert.False(t, ok)
+}
+
+func TestDispatcherSerializesMetadata(t *testing.T) {
+	d := newDispatcher(0, 10)
+	write := event.VaultEvent{Path: "kv/data/app", Operation: logical.UpdateOperation}
+	d.submit(write)
+	first, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, write, first)
+
+	// a metadata delete of the secret waits for the write
+	d.submit(event.VaultEvent{Path: "kv/metadata/app", Operation: logical.DeleteOperation})
+	assert.Empty(t, d.ready)
+	d.done(first)
+	next, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, "kv/metadata/app", next.Path)
+	assert.Equal(t, logical.Operation(logical.DeleteOperation), next.Operation)
+	d.done(next)
+	assert.Empty(t, d.keys)
+}
+
+func TestDispatcherDebounce(t *testing.T) {
+	d := newDispatcher(50*time.Millisecond, 10)
+	d.submit(event.VaultEvent{Path: "kv/data/a", Operation: logical.CreateOperation})
+	d.submit(event.VaultEvent{Path: "kv/data/a", Operation: logical.UpdateOperation})
+	assert.Empty(t, d.ready)
+
+	evt, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, logical.Operation(logical.UpdateOperation), evt.Operation)
+	assert.Empty(t, d.ready)
+	d.done(evt)
+	assert.Empty(t, d.keys)
+}
+
+func TestDispatcherCloseFlushesPending(t *testing.T) {
+	d := newDispatcher(time.Hour, 10)
+	d.submit(event.VaultEvent{Path: "kv/data/a"})
+	d.close()
+
+	evt, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, "kv/data/a", evt.Path)
+	d.done(evt)
+	_, ok = d.next()
+	assert.False(t, ok)
+}
+
+func TestDispatcherCapacity(t *testing.T) {
+	d := newDispatcher(0, 1)
+	a := event.VaultEvent{Path: "kv/data/a"}
+	d.submit(a)
+
+	// the only slot is held by a, so events of other keys wait for it
+	submitted := make(chan struct{})
+	go func() {
+		d.submit(event.VaultEvent{Path: "kv/data/b"})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("submit did not block at capacity")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	evt, ok := d.next()
+	assert.True(t, ok)
+	assert.Equal(t, a, evt)
+	d.done(evt)
+	<-submitted
+	evt, ok = d.next()
+	assert.True(t, ok)
+	assert.Equal(t, "kv/data/b", evt.Path)
+	d.done(evt)
+	assert.Empty(t, d.keys)
+	assert.Empty(t, d.slots)
+}
+
+func TestCoalescePendingDeletes(t *testing.T) {
+	pending := event.VaultEvent{SyncName: "test/app", Operation: logical.DeleteOperation, Manual: true}
+	evt := event.VaultEvent{SyncName: "test/app", Operation: logical.UpdateOperation, Manual: true, PendingDeletes: true}
+	c := coalesce(pending, evt)
+	assert.False(t, c.PendingDeletes)
+	assert.Equal(t, logical.Operation(logical.DeleteOperation), c.Operation)
+
+	c = coalesce(evt, evt)
+	assert.True(t, c.PendingDeletes)
+}
+
+func TestCoalesceIgnoreSyncWindow(t *testing.T) {
+	pending := event.VaultEvent{SyncName: "test/app", Operation: logical.UpdateOperation, Manual: true, IgnoreSyncWindow: true}
+	evt := event.VaultEvent{SyncName: "test/app", Operation: logical.UpdateOperation, Manual: true}
+	assert.True(t, coalesce(pending, evt).IgnoreSyncWindow)
+	assert.False(t, coalesce(evt, evt).IgnoreSyncWindow)
+}
@@ -19,7 +19,13 @@ func handleSyncError(ctx context.Context, err error, j SyncJob, startTime time.T
 
 	namespace, name := j.SyncConfig.Namespace, j.SyncConfig.Name
 	observeWorkerError(namespace, name, startTime)
-	backend.SetSyncStatus(ctx, j.SyncConfig, backend.SyncStatusFailed)
+	if scheduleRetry(j, err) {
+		// notifications are only sent once the event can no longer be retried
+		backend.SetSyncStatus(ctx, j.SyncConfig, backend.SyncStatusRetrying, syncResult(j, err))
+		return err
+	}
+	backend.SetSyncStatus(ctx, j.SyncConfig, backend.SyncStatusFailed, syncResult(j, err))
+	deadLetter(ctx, j, err)
 	if err := notifications.Trigger(ctx, v1alpha1.NotificationMessage{
 		Message:         fmt.Sprintf("error syncing: %s", err),
 		Event:           v1alpha1.NotificationEventSyncFailure,
@@ -37,7 +43,23 @@ func handleSyncSuccess(ctx context.Context, j SyncJob, startTime time.Time) erro
 
 	namespace, name := j.SyncConfig.Namespace, j.SyncConfig.Name
 	observeWorkerSuccess(namespace, name, startTime)
-	backend.SetSyncStatus(ctx, j.SyncConfig, backend.SyncStatusSuccess)
+	status := backend.SyncStatusSuccess
+	if j.drift.Drifted() && driftPolicy(j) == v1alpha1.DriftPolicyReport {
+		// drift was only reported, the destinations still differ from the source
+		status = backend.SyncStatusDrifted
+	}
+	result := syncResult(j, nil)
+	if j.SyncConfig.DryRun() {
+		status = backend.SyncStatusDryRun
+	}
+	if result != nil && result.Plan != nil {
+		p := result.Plan
+		s := p.Summary
+		backend.WriteEvent(ctx, namespace, name, "Normal", string(backend.SyncStatusDryRun),
+			fmt.Sprintf("dry run plan: %d create, %d update, %d delete, %d write, %d no-op, %d errors",
+				s.Create, s.Update, s.Delete, s.Write, s.NoOp, s.Errors))
+	}
+	backend.SetSyncStatus(ctx, j.SyncConfig, status, result)
 	if err := notifications.Trigger(ctx, v1alpha1.NotificationMessage{
 		Message:         "sync success",
 		Event:           v1alpha1.NotificationEventSyncSuccess,
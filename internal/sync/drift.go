@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+// wholeValueKey is reported in place of key names when a secret
+// is not a JSON object and can only be compared as a whole
+const wholeValueKey = "*"
+
+// keyDiff is the set of key names which differ between two secrets.
+// It never contains values.
+type keyDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty returns true if there are no differences
+func (d keyDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Keys returns all key names which differ
+func (d keyDiff) Keys() []string {
+	var keys []string
+	keys = append(keys, d.Added...)
+	keys = append(keys, d.Removed...)
+	keys = append(keys, d.Changed...)
+	sort.Strings(keys)
+	return keys
+}
+
+// diffSecretKeys compares the desired secret with the current secret.
+// Added keys exist only in desired, removed keys exist only in current.
+// If either secret is not a JSON object, the secrets are compared as a whole.
+func diffSecretKeys(desired, current []byte) keyDiff {
+	var d keyDiff
+	var dm, cm map[string]any
+	if err := json.Unmarshal(desired, &dm); err != nil {
+		return diffWholeValue(desired, current)
+	}
+	if current != nil {
+		if err := json.Unmarshal(current, &cm); err != nil {
+			return diffWholeValue(desired, current)
+		}
+	}
+	for k, v := range dm {
+		cv, ok := cm[k]
+		if !ok {
+			d.Added = append(d.Added, k)
+		} else if !reflect.DeepEqual(v, cv) {
+			d.Changed = append(d.Changed, k)
+		}
+	}
+	for k := range cm {
+		if _, ok := dm[k]; !ok {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// diffWholeValue compares secrets which are not JSON objects
+func diffWholeValue(desired, current []byte) keyDiff {
+	var d keyDiff
+	if current == nil {
+		d.Added = []string{wholeValueKey}
+	} else if !bytes.Equal(bytes.TrimSpace(desired), bytes.TrimSpace(current)) {
+		d.Changed = []string{wholeValueKey}
+	}
+	return d
+}
+
+// driftTracker records drift found across all destinations of a single sync job
+type driftTracker struct {
+	mu      sync.Mutex
+	drifted bool
+}
+
+func (t *driftTracker) record() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drifted = true
+}
+
+func (t *driftTracker) Drifted() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.drifted
+}
+
+// driftPolicy returns the drift policy of the job, or an empty string if
+// the job is not a drift check
+func driftPolicy(j SyncJob) v1alpha1.DriftPolicy {
+	if !j.VaultEvent.DriftCheck {
+		return ""
+	}
+	return j.SyncConfig.Spec.DriftPolicy
+}
+
+// supportsDriftCheck returns true if the destination can read back
+// secrets in the same format they were written
+func supportsDriftCheck(d SyncClient) bool {
+	switch d.Driver() {
+	case driver.DriverNameVault, driver.DriverNameAws, driver.DriverNameGcp:
+		return true
+	default:
+		return false
+	}
+}
+
+// destMerges returns true if the destination merges written keys into the
+// existing secret, in which case extra keys in the destination are not drift
+func destMerges(d SyncClient) bool {
+	m, ok := d.Meta()["merge"].(bool)
+	return ok && m
+}
+
+// checkDrift compares the current destination value with the desired value,
+// reporting any drifted keys. It returns true if the destination has drifted.
+func checkDrift(ctx context.Context, j SyncJob, dest SyncClient, sourcePath, destPath string, desired []byte) bool {
+	l := log.WithFields(log.Fields{
+		"action":    "checkDrift",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+		"driver":    dest.Driver(),
+		"destPath":  destPath,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	var current []byte
+	err := limited(ctx, dest, func(ctx context.Context) error {
+		var err error
+		current, err = dest.GetSecret(ctx, destPath)
+		return err
+	})
+	if err != nil {
+		// a destination which cannot be read is treated as missing
+		l.WithError(err).Debug("unable to read destination secret")
+		current = nil
+	}
+	diff := diffSecretKeys(desired, current)
+	if destMerges(dest) {
+		diff.Removed = nil
+	}
+	if diff.Empty() {
+		l.Debug("no drift")
+		return false
+	}
+	keys := diff.Keys()
+	l.WithField("keys", keys).Info("drift detected")
+	metrics.DriftDetected.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name, string(dest.Driver())).Inc()
+	j.drift.record()
+	action := "reported"
+	if driftPolicy(j) == v1alpha1.DriftPolicyHeal {
+		action = "healing"
+	}
+	backend.WriteEvent(
+		ctx,
+		j.SyncConfig.Namespace,
+		j.SyncConfig.Name,
+		"Warning",
+		string(backend.SyncStatusDrifted),
+		fmt.Sprintf("drift %s for %s in %s: %s, keys: %s", action, sourcePath, dest.Driver(), destPath, strings.Join(keys, ", ")),
+	)
+	return true
+}
+
+// ResyncTrigger triggers a periodic full sync of the sync config,
+// checking destinations for drift if a drift policy is configured
+func ResyncTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync) error {
+	l := log.WithFields(log.Fields{"action": "ResyncTrigger"})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l = l.WithFields(log.Fields{"name": name})
+	l.Debug("resync trigger")
+	evt := event.VaultEvent{
+		SyncName:   name,
+		Operation:  logical.UpdateOperation,
+		Manual:     true,
+		DriftCheck: cfg.Spec.DriftPolicy != "",
+	}
+	return queue.Q.Push(evt)
+}
@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffSecretKeys(t *testing.T) {
+	cases := []struct {
+		name     string
+		desired  string
+		current  []byte
+		expected keyDiff
+	}{
+		{
+			name:     "equal",
+			desired:  `{"a":"1","b":2}`,
+			current:  []byte(`{"b":2,"a":"1"}`),
+			expected: keyDiff{},
+		},
+		{
+			name:     "changed added removed",
+			desired:  `{"a":"1","b":"2","c":"3"}`,
+			current:  []byte(`{"a":"x","c":"3","d":"4"}`),
+			expected: keyDiff{Added: []string{"b"}, Removed: []string{"d"}, Changed: []string{"a"}},
+		},
+		{
+			name:     "missing destination",
+			desired:  `{"b":"2","a":"1"}`,
+			current:  nil,
+			expected: keyDiff{Added: []string{"a", "b"}},
+		},
+		{
+			name:     "non json changed",
+			desired:  "A=1\n",
+			current:  []byte("A=2\n"),
+			expected: keyDiff{Changed: []string{wholeValueKey}},
+		},
+		{
+			name:     "non json equal",
+			desired:  "A=1\n",
+			current:  []byte("A=1"),
+			expected: keyDiff{},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, diffSecretKeys([]byte(c.desired), c.current))
+		})
+	}
+}
+
+func driftTestJob(policy v1alpha1.DriftPolicy, dest *manualRegexTestClient) SyncJob {
+	return SyncJob{
+		VaultEvent: event.VaultEvent{Manual: true, DriftCheck: true},
+		SyncConfig: v1alpha1.VaultSecretSync{
+			ObjectMeta: metav1.ObjectMeta{Name: "drift", Namespace: "test"},
+			Spec: v1alpha1.VaultSecretSyncSpec{
+				Source:      &vault.VaultClient{Path: "kv/app"},
+				DriftPolicy: policy,
+			},
+		},
+		drift: &driftTracker{},
+	}
+}
+
+func TestCreateOneDriftPolicy(t *testing.T) {
+	for _, policy := range []v1alpha1.DriftPolicy{v1alpha1.DriftPolicyReport, v1alpha1.DriftPolicyHeal} {
+		t.Run(string(policy), func(t *testing.T) {
+			source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a","pass":"new"}`)}}
+			dest := &manualRegexTestClient{
+				secrets: map[string][]byte{"kv/copy": []byte(`{"user":"a","pass":"hotfix"}`)},
+			}
+			j := driftTestJob(policy, dest)
+
+			err := CreateOne(context.Background(), j, source, dest, "kv/app", "kv/copy")
+			assert.NoError(t, err)
+			assert.True(t, j.drift.Drifted())
+			if policy == v1alpha1.DriftPolicyReport {
+				assert.Empty(t, dest.writes)
+				assert.JSONEq(t, `{"user":"a","pass":"hotfix"}`, string(dest.secrets["kv/copy"]))
+			} else {
+				assert.JSONEq(t, `{"user":"a","pass":"new"}`, string(dest.writes["kv/copy"]))
+			}
+		})
+	}
+}
+
+func TestCreateOneNoDriftSkipsWrite(t *testing.T) {
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+	dest := &manualRegexTestClient{
+		secrets: map[string][]byte{"kv/copy": []byte(`{"user":"a"}`)},
+	}
+	j := driftTestJob(v1alpha1.DriftPolicyHeal, dest)
+
+	assert.NoError(t, CreateOne(context.Background(), j, source, dest, "kv/app", "kv/copy"))
+	assert.False(t, j.drift.Drifted())
+	assert.Empty(t, dest.writes)
+}
+
+func TestCreateOneDriftReportWritesSourceChange(t *testing.T) {
+	ctx := context.Background()
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+	dest := &manualRegexTestClient{secrets: map[string][]byte{}}
+	assert.NoError(t, CreateOne(ctx, hashTestJob(t, false), source, dest, "kv/app", "kv/copy"))
+	dest.secrets["kv/copy"] = dest.writes["kv/copy"]
+
+	driftJob := func() SyncJob {
+		j := hashTestJob(t, false)
+		j.VaultEvent.DriftCheck = true
+		j.SyncConfig.Spec.DriftPolicy = v1alpha1.DriftPolicyReport
+		j.drift = &driftTracker{}
+		j.destinations = newDestinationTracker()
+		return j
+	}
+
+	// a destination matching the source is recorded as synced
+	dest.writes = nil
+	j := driftJob()
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/copy"))
+	assert.Empty(t, dest.writes)
+	assert.False(t, j.drift.Drifted())
+	r, ok := j.destinations.results[destInventoryKey(dest, "kv/copy")]
+	assert.True(t, ok)
+	assert.False(t, r.Status.LastSuccessTime.Time.IsZero())
+
+	// a source change missed by a dropped event is written, not reported as drift
+	source.secrets["kv/app"] = []byte(`{"user":"b"}`)
+	j = driftJob()
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/copy"))
+	assert.JSONEq(t, `{"user":"b"}`, string(dest.writes["kv/copy"]))
+	assert.False(t, j.drift.Drifted())
+}
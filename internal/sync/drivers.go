@@ -74,7 +74,8 @@ func DestinationStoreNames(sc v1alpha1.VaultSecretSync) []driver.DriverName {
 	return destDrivers
 }
 
-// getAddressForEvent returns the Vault address for a given vault Event
+// getAddressForEvent returns the Vault address for a given vault Event. The
+// destination of a bidirectional sync is matched as well as the sources.
 func GetAddressForEvent(event event.AuditEvent) string {
 	l := log.WithFields(log.Fields{
 		"action": "getAddressForEvent",
@@ -90,24 +91,30 @@ func GetAddressForEvent(event event.AuditEvent) string {
 			l.Error("source driver is not vault")
 			continue
 		}
-		l.Debugf("vaultTenant=%s sourceMeta=%+v", event.VaultTenant, scs.Source.Meta())
-		var metaAddrStr, metaCidrStr string
-		if maex, ok := scs.Source.Meta()["address"]; ok {
-			if v, ok := maex.(string); ok {
-				metaAddrStr = v
-			}
+		vaults := []SyncClient{scs.Source}
+		if v.Spec.Bidirectional != nil && len(scs.Dest) == 1 && scs.Dest[0].Driver() == driver.DriverNameVault {
+			vaults = append(vaults, scs.Dest[0])
 		}
-		if mcex, ok := scs.Source.Meta()["cidr"]; ok {
-			if v, ok := mcex.(string); ok {
-				metaCidrStr = v
+		for _, vc := range vaults {
+			l.Debugf("vaultTenant=%s meta=%+v", event.VaultTenant, vc.Meta())
+			var metaAddrStr, metaCidrStr string
+			if maex, ok := vc.Meta()["address"]; ok {
+				if v, ok := maex.(string); ok {
+					metaAddrStr = v
+				}
+			}
+			if mcex, ok := vc.Meta()["cidr"]; ok {
+				if v, ok := mcex.(string); ok {
+					metaCidrStr = v
+				}
+			}
+			if event.VaultTenant != "" && event.VaultTenant == vc.Meta()["address"] {
+				l.WithField("address", metaAddrStr).Debug("found address in meta")
+				return metaAddrStr
+			} else if metaCidrStr != "" && cidrContainsIP(metaCidrStr, event.RemoteAddr) {
+				l.WithField("address", metaAddrStr).Debug("found address in meta cidr")
+				return metaAddrStr
 			}
-		}
-		if event.VaultTenant != "" && event.VaultTenant == scs.Source.Meta()["address"] {
-			l.WithField("address", metaAddrStr).Debug("found address in source meta")
-			return metaAddrStr
-		} else if metaCidrStr != "" && cidrContainsIP(metaCidrStr, event.RemoteAddr) {
-			l.WithField("address", metaAddrStr).Debug("found address in source meta cidr")
-			return metaAddrStr
 		}
 	}
 	l.Trace("end")
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
-	"github.com/robertlestak/vault-secret-sync/internal/event"
 	"github.com/robertlestak/vault-secret-sync/internal/queue"
 	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	log "github.com/sirupsen/logrus"
@@ -19,17 +18,22 @@ var (
 )
 
 // Worker function that processes events
-func eventWorker(ctx context.Context, workerID int, events <-chan event.VaultEvent) {
+func eventWorker(ctx context.Context, workerID int, d *dispatcher) {
 	l := log.WithFields(log.Fields{
 		"worker": workerID,
 		"action": "eventWorker",
 	})
 	l.Trace("Worker started")
-	for event := range events {
+	for {
+		event, ok := d.next()
+		if !ok {
+			break
+		}
 		// Process the event here
 		if err := Sync(ctx, event); err != nil {
 			l.Error(err)
 		}
+		d.done(event)
 	}
 	l.Trace("Worker stopped")
 }
@@ -40,31 +44,47 @@ func EventProcessor(ctx context.Context, workerPoolSize, numSubscriptions int) e
 	})
 	l.Trace("Starting eventProcessor")
 
-	// Function to start a subscription and its workers
+	// on shutdown the subscriptions are stopped first, and the syncs of
+	// the events already received are only cancelled if they don't finish
+	syncCtx, cancelSyncs := context.WithCancel(ctx)
+	defer cancelSyncs()
+	subCtx, stopSubscriptions := context.WithCancel(syncCtx)
+	defer stopSubscriptions()
+	processor.start(stopSubscriptions, cancelSyncs)
+
+	// events for the same path are synced in order by a shared pool of
+	// workers, and bursts are coalesced. The subscriptions are blocked while
+	// the workers are saturated, leaving events in the queue.
+	workers := numSubscriptions * workerPoolSize
+	d := newDispatcher(DefaultDebounce, workers)
+	for i := 0; i < workers; i++ {
+		processor.workers.Add(1)
+		go func(id int) {
+			defer processor.workers.Done()
+			eventWorker(syncCtx, id, d)
+		}(i)
+	}
+
+	var subscriptions sync.WaitGroup
+	// Function to start a subscription
 	startSubscription := func(subID int) {
 		l := log.WithFields(log.Fields{
 			"subscription": subID,
 		})
-		ch, err := queue.Q.Subscribe(ctx)
+		ch, err := queue.Q.Subscribe(subCtx)
 		if err != nil {
 			l.Error("Failed to subscribe to queue:", err)
 			return
 		}
 		l.Trace("Subscribed to queue")
 
-		eventChannel := make(chan event.VaultEvent)
-
-		// Start workers for this subscription
-		for i := 0; i < workerPoolSize; i++ {
-			go eventWorker(ctx, subID*workerPoolSize+i, eventChannel)
-		}
-
 		// Distribute events to workers
+		subscriptions.Add(1)
 		go func() {
+			defer subscriptions.Done()
 			for event := range ch {
-				eventChannel <- event
+				d.submit(event)
 			}
-			close(eventChannel) // Close channel to stop workers after all events are processed
 		}()
 	}
 
@@ -72,22 +92,17 @@ func EventProcessor(ctx context.Context, workerPoolSize, numSubscriptions int) e
 	for i := 0; i < numSubscriptions; i++ {
 		startSubscription(i)
 	}
+	// stop workers after all events are processed
+	go func() {
+		subscriptions.Wait()
+		d.close()
+	}()
 
 	<-ctx.Done()
 	l.Trace("Stopping eventProcessor")
 	return nil
 }
 
-func Drain(ctx context.Context) {
-	l := log.WithFields(log.Fields{
-		"action": "Drain",
-	})
-	l.Trace("start")
-	go l.Trace("end")
-	// wait for all events to finish processing
-	WaitForSyncs(ctx)
-}
-
 // TrackSyncStart logs the start time of a sync
 func TrackSyncStart(id string) {
 	startTime := time.Now()
@@ -146,7 +161,7 @@ func SyncDelete(ctx context.Context, sc *SyncClients, j SyncJob) error {
 		}
 	} else {
 		l.Debug("single delete")
-		if err := handleSingleDelete(sc, j); err != nil {
+		if err := handleSingleDelete(ctx, sc, j); err != nil {
 			return err
 		}
 	}
@@ -170,7 +185,7 @@ func SyncCreate(ctx context.Context, sc *SyncClients, j SyncJob) error {
 			return err
 		}
 	} else {
-		if err := handleSingleSync(sc, j); err != nil {
+		if err := handleSingleSync(ctx, sc, j); err != nil {
 			return err
 		}
 	}
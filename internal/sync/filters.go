@@ -23,18 +23,18 @@ func shouldFilterSecret(j SyncJob, sourcePath, destPath string) bool {
 	return false
 }
 
-// shouldDryRun checks if the sync should be a dry run
-func shouldDryRun(j SyncJob, dest SyncClient, sourcePath, destPath string) bool {
+// shouldSuspend checks if the sync is suspended
+func shouldSuspend(ctx context.Context, j SyncJob, dest SyncClient, sourcePath, destPath string) bool {
 	l := log.WithFields(log.Fields{
-		"action":     "shouldDryRun",
+		"action":     "shouldSuspend",
 		"sourcePath": sourcePath,
 		"destPath":   destPath,
 	})
 	if j.SyncConfig.Spec.Suspend != nil && *j.SyncConfig.Spec.Suspend {
 		l.Info("sync suspended")
-		backend.SetSyncStatus(context.TODO(), j.SyncConfig, backend.SyncStatusSuspended)
+		backend.SetSyncStatus(ctx, j.SyncConfig, backend.SyncStatusSuspended, nil)
 		backend.WriteEvent(
-			context.TODO(),
+			ctx,
 			j.SyncConfig.Namespace,
 			j.SyncConfig.Name,
 			"Normal",
@@ -43,16 +43,30 @@ func shouldDryRun(j SyncJob, dest SyncClient, sourcePath, destPath string) bool
 		)
 		return true
 	}
-	if j.SyncConfig.Spec.DryRun != nil && *j.SyncConfig.Spec.DryRun {
+	return false
+}
+
+// shouldDryRun checks if a delete should be skipped because the sync is
+// suspended or a dry run, recording the planned delete of a dry run
+func shouldDryRun(ctx context.Context, j SyncJob, dest SyncClient, sourcePath, destPath string) bool {
+	l := log.WithFields(log.Fields{
+		"action":     "shouldDryRun",
+		"sourcePath": sourcePath,
+		"destPath":   destPath,
+	})
+	if shouldSuspend(ctx, j, dest, sourcePath, destPath) {
+		return true
+	}
+	if isDryRun(j) {
 		l.Info("dry run")
-		backend.SetSyncStatus(context.TODO(), j.SyncConfig, backend.SyncStatusDryRun)
+		j.plan.delete(dest, destPath)
 		backend.WriteEvent(
-			context.TODO(),
+			ctx,
 			j.SyncConfig.Namespace,
 			j.SyncConfig.Name,
 			"Normal",
 			string(backend.SyncStatusDryRun),
-			fmt.Sprintf("dry run: synced %s to %s: %s", sourcePath, dest.Driver(), destPath),
+			fmt.Sprintf("dry run: would delete %s from %s: %s", sourcePath, dest.Driver(), destPath),
 		)
 		return true
 	}
@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+)
+
+// metaString returns the string value of a destination meta field
+func metaString(m map[string]any, k string) string {
+	if v, ok := m[k]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// destLocation identifies the store a destination client writes to, so that
+// the same path in two different stores is tracked separately
+func destLocation(d SyncClient) string {
+	var parts []string
+	switch d.Driver() {
+	case driver.DriverNameVault:
+		m := d.Meta()
+		parts = []string{metaString(m, "address"), metaString(m, "namespace")}
+	case driver.DriverNameAws:
+		m := d.Meta()
+		parts = []string{metaString(m, "region"), metaString(m, "roleArn")}
+	case driver.DriverNameGcp:
+		parts = []string{metaString(d.Meta(), "project")}
+	case driver.DriverNameGitHub:
+		m := d.Meta()
+		parts = []string{
+			metaString(m, "owner"),
+			metaString(m, "repo"),
+			metaString(m, "env"),
+			metaString(m, "org"),
+			metaString(m, "dependabot"),
+		}
+	default:
+		// the http destination path is the full url
+		return ""
+	}
+	return strings.Join(parts, "/")
+}
+
+// destInventoryKey returns the inventory key of the destination secret
+func destInventoryKey(d SyncClient, destPath string) string {
+	return backend.InventoryKey(string(d.Driver()), destLocation(d), destPath)
+}
+
+// payloadHash returns the hash of the payload written to a destination.
+// The hash covers the destination configuration of the sync config so that
+// configuration changes such as tags or encryption keys are written, and is
+// salted with the sync config UID so that it cannot be compared across configs.
+func payloadHash(j SyncJob, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(j.SyncConfig.UID))
+	h.Write([]byte{0})
+	if dj, err := json.Marshal(j.SyncConfig.Spec.Dest); err == nil {
+		h.Write(dj)
+	}
+	h.Write([]byte{0})
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// unchanged returns true if the payload was already written to the destination
+func unchanged(j SyncJob, dest SyncClient, destPath, hash string) bool {
+	r, ok := j.inventory.Get(destInventoryKey(dest, destPath))
+	return ok && r.Hash == hash
+}
+
+// changed returns true if a different payload was last written to the
+// destination, as the source or the sync config changed since
+func changed(j SyncJob, dest SyncClient, destPath, hash string) bool {
+	r, ok := j.inventory.Get(destInventoryKey(dest, destPath))
+	return ok && r.Hash != hash
+}
+
+// recordWrite records the payload hash written to the destination
+func recordWrite(j SyncJob, dest SyncClient, sourcePath, destPath, hash string) {
+	recordKeyWrite(j, dest, sourcePath, destPath, "", hash, nil)
+}
+
+// recordKeyWrite records the payload hash written to the destination, the
+// source secret key written if the destination splits keys, and the keys
+// of the source metadata propagated
+func recordKeyWrite(j SyncJob, dest SyncClient, sourcePath, destPath, key, hash string, metadata []string) {
+	j.inventory.Put(backend.InventoryRecord{
+		Driver:     string(dest.Driver()),
+		Location:   destLocation(dest),
+		Path:       destPath,
+		SourcePath: sourcePath,
+		SourceKey:  key,
+		Hash:       hash,
+		Metadata:   metadata,
+	})
+}
+
+// recordDelete removes the destination secret from the inventory
+func recordDelete(j SyncJob, dest SyncClient, destPath string) {
+	j.inventory.Delete(destInventoryKey(dest, destPath))
+	j.destinations.deleted(dest, destPath)
+}
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func hashTestJob(t *testing.T, force bool) SyncJob {
+	t.Helper()
+	cfg := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Name: "hash", Namespace: "test", UID: "hash-uid"},
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Source: &vault.VaultClient{Path: "kv/app"},
+		},
+	}
+	inv, err := backend.GetInventory(context.Background(), cfg)
+	assert.NoError(t, err)
+	return SyncJob{
+		VaultEvent: event.VaultEvent{Manual: true, Force: force},
+		SyncConfig: cfg,
+		inventory:  inv,
+	}
+}
+
+func TestCreateOneSkipsUnchangedWrites(t *testing.T) {
+	ctx := context.Background()
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+	dest := &manualRegexTestClient{}
+
+	assert.NoError(t, CreateOne(ctx, hashTestJob(t, false), source, dest, "kv/app", "kv/copy"))
+	assert.Len(t, dest.writes, 1)
+
+	// the same payload is not written again
+	dest.writes = nil
+	assert.NoError(t, CreateOne(ctx, hashTestJob(t, false), source, dest, "kv/app", "kv/copy"))
+	assert.Empty(t, dest.writes)
+
+	// a force sync writes regardless of the recorded hash
+	assert.NoError(t, CreateOne(ctx, hashTestJob(t, true), source, dest, "kv/app", "kv/copy"))
+	assert.Len(t, dest.writes, 1)
+
+	// a changed payload is written
+	dest.writes = nil
+	source.secrets["kv/app"] = []byte(`{"user":"b"}`)
+	assert.NoError(t, CreateOne(ctx, hashTestJob(t, false), source, dest, "kv/app", "kv/copy"))
+	assert.JSONEq(t, `{"user":"b"}`, string(dest.writes["kv/copy"]))
+
+	// a deleted destination is written again on the next sync
+	j := hashTestJob(t, false)
+	recordDelete(j, dest, "kv/copy")
+	dest.writes = nil
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/copy"))
+	assert.Len(t, dest.writes, 1)
+}
+
+func TestPayloadHashCoversDestConfig(t *testing.T) {
+	j := hashTestJob(t, false)
+	h := payloadHash(j, []byte(`{"user":"a"}`))
+	assert.Equal(t, h, payloadHash(j, []byte(`{"user":"a"}`)))
+
+	j.SyncConfig.Spec.Dest = []*v1alpha1.StoreConfig{{Vault: &vault.VaultClient{Path: "kv/copy"}}}
+	hd := payloadHash(j, []byte(`{"user":"a"}`))
+	assert.NotEqual(t, h, hd)
+
+	j.SyncConfig.UID = "other-uid"
+	assert.NotEqual(t, hd, payloadHash(j, []byte(`{"user":"a"}`)))
+}
+
+func TestCreateOneRecordsDestinationStatus(t *testing.T) {
+	ctx := context.Background()
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+	dest := &manualRegexTestClient{}
+
+	j := hashTestJob(t, true)
+	j.VaultEvent.Operation = logical.UpdateOperation
+	j.destinations = newDestinationTracker()
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/copy"))
+	assert.Error(t, CreateOne(ctx, j, source, dest, "kv/missing", "kv/other"))
+
+	r := syncResult(j, nil)
+	assert.True(t, r.Complete)
+	assert.Len(t, r.Destinations, 2)
+	byPath := make(map[string]v1alpha1.DestinationStatus)
+	for _, d := range r.Destinations {
+		byPath[d.Status.Path] = d.Status
+	}
+	assert.Equal(t, payloadHash(j, []byte(`{"user":"a"}`)), byPath["kv/copy"].Hash)
+	assert.False(t, byPath["kv/copy"].LastSuccessTime.Time.IsZero())
+	assert.Equal(t, "secret not found", byPath["kv/other"].LastError)
+	assert.True(t, byPath["kv/other"].LastSuccessTime.Time.IsZero())
+
+	// a failed sync only updates the destinations it touched
+	assert.False(t, syncResult(j, assert.AnError).Complete)
+}
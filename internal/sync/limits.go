@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+)
+
+var (
+	// DefaultWorkers is the number of sync configs, or destinations of a
+	// sync config, which are synced concurrently
+	DefaultWorkers = 100
+	// DefaultRegexWorkers is the number of secrets of a regex sync config
+	// which are synced concurrently
+	DefaultRegexWorkers = 10
+	// DefaultSyncTimeout is the maximum duration of a sync when a
+	// VaultSecretSync does not set spec.timeout. 0 disables the timeout.
+	DefaultSyncTimeout time.Duration
+	// DefaultCallTimeout is the maximum duration of a single store
+	// operation, such as writing a secret. 0 disables the timeout.
+	DefaultCallTimeout = 5 * time.Minute
+)
+
+// workers returns the number of workers for n tasks
+func workers(max, n int) int {
+	if n < max {
+		return n
+	}
+	return max
+}
+
+// withTimeout returns a context with the timeout, or without a timeout if it is 0
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// syncTimeout returns the timeout of a sync of the sync config
+func syncTimeout(s v1alpha1.VaultSecretSync) time.Duration {
+	if s.Spec.Timeout != nil {
+		return s.Spec.Timeout.Duration
+	}
+	return DefaultSyncTimeout
+}
+
+// limited runs the store operation once allowed by the concurrency and
+// rate limits of the store's driver, with the call timeout. Errors caused
+// by the call or sync timeout are marked as timeouts.
+func limited(ctx context.Context, d SyncClient, op func(context.Context) error) error {
+	release, err := driver.Acquire(ctx, d.Driver())
+	if err != nil {
+		return driver.Timeout(ctx, err)
+	}
+	defer release()
+	cctx, cancel := withTimeout(ctx, DefaultCallTimeout)
+	defer cancel()
+	err = op(cctx)
+	if err != nil && ctx.Err() == nil && errors.Is(cctx.Err(), context.DeadlineExceeded) {
+		metrics.StoreCallTimeouts.WithLabelValues(string(d.Driver())).Inc()
+	}
+	return driver.Timeout(cctx, err)
+}
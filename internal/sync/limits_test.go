@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hungTestClient is a destination whose writes never complete
+type hungTestClient struct {
+	manualRegexTestClient
+}
+
+func (m *hungTestClient) WriteSecret(ctx context.Context, _ metav1.ObjectMeta, _ string, _ []byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCreateOneCallTimeout(t *testing.T) {
+	defer func(d time.Duration) { DefaultCallTimeout = d }(DefaultCallTimeout)
+	DefaultCallTimeout = 10 * time.Millisecond
+
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+	j := hashTestJob(t, true)
+	j.destinations = newDestinationTracker()
+	err := CreateOne(context.Background(), j, source, &hungTestClient{}, "kv/app", "kv/hung")
+	assert.Error(t, err)
+	assert.True(t, driver.IsTimeout(err))
+	assert.False(t, driver.IsPermanent(err))
+	assert.True(t, syncResult(j, err).TimedOut)
+}
+
+func TestSyncTimeoutError(t *testing.T) {
+	j := hashTestJob(t, false)
+	err := context.Canceled
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, driver.IsTimeout(syncTimeoutError(ctx, j, err)))
+
+	tctx, tcancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer tcancel()
+	<-tctx.Done()
+	assert.True(t, driver.IsTimeout(syncTimeoutError(tctx, j, err)))
+}
@@ -0,0 +1,74 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+)
+
+// selectMetadata returns the source metadata propagated by the config,
+// with the prefix of the config prepended to its keys
+func selectMetadata(cfg *v1alpha1.MetadataConfig, md map[string]string) map[string]string {
+	if cfg == nil || len(md) == 0 {
+		return nil
+	}
+	sel := make(map[string]string)
+	for k, v := range md {
+		if len(cfg.Include) > 0 && !slices.Contains(cfg.Include, k) {
+			continue
+		}
+		if slices.Contains(cfg.Exclude, k) {
+			continue
+		}
+		sel[cfg.Prefix+k] = v
+	}
+	return sel
+}
+
+// destMetadata returns the source metadata to propagate to the destination
+// secret, and the keys propagated by the last write to the destination
+// secret which are no longer propagated
+func destMetadata(j SyncJob, dest SyncClient, sourcePath, destPath string) driver.Metadata {
+	md := driver.Metadata{
+		Set: selectMetadata(j.SyncConfig.Spec.PropagateMetadata, j.sources.metadata(sourcePath)),
+	}
+	if r, ok := j.inventory.Get(destInventoryKey(dest, destPath)); ok {
+		for _, k := range r.Metadata {
+			if _, ok := md.Set[k]; !ok {
+				md.Remove = append(md.Remove, k)
+			}
+		}
+	}
+	return md
+}
+
+// metadataKeys returns the sorted keys of the metadata
+func metadataKeys(md map[string]string) []string {
+	var keys []string
+	for k := range md {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// metadataHash returns the payload hash covering the propagated metadata,
+// so that changes to the metadata alone are written. Without metadata the
+// payload hash is returned as is.
+func metadataHash(hash string, md map[string]string) string {
+	if len(md) == 0 {
+		return hash
+	}
+	h := sha256.New()
+	h.Write([]byte(hash))
+	for _, k := range metadataKeys(md) {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(md[k]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
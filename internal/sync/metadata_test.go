@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// metadataTestClient is a test store which reads the custom metadata of
+// its secrets, and records the metadata propagated by each write
+type metadataTestClient struct {
+	manualRegexTestClient
+	metadata map[string]map[string]string
+	written  []driver.Metadata
+}
+
+func (c *metadataTestClient) GetSecretWithMetadata(ctx context.Context, path string) ([]byte, map[string]string, error) {
+	secret, err := c.GetSecret(ctx, path)
+	return secret, c.metadata[path], err
+}
+
+func (c *metadataTestClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secret []byte) ([]byte, error) {
+	c.written = append(c.written, driver.MetadataFrom(ctx))
+	return c.manualRegexTestClient.WriteSecret(ctx, meta, path, secret)
+}
+
+func TestSelectMetadata(t *testing.T) {
+	md := map[string]string{"owner": "team-a", "cost-center": "42", "internal": "x"}
+	assert.Nil(t, selectMetadata(nil, md))
+	assert.Equal(t, map[string]string{"vault-owner": "team-a", "vault-cost-center": "42"},
+		selectMetadata(&v1alpha1.MetadataConfig{Exclude: []string{"internal"}, Prefix: "vault-"}, md))
+	assert.Equal(t, map[string]string{"owner": "team-a"},
+		selectMetadata(&v1alpha1.MetadataConfig{Include: []string{"owner"}}, md))
+}
+
+func TestPropagateMetadata(t *testing.T) {
+	ctx := context.Background()
+	source := &metadataTestClient{
+		manualRegexTestClient: manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}},
+		metadata:              map[string]map[string]string{"kv/app": {"owner": "team-a", "internal": "x"}},
+	}
+	dest := &metadataTestClient{}
+
+	j := pruneTestJob(t, "metadata", false, false)
+	j.SyncConfig.Spec.PropagateMetadata = &v1alpha1.MetadataConfig{Exclude: []string{"internal"}}
+	sync := func() {
+		j.sources = newSourceCache()
+		assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/copy"))
+	}
+
+	sync()
+	assert.Equal(t, []driver.Metadata{{Set: map[string]string{"owner": "team-a"}}}, dest.written)
+	r, _ := j.inventory.Get(destInventoryKey(dest, "kv/copy"))
+	assert.Equal(t, []string{"owner"}, r.Metadata)
+
+	// unchanged secrets and metadata are not written again
+	sync()
+	assert.Len(t, dest.written, 1)
+
+	// a change to the metadata alone is written
+	source.metadata["kv/app"]["owner"] = "team-b"
+	sync()
+	assert.Len(t, dest.written, 2)
+	assert.Equal(t, "team-b", dest.written[1].Set["owner"])
+
+	// metadata removed from the source is removed from the destination
+	source.metadata["kv/app"] = nil
+	sync()
+	assert.Equal(t, driver.Metadata{Remove: []string{"owner"}}, dest.written[2])
+	r, _ = j.inventory.Get(destInventoryKey(dest, "kv/copy"))
+	assert.Empty(t, r.Metadata)
+}
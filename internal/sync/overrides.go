@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+)
+
+// destJob returns the job as seen by the destination, with the overrides of
+// the destination merged over the spec of the sync config. Destinations
+// which are not configured in the spec, such as the stores of pruned
+// secrets since removed from the spec, see the spec as is.
+func destJob(j SyncJob, dest SyncClient) SyncJob {
+	if d, ok := j.stores[dest]; ok {
+		j.SyncConfig = j.SyncConfig.ForDest(d)
+	}
+	return j
+}
+
+// isSuspended returns true if the job is suspended
+func isSuspended(j SyncJob) bool {
+	return j.SyncConfig.Spec.Suspend != nil && *j.SyncConfig.Spec.Suspend
+}
+
+// syncsDelete returns true if deletes of source secrets are synced by the job
+func syncsDelete(j SyncJob) bool {
+	return j.SyncConfig.Spec.SyncDelete == nil || *j.SyncConfig.Spec.SyncDelete
+}
+
+// destStores returns the spec config of each destination client
+func destStores(sc v1alpha1.VaultSecretSync, scs *SyncClients) map[SyncClient]*v1alpha1.StoreConfig {
+	stores := make(map[SyncClient]*v1alpha1.StoreConfig)
+	for i, d := range sc.Spec.Dest {
+		if d != nil && i < len(scs.Dest) {
+			stores[scs.Dest[i]] = d
+		}
+	}
+	return stores
+}
@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTestClient is a test source which counts its reads
+type countingTestClient struct {
+	manualRegexTestClient
+	reads int
+}
+
+func (c *countingTestClient) GetSecret(ctx context.Context, path string) ([]byte, error) {
+	c.reads++
+	return c.manualRegexTestClient.GetSecret(ctx, path)
+}
+
+func TestForDest(t *testing.T) {
+	yes, no := true, false
+	tmpl := `{{ .user }}`
+	cfg := v1alpha1.VaultSecretSync{Spec: v1alpha1.VaultSecretSyncSpec{
+		DryRun: &yes,
+		Transforms: &v1alpha1.TransformSpec{
+			Include: []string{"user", "pass"},
+			Rename:  []v1alpha1.RenameTransform{{From: "user", To: "username"}},
+		},
+	}}
+
+	d := cfg.ForDest(&v1alpha1.StoreConfig{
+		DryRun:     &no,
+		Suspend:    &yes,
+		Transforms: &v1alpha1.TransformSpec{Template: &tmpl},
+	})
+	assert.False(t, *d.Spec.DryRun)
+	assert.True(t, *d.Spec.Suspend)
+	assert.Equal(t, []string{"user", "pass"}, d.Spec.Transforms.Include)
+	assert.Equal(t, "username", d.Spec.Transforms.Rename[0].To)
+	assert.Equal(t, tmpl, *d.Spec.Transforms.Template)
+
+	// the sync config is not modified
+	assert.True(t, *cfg.Spec.DryRun)
+	assert.Nil(t, cfg.Spec.Suspend)
+	assert.Nil(t, cfg.Spec.Transforms.Template)
+
+	assert.Equal(t, cfg, cfg.ForDest(&v1alpha1.StoreConfig{}))
+}
+
+func TestDestOverrideHelpers(t *testing.T) {
+	yes, no := true, false
+	cfg := v1alpha1.VaultSecretSync{Spec: v1alpha1.VaultSecretSyncSpec{
+		SyncDelete: &no,
+		Dest:       []*v1alpha1.StoreConfig{{Suspend: &yes, DryRun: &yes}, {}},
+	}}
+	assert.False(t, cfg.Suspended())
+	assert.True(t, cfg.PartialSuspend())
+	assert.False(t, cfg.DryRun())
+	assert.True(t, cfg.PartialDryRun())
+	assert.False(t, cfg.SyncsDeletes())
+
+	cfg.Spec.Dest[1].Suspend = &yes
+	cfg.Spec.Dest[1].SyncDelete = &yes
+	assert.True(t, cfg.Suspended())
+	assert.True(t, cfg.SyncsDeletes())
+}
+
+func TestDestOverrides(t *testing.T) {
+	ctx := context.Background()
+	yes := true
+	source := &countingTestClient{manualRegexTestClient: manualRegexTestClient{
+		secrets: map[string][]byte{"kv/app": []byte(`{"user":"admin","pass":"secret"}`)},
+	}}
+	full := &manualRegexTestClient{path: "full/app"}
+	subset := &manualRegexTestClient{path: "subset/app"}
+	flaky := &manualRegexTestClient{path: "flaky/app"}
+
+	j := pruneTestJob(t, "overrides", false, false)
+	j.SyncConfig.Spec.Dest = []*v1alpha1.StoreConfig{
+		{},
+		{Transforms: &v1alpha1.TransformSpec{Include: []string{"user"}}},
+		{Suspend: &yes},
+	}
+	scs := &SyncClients{Dest: []SyncClient{full, subset, flaky}}
+	j.stores = destStores(j.SyncConfig, scs)
+	j.sources = newSourceCache()
+
+	for _, d := range scs.Dest {
+		assert.NoError(t, CreateOne(ctx, j, source, d, "kv/app", d.GetPath()))
+	}
+	assert.JSONEq(t, `{"user":"admin","pass":"secret"}`, string(full.writes["full/app"]))
+	assert.JSONEq(t, `{"user":"admin"}`, string(subset.writes["subset/app"]))
+	assert.Empty(t, flaky.writes)
+	// the source is read once for every destination
+	assert.Equal(t, 1, source.reads)
+}
+
+func TestPruneOrphansDestOverrides(t *testing.T) {
+	ctx := context.Background()
+	yes := true
+	planned := &manualRegexTestClient{}
+	pruned := &splitTestClient{}
+	scs := &SyncClients{Dest: []SyncClient{planned, pruned}}
+
+	j := pruneTestJob(t, "prune-overrides", true, false)
+	j.SyncConfig.Spec.Dest = []*v1alpha1.StoreConfig{{DryRun: &yes}, {}}
+	j.stores = destStores(j.SyncConfig, scs)
+	j.plan = newPlanTracker()
+	for _, d := range scs.Dest {
+		recordWrite(j, d, "kv/old", "kv/old", "h")
+	}
+	assert.NoError(t, pruneOrphans(ctx, scs, j))
+	assert.Empty(t, planned.deletes)
+	assert.Equal(t, []string{"kv/old"}, pruned.deletes)
+	_, kept := j.inventory.Get(destInventoryKey(planned, "kv/old"))
+	assert.True(t, kept)
+
+	// suspended destinations are not pruned
+	j.SyncConfig.Spec.Dest[0] = &v1alpha1.StoreConfig{Suspend: &yes}
+	j.stores = destStores(j.SyncConfig, scs)
+	assert.NoError(t, pruneOrphans(ctx, scs, j))
+	assert.Empty(t, planned.deletes)
+	_, kept = j.inventory.Get(destInventoryKey(planned, "kv/old"))
+	assert.True(t, kept)
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+// conflictPolicy returns the conflict policy of the job, defaulting to adopt
+func conflictPolicy(j SyncJob) v1alpha1.ConflictPolicy {
+	if j.SyncConfig.Spec.ConflictPolicy == "" {
+		return v1alpha1.ConflictPolicyAdopt
+	}
+	return j.SyncConfig.Spec.ConflictPolicy
+}
+
+// conflicts returns the names of the existing secrets which the conflict
+// policy does not allow the owner to write
+func conflicts(policy v1alpha1.ConflictPolicy, owner string, owners map[string]string) []string {
+	var c []string
+	for name, o := range owners {
+		if o == owner {
+			continue
+		}
+		if o == "" && policy == v1alpha1.ConflictPolicyAdopt {
+			continue
+		}
+		c = append(c, name)
+	}
+	sort.Strings(c)
+	return c
+}
+
+// checkOwnership returns a permanent error if writing the payload to the
+// destination would replace or delete an existing secret which the conflict
+// policy of the job does not allow it to write. Destinations which do not record
+// owners are not checked.
+func checkOwnership(ctx context.Context, j SyncJob, dest SyncClient, destPath string, payload []byte) error {
+	l := log.WithFields(log.Fields{
+		"action":    "checkOwnership",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+		"driver":    dest.Driver(),
+		"dest.Path": destPath,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	policy := conflictPolicy(j)
+	if policy == v1alpha1.ConflictPolicyOverwrite {
+		return nil
+	}
+	od, ok := dest.(driver.Ownable)
+	if !ok {
+		return nil
+	}
+	owner := driver.Owner(j.SyncConfig.ObjectMeta)
+	if owner == "" {
+		return nil
+	}
+	var owners map[string]string
+	err := limited(ctx, dest, func(ctx context.Context) error {
+		var err error
+		owners, err = od.Owners(ctx, owner, destPath, payload)
+		return err
+	})
+	if err != nil {
+		return driver.Classify(dest, err)
+	}
+	c := conflicts(policy, owner, owners)
+	if len(c) == 0 {
+		return nil
+	}
+	l.WithField("conflicts", c).Warn("destination secret not owned by sync")
+	var details []string
+	for _, name := range c {
+		o := owners[name]
+		if o == "" {
+			o = "no owner"
+		}
+		details = append(details, fmt.Sprintf("%s (%s)", name, o))
+	}
+	return driver.Permanent(fmt.Errorf("conflictPolicy %s: refusing to write or delete existing secrets not owned by %s: %v", policy, owner, details))
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// ownableTestClient is a destination which records secret owners
+type ownableTestClient struct {
+	manualRegexTestClient
+	owners map[string]string
+}
+
+func (m *ownableTestClient) Owners(ctx context.Context, owner, path string, payload []byte) (map[string]string, error) {
+	if o, ok := m.owners[path]; ok {
+		return map[string]string{path: o}, nil
+	}
+	return map[string]string{}, nil
+}
+
+func TestCreateOneConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  v1alpha1.ConflictPolicy
+		owners  map[string]string
+		wantErr bool
+	}{
+		{name: "new secret", owners: map[string]string{}},
+		{name: "owned secret", owners: map[string]string{"kv/copy": "test/hash"}},
+		{name: "adopt unowned", owners: map[string]string{"kv/copy": ""}},
+		{name: "adopt owned by other", owners: map[string]string{"kv/copy": "test/other"}, wantErr: true},
+		{name: "refuse unowned", policy: v1alpha1.ConflictPolicyRefuse, owners: map[string]string{"kv/copy": ""}, wantErr: true},
+		{name: "refuse owned", policy: v1alpha1.ConflictPolicyRefuse, owners: map[string]string{"kv/copy": "test/hash"}},
+		{name: "overwrite owned by other", policy: v1alpha1.ConflictPolicyOverwrite, owners: map[string]string{"kv/copy": "test/other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+			dest := &ownableTestClient{owners: tt.owners}
+			// force the write, as the inventory is shared by the tests
+			j := hashTestJob(t, true)
+			j.SyncConfig.Spec.ConflictPolicy = tt.policy
+
+			err := CreateOne(context.Background(), j, source, dest, "kv/app", "kv/copy")
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.True(t, driver.IsPermanent(err))
+				assert.Empty(t, dest.writes)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, dest.writes, 1)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package sync
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isDryRun returns true if the sync config is a dry run
+func isDryRun(j SyncJob) bool {
+	return j.SyncConfig.Spec.DryRun != nil && *j.SyncConfig.Spec.DryRun
+}
+
+// planTracker records the changes a dry run of a single sync job would
+// make to each destination secret
+type planTracker struct {
+	mu      sync.Mutex
+	changes map[string]v1alpha1.PlannedChange
+}
+
+func newPlanTracker() *planTracker {
+	return &planTracker{changes: make(map[string]v1alpha1.PlannedChange)}
+}
+
+func (t *planTracker) record(dest SyncClient, destPath string, c v1alpha1.PlannedChange) {
+	if t == nil {
+		return
+	}
+	c.Driver = string(dest.Driver())
+	c.Location = destLocation(dest)
+	c.Path = destPath
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changes[destInventoryKey(dest, destPath)] = c
+}
+
+// delete records that the destination secret would be deleted
+func (t *planTracker) delete(dest SyncClient, destPath string) {
+	t.record(dest, destPath, v1alpha1.PlannedChange{Action: v1alpha1.PlanActionDelete})
+}
+
+// orphan records that the orphaned destination secret would be pruned
+func (t *planTracker) orphan(r backend.InventoryRecord) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.changes[r.Key()] = v1alpha1.PlannedChange{
+		Driver:   r.Driver,
+		Location: r.Location,
+		Path:     r.Path,
+		Action:   v1alpha1.PlanActionDelete,
+	}
+}
+
+// planStore holds the full plan of each dry run sync config, of which only
+// part may fit in its status
+type planStore struct {
+	mu    sync.Mutex
+	plans map[string]*v1alpha1.Plan
+}
+
+var plans = &planStore{plans: make(map[string]*v1alpha1.Plan)}
+
+// update merges the changes into the plan of the sync config, returning
+// the new plan. The changes of a complete sync replace the plan.
+func (s *planStore) update(name string, changes map[string]v1alpha1.PlannedChange, complete bool) *v1alpha1.Plan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byKey := make(map[string]v1alpha1.PlannedChange)
+	if prev, ok := s.plans[name]; ok && !complete {
+		for _, c := range prev.Changes {
+			byKey[backend.InventoryKey(c.Driver, c.Location, c.Path)] = c
+		}
+	}
+	for k, c := range changes {
+		byKey[k] = c
+	}
+	p := &v1alpha1.Plan{Time: metav1.Now()}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := byKey[k]
+		p.Changes = append(p.Changes, c)
+		if c.Error != "" {
+			p.Summary.Errors++
+		}
+		switch c.Action {
+		case v1alpha1.PlanActionCreate:
+			p.Summary.Create++
+		case v1alpha1.PlanActionUpdate:
+			p.Summary.Update++
+		case v1alpha1.PlanActionDelete:
+			p.Summary.Delete++
+		case v1alpha1.PlanActionNoOp:
+			p.Summary.NoOp++
+		case v1alpha1.PlanActionWrite:
+			p.Summary.Write++
+		}
+	}
+	s.plans[name] = p
+	return p
+}
+
+func (s *planStore) get(name string) (*v1alpha1.Plan, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.plans[name]
+	return p, ok
+}
+
+func (s *planStore) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.plans, name)
+}
+
+// planResult returns the plan of the sync config after the job, or nil if
+// no destination of the sync config is a dry run
+func planResult(j SyncJob, err error) *v1alpha1.Plan {
+	name := backend.InternalName(j.SyncConfig.Namespace, j.SyncConfig.Name)
+	if !j.SyncConfig.PartialDryRun() {
+		plans.forget(name)
+		return nil
+	}
+	if j.plan == nil {
+		return nil
+	}
+	j.plan.mu.Lock()
+	defer j.plan.mu.Unlock()
+	return plans.update(name, j.plan.changes, err == nil && fullSync(j))
+}
+
+// planCreate records the change a dry run would make when writing the
+// source secret to the destination. Destinations which can be read back
+// are compared with the source, others are planned as a write of every key.
+func planCreate(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePath, destPath string) error {
+	l := log.WithFields(log.Fields{
+		"action":      "planCreate",
+		"source.Path": sourcePath,
+		"dest.Path":   destPath,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	fail := func(err error) error {
+		j.plan.record(dest, destPath, v1alpha1.PlannedChange{Action: v1alpha1.PlanActionWrite, Error: err.Error()})
+		return handleCreateOneError(ctx, err, j, dest, sourcePath, destPath)
+	}
+	ssecret, err := readSource(ctx, j, source, sourcePath)
+	if err != nil {
+		return fail(err)
+	}
+	planWrite(ctx, j, dest, destPath, ssecret)
+	return nil
+}
+
+// planWrite records the change a dry run would make when writing the
+// payload to the destination
+func planWrite(ctx context.Context, j SyncJob, dest SyncClient, destPath string, ssecret []byte) {
+	l := log.WithFields(log.Fields{
+		"action":    "planWrite",
+		"dest.Path": destPath,
+	})
+	c := v1alpha1.PlannedChange{Action: v1alpha1.PlanActionWrite}
+	if supportsDriftCheck(dest) {
+		var current []byte
+		err := limited(ctx, dest, func(ctx context.Context) error {
+			var err error
+			current, err = dest.GetSecret(ctx, destPath)
+			return err
+		})
+		if err != nil {
+			// a destination which cannot be read is treated as missing
+			l.WithError(err).Debug("unable to read destination secret")
+			current = nil
+		}
+		diff := diffSecretKeys(ssecret, current)
+		if destMerges(dest) {
+			diff.Removed = nil
+		}
+		switch {
+		case current == nil:
+			c.Action = v1alpha1.PlanActionCreate
+		case diff.Empty():
+			c.Action = v1alpha1.PlanActionNoOp
+		default:
+			c.Action = v1alpha1.PlanActionUpdate
+		}
+		c.Added, c.Removed, c.Changed = diff.Added, diff.Removed, diff.Changed
+	} else {
+		c.Added = diffSecretKeys(ssecret, nil).Added
+	}
+	if c.Action != v1alpha1.PlanActionNoOp {
+		if err := checkOwnership(ctx, j, dest, destPath, ssecret); err != nil {
+			c.Error = err.Error()
+		}
+	}
+	l.WithField("plan", c.Action).Debug("planned change")
+	j.plan.record(dest, destPath, c)
+}
@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func planTestJob(t *testing.T) SyncJob {
+	dryRun := true
+	j := SyncJob{
+		VaultEvent: event.VaultEvent{Manual: true, Operation: "update"},
+		SyncConfig: v1alpha1.VaultSecretSync{
+			ObjectMeta: metav1.ObjectMeta{Name: t.Name(), Namespace: "test"},
+			Spec: v1alpha1.VaultSecretSyncSpec{
+				Source: &vault.VaultClient{Path: "kv/app"},
+				DryRun: &dryRun,
+			},
+		},
+		drift:        &driftTracker{},
+		destinations: newDestinationTracker(),
+		plan:         newPlanTracker(),
+	}
+	t.Cleanup(func() { plans.forget(backend.InternalName("test", t.Name())) })
+	return j
+}
+
+func TestCreateOneDryRunPlan(t *testing.T) {
+	source := &manualRegexTestClient{secrets: map[string][]byte{
+		"kv/app": []byte(`{"user":"a","pass":"new","host":"h"}`),
+	}}
+	dest := &manualRegexTestClient{secrets: map[string][]byte{
+		"kv/update": []byte(`{"user":"a","pass":"old","port":"1"}`),
+		"kv/same":   []byte(`{"user":"a","pass":"new","host":"h"}`),
+	}}
+	j := planTestJob(t)
+	ctx := context.Background()
+	for _, p := range []string{"kv/update", "kv/same", "kv/create"} {
+		assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", p))
+	}
+	assert.Empty(t, dest.writes)
+
+	p := planResult(j, nil)
+	if !assert.NotNil(t, p) || !assert.Len(t, p.Changes, 3) {
+		return
+	}
+	assert.Equal(t, v1alpha1.PlanSummary{Create: 1, Update: 1, NoOp: 1}, p.Summary)
+	byPath := make(map[string]v1alpha1.PlannedChange)
+	for _, c := range p.Changes {
+		byPath[c.Path] = c
+	}
+	assert.Equal(t, v1alpha1.PlannedChange{
+		Driver:   "vault",
+		Location: destLocation(dest),
+		Path:     "kv/update",
+		Action:   v1alpha1.PlanActionUpdate,
+		Added:    []string{"host"},
+		Removed:  []string{"port"},
+		Changed:  []string{"pass"},
+	}, byPath["kv/update"])
+	assert.Equal(t, v1alpha1.PlanActionNoOp, byPath["kv/same"].Action)
+	assert.Equal(t, v1alpha1.PlanActionCreate, byPath["kv/create"].Action)
+	assert.Equal(t, []string{"host", "pass", "user"}, byPath["kv/create"].Added)
+}
+
+func TestPlanStoreMerge(t *testing.T) {
+	s := &planStore{plans: make(map[string]*v1alpha1.Plan)}
+	change := func(path string, a v1alpha1.PlanAction) map[string]v1alpha1.PlannedChange {
+		return map[string]v1alpha1.PlannedChange{
+			backend.InventoryKey("vault", "", path): {Driver: "vault", Path: path, Action: a},
+		}
+	}
+	s.update("test/app", change("kv/a", v1alpha1.PlanActionCreate), true)
+	p := s.update("test/app", change("kv/b", v1alpha1.PlanActionDelete), false)
+	assert.Len(t, p.Changes, 2)
+	assert.Equal(t, v1alpha1.PlanSummary{Create: 1, Delete: 1}, p.Summary)
+
+	// a complete sync replaces the plan
+	p = s.update("test/app", change("kv/a", v1alpha1.PlanActionNoOp), true)
+	assert.Len(t, p.Changes, 1)
+	assert.Equal(t, v1alpha1.PlanSummary{NoOp: 1}, p.Summary)
+}
+
+func TestPlanResult(t *testing.T) {
+	j := planTestJob(t)
+	j.plan.delete(&manualRegexTestClient{}, "kv/old")
+	p := planResult(j, nil)
+	require.NotNil(t, p)
+	assert.Equal(t, v1alpha1.PlanActionDelete, p.Changes[0].Action)
+	_, ok := plans.get(backend.InternalName("test", t.Name()))
+	assert.True(t, ok)
+
+	// the plan is forgotten once the sync is no longer a dry run
+	dryRun := false
+	j.SyncConfig.Spec.DryRun = &dryRun
+	assert.Nil(t, planResult(j, nil))
+	_, ok = plans.get(backend.InternalName("test", t.Name()))
+	assert.False(t, ok)
+}
@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxOrphansInEvent is the number of orphaned secrets listed in an event
+const maxOrphansInEvent = 10
+
+// destStoreKey returns the inventory key of the destination store
+func destStoreKey(d SyncClient) string {
+	return backend.StoreKey(string(d.Driver()), destLocation(d))
+}
+
+// recordJob returns the job as seen by the destination of the inventory
+// record. Records of stores since removed from the spec see the spec as is.
+func recordJob(j SyncJob, scs *SyncClients, r backend.InventoryRecord) SyncJob {
+	for _, d := range scs.Dest {
+		if destStoreKey(d) == r.StoreKey() {
+			return destJob(j, d)
+		}
+	}
+	return j
+}
+
+// orphans returns the inventory records of destination secrets which were
+// not desired by the full sync job
+func orphans(j SyncJob) []backend.InventoryRecord {
+	var o []backend.InventoryRecord
+	for _, r := range j.inventory.Records() {
+		if !j.destinations.isDesired(r.Key()) {
+			o = append(o, r)
+		}
+	}
+	return o
+}
+
+// orphanSummary lists the orphaned secrets for an event
+func orphanSummary(o []backend.InventoryRecord) string {
+	var paths []string
+	for i, r := range o {
+		if i == maxOrphansInEvent {
+			paths = append(paths, fmt.Sprintf("and %d more", len(o)-i))
+			break
+		}
+		paths = append(paths, fmt.Sprintf("%s: %s", r.Driver, r.Path))
+	}
+	return strings.Join(paths, ", ")
+}
+
+// destClients returns the destination client of each store of the job,
+// keyed by store key. The inventory is kept in the namespace of the sync
+// config, where it can be edited, so recorded secrets are only deleted from
+// stores which are still in the spec, using the clients of the spec.
+func destClients(scs *SyncClients) map[string]SyncClient {
+	clients := make(map[string]SyncClient)
+	for _, d := range scs.Dest {
+		clients[destStoreKey(d)] = d
+	}
+	return clients
+}
+
+// unmanaged splits the records into those of stores in the spec, and
+// those of stores which are not
+func unmanaged(clients map[string]SyncClient, rs []backend.InventoryRecord) (managed, removed []backend.InventoryRecord) {
+	for _, r := range rs {
+		if _, ok := clients[r.StoreKey()]; ok {
+			managed = append(managed, r)
+		} else {
+			removed = append(removed, r)
+		}
+	}
+	return managed, removed
+}
+
+// pruneOrphans deletes the destination secrets recorded in the inventory
+// which were not desired by a successful full sync. If the sync config does
+// not enable pruning, or is a dry run, the orphaned secrets are only reported.
+func pruneOrphans(ctx context.Context, scs *SyncClients, j SyncJob) error {
+	l := log.WithFields(log.Fields{
+		"action":    "pruneOrphans",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	// the records of a bidirectional sync are the versions of each pair,
+	// not destinations written from the source
+	if j.inventory == nil || !fullSync(j) || j.SyncConfig.Spec.Bidirectional != nil {
+		return nil
+	}
+	o := orphans(j)
+	if len(o) == 0 {
+		return nil
+	}
+	prune := j.SyncConfig.Spec.Prune != nil && *j.SyncConfig.Spec.Prune
+	l = l.WithField("orphans", len(o))
+	if !prune {
+		msg := fmt.Sprintf("found %d orphaned destination secrets, set spec.prune to delete them: %s", len(o), orphanSummary(o))
+		l.Info(msg)
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "Orphaned", msg)
+		return nil
+	}
+	// orphans of suspended destinations are kept, and orphans of dry run
+	// destinations are only planned
+	var planned []backend.InventoryRecord
+	var deletes []backend.InventoryRecord
+	plannedJob, deleteJob := j, j
+	for _, r := range o {
+		rj := recordJob(j, scs, r)
+		switch {
+		case isSuspended(rj):
+		case isDryRun(rj):
+			planned = append(planned, r)
+			plannedJob = rj
+		default:
+			deletes = append(deletes, r)
+			deleteJob = rj
+		}
+	}
+	if len(planned) > 0 {
+		msg := fmt.Sprintf("dry run: would prune %d orphaned destination secrets: %s", len(planned), orphanSummary(planned))
+		l.Info(msg)
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "Orphaned", msg)
+		for _, r := range planned {
+			j.plan.orphan(r)
+		}
+		if err := reserveDeletes(ctx, plannedJob, len(planned)); err != nil {
+			return err
+		}
+	}
+	clients := destClients(scs)
+	o, removed := unmanaged(clients, deletes)
+	if len(removed) > 0 {
+		// the secrets of stores removed from the spec are no longer tracked,
+		// and must be deleted by hand
+		msg := fmt.Sprintf("not pruning %d orphaned destination secrets of stores no longer in spec.dest, delete them by hand: %s", len(removed), orphanSummary(removed))
+		l.Warn(msg)
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "Orphaned", msg)
+		for _, r := range removed {
+			j.inventory.Delete(r.Key())
+		}
+	}
+	if len(o) == 0 {
+		return nil
+	}
+	if err := reserveDeletes(ctx, deleteJob, len(o)); err != nil {
+		return err
+	}
+	var errs []error
+	var pruned []backend.InventoryRecord
+	for _, r := range o {
+		d := clients[r.StoreKey()]
+		if err := deleteSecret(ctx, j, d, r.Path); err != nil {
+			l.WithError(err).WithFields(log.Fields{"driver": r.Driver, "path": r.Path}).Error("failed to prune secret")
+			errs = append(errs, driver.Classify(d, err))
+			continue
+		}
+		metrics.PrunedSecrets.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name, r.Driver).Inc()
+		pruned = append(pruned, r)
+	}
+	if len(pruned) > 0 {
+		msg := fmt.Sprintf("pruned %d orphaned destination secrets: %s", len(pruned), orphanSummary(pruned))
+		if grace := deletionGracePeriod(j.SyncConfig); grace > 0 {
+			msg = fmt.Sprintf("scheduled %d orphaned destination secrets for deletion in %s: %s", len(pruned), grace, orphanSummary(pruned))
+		}
+		backend.WriteEvent(
+			ctx,
+			j.SyncConfig.Namespace,
+			j.SyncConfig.Name,
+			"Normal",
+			"Pruned",
+			msg,
+		)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func pruneTestJob(t *testing.T, uid string, prune, dryRun bool) SyncJob {
+	t.Helper()
+	cfg := v1alpha1.VaultSecretSync{
+		ObjectMeta: metav1.ObjectMeta{Name: "prune", Namespace: "test", UID: types.UID(uid)},
+		Spec: v1alpha1.VaultSecretSyncSpec{
+			Source: &vault.VaultClient{Path: "kv/app"},
+			Prune:  &prune,
+			DryRun: &dryRun,
+		},
+	}
+	inv, err := backend.GetInventory(context.Background(), cfg)
+	assert.NoError(t, err)
+	return SyncJob{
+		VaultEvent:   event.VaultEvent{Manual: true, Operation: logical.UpdateOperation},
+		SyncConfig:   cfg,
+		inventory:    inv,
+		destinations: newDestinationTracker(),
+	}
+}
+
+func TestPruneOrphans(t *testing.T) {
+	tests := []struct {
+		name    string
+		prune   bool
+		dryRun  bool
+		deletes []string
+	}{
+		{name: "prune", prune: true, deletes: []string{"kv/old"}},
+		{name: "report only", prune: false},
+		{name: "dry run", prune: true, dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"a"}`)}}
+			dest := &manualRegexTestClient{}
+			scs := &SyncClients{Source: source, Dest: []SyncClient{dest}}
+
+			j := pruneTestJob(t, "prune-"+tt.name, tt.prune, tt.dryRun)
+			recordWrite(j, dest, "kv/old", "kv/old", "h")
+			assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/copy"))
+			assert.NoError(t, pruneOrphans(ctx, scs, j))
+			assert.Equal(t, tt.deletes, dest.deletes)
+
+			_, kept := j.inventory.Get(destInventoryKey(dest, "kv/old"))
+			assert.Equal(t, len(tt.deletes) == 0, kept)
+		})
+	}
+}
+
+func TestPruneOrphansOnlyAfterFullSync(t *testing.T) {
+	ctx := context.Background()
+	dest := &manualRegexTestClient{}
+	scs := &SyncClients{Dest: []SyncClient{dest}}
+
+	j := pruneTestJob(t, "prune-event", true, false)
+	j.VaultEvent.Manual = false
+	recordWrite(j, dest, "kv/old", "kv/old", "h")
+	assert.NoError(t, pruneOrphans(ctx, scs, j))
+	assert.Empty(t, dest.deletes)
+}
+
+func TestPruneOrphansRemovedStore(t *testing.T) {
+	ctx := context.Background()
+	dest := &manualRegexTestClient{}
+	scs := &SyncClients{Dest: []SyncClient{dest}}
+	j := pruneTestJob(t, "prune-removed-store", true, false)
+	// records of stores not in the spec, such as those added by editing the
+	// state secret, are never deleted
+	other := backend.InventoryRecord{Driver: "vault", Location: "https://other.example.com|", Path: "kv/other"}
+	j.inventory.Put(other)
+	j.inventory.PutTombstone(backend.Tombstone{InventoryRecord: backend.InventoryRecord{Driver: "vault", Location: "https://other.example.com|", Path: "kv/pending"}})
+	recordWrite(j, dest, "kv/old", "kv/old", "h")
+
+	assert.NoError(t, pruneOrphans(ctx, scs, j))
+	assert.Equal(t, []string{"kv/old"}, dest.deletes)
+	assert.Empty(t, j.inventory.Records())
+
+	assert.NoError(t, processPendingDeletes(ctx, scs, j))
+	assert.Equal(t, []string{"kv/old"}, dest.deletes)
+	assert.Empty(t, j.inventory.Tombstones())
+}
@@ -3,11 +3,10 @@ package sync
 import (
 	"context"
 	"fmt"
-	"path"
 	"regexp"
-	"strings"
 
 	"github.com/robertlestak/vault-secret-sync/internal/transforms"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,11 +18,12 @@ type manualSyncTask struct {
 
 func manualRegexSyncWorker(ctx context.Context, j SyncJob, taskCh chan manualSyncTask, errCh chan error) {
 	for task := range taskCh {
-		if shouldFilterSecret(j, j.SyncConfig.Spec.Source.GetPath(), task.dest.GetPath()) {
+		dj := destJob(j, task.dest)
+		if shouldFilterSecret(dj, j.SyncConfig.Spec.Source.GetPath(), task.dest.GetPath()) {
 			errCh <- nil
 			continue
 		}
-		if shouldDryRun(j, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.rewritePath) {
+		if shouldSuspend(ctx, dj, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.rewritePath) {
 			errCh <- nil
 			continue
 		}
@@ -79,15 +79,13 @@ func handleManualRegexSync(ctx context.Context, sc *SyncClients, j SyncJob) erro
 	taskCh := make(chan manualSyncTask, len(sc.Dest)*len(list))
 	errCh := make(chan error, len(sc.Dest)*len(list))
 
-	// Number of worker goroutines
-	const numWorkers = 10 // Adjust this number based on your requirements
-
 	// Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
+	for i := 0; i < DefaultRegexWorkers; i++ {
 		go manualRegexSyncWorker(ctx, j, taskCh, errCh)
 	}
 
 	// Create tasks and send them to the task channel
+	var errors []error
 	taskCount := 0
 	for _, d := range sc.Dest {
 		ll := log.WithFields(log.Fields{"store": d.Driver()})
@@ -97,19 +95,11 @@ func handleManualRegexSync(ctx context.Context, sc *SyncClients, j SyncJob) erro
 				ll.WithField("path", p).Debug("skipping non-matching path")
 				continue
 			}
-			matches := rx.FindStringSubmatch(p)
-			rewritePath := d.GetPath()
-
-			if hasCaptureGroups(sourcePath) {
-				for i, match := range matches {
-					if i == 0 {
-						continue
-					}
-					groupName := fmt.Sprintf("$%d", i)
-					rewritePath = strings.ReplaceAll(rewritePath, groupName, match)
-				}
-			} else {
-				rewritePath = path.Join(rewritePath, p[len(highestNonRegexPath):])
+			rewritePath, err := destPath(j, d, sourcePath, rx, p)
+			if err != nil {
+				ll.WithField("path", p).Error(err)
+				errors = append(errors, driver.Permanent(err))
+				continue
 			}
 
 			taskCh <- manualSyncTask{dest: d, srcPath: p, rewritePath: rewritePath}
@@ -118,7 +108,6 @@ func handleManualRegexSync(ctx context.Context, sc *SyncClients, j SyncJob) erro
 	}
 	close(taskCh)
 
-	var errors []error
 	for i := 0; i < taskCount; i++ {
 		if err := <-errCh; err != nil {
 			errors = append(errors, err)
@@ -127,7 +116,7 @@ func handleManualRegexSync(ctx context.Context, sc *SyncClients, j SyncJob) erro
 	close(errCh)
 
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 
 	l.Debug("manual regex sync complete")
@@ -142,11 +131,12 @@ type syncTask struct {
 
 func regexSyncWorker(ctx context.Context, j SyncJob, taskCh chan syncTask, errCh chan error) {
 	for task := range taskCh {
-		if shouldFilterSecret(j, j.SyncConfig.Spec.Source.GetPath(), task.dest.GetPath()) {
+		dj := destJob(j, task.dest)
+		if shouldFilterSecret(dj, j.SyncConfig.Spec.Source.GetPath(), task.dest.GetPath()) {
 			errCh <- nil
 			continue
 		}
-		if shouldDryRun(j, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.rewritePath) {
+		if shouldSuspend(ctx, dj, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.rewritePath) {
 			errCh <- nil
 			continue
 		}
@@ -184,41 +174,31 @@ func handleRegexSync(ctx context.Context, sc *SyncClients, j SyncJob) error {
 	}
 	l.Debug("regex match")
 	sp := strippedPath(j.VaultEvent.Path)
-	matches := rx.FindStringSubmatch(sp)
-
 	taskCh := make(chan syncTask, len(sc.Dest))
 	errCh := make(chan error, len(sc.Dest))
 
-	// Number of worker goroutines
-	const numWorkers = 10 // Adjust this number based on your requirements
-
 	// Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
+	for i := 0; i < DefaultRegexWorkers; i++ {
 		go regexSyncWorker(ctx, j, taskCh, errCh)
 	}
 
 	// Create tasks and send them to the task channel
+	var errors []error
+	taskCount := 0
 	for _, d := range sc.Dest {
-		rewritePath := d.GetPath()
-
-		if hasCaptureGroups(sc.Source.GetPath()) {
-			for i, match := range matches {
-				if i == 0 {
-					continue
-				}
-				groupName := fmt.Sprintf("$%d", i)
-				rewritePath = strings.ReplaceAll(rewritePath, groupName, match)
-			}
-		} else {
-			rewritePath = path.Join(rewritePath, sp[len(findHighestNonRegexPath(sc.Source.GetPath())):])
+		rewritePath, err := destPath(j, d, sc.Source.GetPath(), rx, sp)
+		if err != nil {
+			l.WithField("store", d.Driver()).Error(err)
+			errors = append(errors, driver.Permanent(err))
+			continue
 		}
 
 		taskCh <- syncTask{dest: d, srcPath: sp, rewritePath: rewritePath}
+		taskCount++
 	}
 	close(taskCh)
 
-	var errors []error
-	for i := 0; i < len(sc.Dest); i++ {
+	for i := 0; i < taskCount; i++ {
 		if err := <-errCh; err != nil {
 			errors = append(errors, err)
 		}
@@ -226,33 +206,25 @@ func handleRegexSync(ctx context.Context, sc *SyncClients, j SyncJob) error {
 	close(errCh)
 
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 
 	return nil
 }
 
 type manualDeleteTask struct {
-	dest        SyncClient
-	rewritePath string
+	dest  SyncClient
+	paths []string
 }
 
 func manualRegexDeleteWorker(ctx context.Context, j SyncJob, taskCh chan manualDeleteTask, errCh chan error) {
 	for task := range taskCh {
-		if shouldFilterSecret(j, j.SyncConfig.Spec.Source.GetPath(), task.dest.GetPath()) {
+		dj := destJob(j, task.dest)
+		if !deletesFrom(dj, task.dest) {
 			errCh <- nil
 			continue
 		}
-		if shouldDryRun(j, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.rewritePath) {
-			errCh <- nil
-			continue
-		}
-		if err := task.dest.DeleteSecret(ctx, task.rewritePath); err != nil {
-			log.WithError(err).Error("delete job failed")
-			errCh <- err
-		} else {
-			errCh <- nil
-		}
+		errCh <- deletePaths(ctx, dj, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.paths)
 	}
 }
 
@@ -272,36 +244,41 @@ func handleManualRegexDelete(ctx context.Context, sc *SyncClients, j SyncJob) er
 		return err
 	}
 
-	taskCh := make(chan manualDeleteTask, len(sc.Dest)*len(list))
-	errCh := make(chan error, len(sc.Dest)*len(list))
-
-	// Number of worker goroutines
-	const numWorkers = 10 // Adjust this number based on your requirements
-
-	// Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
-		go manualRegexDeleteWorker(ctx, j, taskCh, errCh)
-	}
-
-	// Create tasks and send them to the task channel
-	taskCount := 0
+	// Create the tasks, and reserve their deletes before deleting anything
+	var tasks []manualDeleteTask
+	deletes := make(map[SyncClient]int)
 	for _, d := range sc.Dest {
 		for _, p := range list {
 			if !rx.MatchString(p) {
 				continue
 			}
-			matches := rx.FindStringSubmatch(p)
-			if len(matches) > 0 {
-				rewritePath := d.GetPath()
-				for i, match := range matches {
-					groupName := fmt.Sprintf("$%d", i)
-					rewritePath = strings.ReplaceAll(rewritePath, groupName, match)
-				}
-				taskCh <- manualDeleteTask{dest: d, rewritePath: rewritePath}
-				taskCount++
+			rewritePath, err := destPath(j, d, sc.Source.GetPath(), rx, p)
+			if err != nil {
+				return driver.Permanent(err)
+			}
+			paths := destDeletePaths(j, d, p, rewritePath)
+			tasks = append(tasks, manualDeleteTask{dest: d, paths: paths})
+			if deletesFrom(destJob(j, d), d) {
+				deletes[d] += len(paths)
 			}
 		}
 	}
+	if err := reserveDestDeletes(ctx, j, sc.Dest, deletes); err != nil {
+		return err
+	}
+	taskCount := len(tasks)
+	taskCh := make(chan manualDeleteTask, taskCount)
+	errCh := make(chan error, taskCount)
+
+	// Start worker goroutines
+	for i := 0; i < DefaultRegexWorkers; i++ {
+		go manualRegexDeleteWorker(ctx, j, taskCh, errCh)
+	}
+
+	// Send the tasks to the task channel
+	for _, task := range tasks {
+		taskCh <- task
+	}
 	close(taskCh)
 
 	var errors []error
@@ -313,33 +290,25 @@ func handleManualRegexDelete(ctx context.Context, sc *SyncClients, j SyncJob) er
 	close(errCh)
 
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 
 	return nil
 }
 
 type deleteTask struct {
-	dest        SyncClient
-	rewritePath string
+	dest  SyncClient
+	paths []string
 }
 
 func regexDeleteWorker(ctx context.Context, j SyncJob, taskCh chan deleteTask, errCh chan error) {
 	for task := range taskCh {
-		if shouldFilterSecret(j, j.SyncConfig.Spec.Source.GetPath(), task.dest.GetPath()) {
-			errCh <- nil
-			continue
-		}
-		if shouldDryRun(j, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.rewritePath) {
+		dj := destJob(j, task.dest)
+		if !deletesFrom(dj, task.dest) {
 			errCh <- nil
 			continue
 		}
-		if err := task.dest.DeleteSecret(ctx, task.rewritePath); err != nil {
-			log.WithError(err).Error("delete job failed")
-			errCh <- err
-		} else {
-			errCh <- nil
-		}
+		errCh <- deletePaths(ctx, dj, task.dest, j.SyncConfig.Spec.Source.GetPath(), task.paths)
 	}
 }
 
@@ -357,37 +326,37 @@ func handleRegexDelete(ctx context.Context, sc *SyncClients, j SyncJob) error {
 	}
 	l.Debug("regex match")
 	sp := strippedPath(j.VaultEvent.Path)
-	matches := rx.FindStringSubmatch(sp)
-	l.WithFields(log.Fields{"matches": matches}).Debug("found matches")
+	l.WithFields(log.Fields{"matches": rx.FindStringSubmatch(sp)}).Debug("found matches")
+
+	// Create the tasks, and reserve their deletes before deleting anything
+	var tasks []deleteTask
+	deletes := make(map[SyncClient]int)
+	for _, d := range sc.Dest {
+		rewritePath, err := destPath(j, d, sc.Source.GetPath(), rx, sp)
+		if err != nil {
+			return driver.Permanent(err)
+		}
+		paths := destDeletePaths(j, d, sp, rewritePath)
+		tasks = append(tasks, deleteTask{dest: d, paths: paths})
+		if deletesFrom(destJob(j, d), d) {
+			deletes[d] += len(paths)
+		}
+	}
+	if err := reserveDestDeletes(ctx, j, sc.Dest, deletes); err != nil {
+		return err
+	}
 
 	taskCh := make(chan deleteTask, len(sc.Dest))
 	errCh := make(chan error, len(sc.Dest))
 
-	// Number of worker goroutines
-	const numWorkers = 10 // Adjust this number based on your requirements
-
 	// Start worker goroutines
-	for i := 0; i < numWorkers; i++ {
+	for i := 0; i < DefaultRegexWorkers; i++ {
 		go regexDeleteWorker(ctx, j, taskCh, errCh)
 	}
 
-	// Create tasks and send them to the task channel
-	for _, d := range sc.Dest {
-		rewritePath := d.GetPath()
-
-		if hasCaptureGroups(sc.Source.GetPath()) {
-			for i, match := range matches {
-				if i == 0 {
-					continue
-				}
-				groupName := fmt.Sprintf("$%d", i)
-				rewritePath = strings.ReplaceAll(rewritePath, groupName, match)
-			}
-		} else {
-			rewritePath = path.Join(rewritePath, sp[len(findHighestNonRegexPath(sc.Source.GetPath())):])
-		}
-
-		taskCh <- deleteTask{dest: d, rewritePath: rewritePath}
+	// Send the tasks to the task channel
+	for _, task := range tasks {
+		taskCh <- task
 	}
 	close(taskCh)
 
@@ -400,7 +369,7 @@ func handleRegexDelete(ctx context.Context, sc *SyncClients, j SyncJob) error {
 	close(errCh)
 
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 
 	return nil
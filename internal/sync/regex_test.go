@@ -79,7 +79,9 @@ func (m *manualRegexTestClient) Close() error {
 	return nil
 }
 
-func manualRegexSyncJob(sourcePath string, dryRun bool) SyncJob {
+// manualRegexSyncJob returns a manual regex sync job. Suspended jobs match
+// secrets without reading or writing them.
+func manualRegexSyncJob(sourcePath string, suspend bool) SyncJob {
 	return SyncJob{
 		SyncConfig: v1alpha1.VaultSecretSync{
 			ObjectMeta: metav1.ObjectMeta{
@@ -87,8 +89,8 @@ func manualRegexSyncJob(sourcePath string, dryRun bool) SyncJob {
 				Namespace: "test-namespace",
 			},
 			Spec: v1alpha1.VaultSecretSyncSpec{
-				Source: &vault.VaultClient{Path: sourcePath},
-				DryRun: &dryRun,
+				Source:  &vault.VaultClient{Path: sourcePath},
+				Suspend: &suspend,
 			},
 		},
 	}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+// destReplicates returns true if the destination replicates source secrets
+// with their metadata, rather than writing their data
+func destReplicates(d SyncClient) bool {
+	r, ok := d.Meta()["replicate"].(bool)
+	return ok && r
+}
+
+// destReplicatesVersions returns true if the destination also replicates the
+// version history of source secrets
+func destReplicatesVersions(d SyncClient) bool {
+	r, ok := d.Meta()["replicateVersions"].(bool)
+	return ok && r
+}
+
+// createReplica replicates the source secret with its metadata, and its
+// versions if the destination replicates versions, to the destination.
+// Replicas are copied as is, so transforms and metadata propagation do not
+// apply to them.
+func createReplica(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePath, destPath string) error {
+	l := log.WithFields(log.Fields{
+		"action":      "createReplica",
+		"source.Path": sourcePath,
+		"dest.Path":   destPath,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	src, sok := source.(driver.Replicator)
+	dst, dok := dest.(driver.Replicator)
+	var err error
+	switch {
+	case source.Driver() != dest.Driver():
+		err = fmt.Errorf("replicate requires a %s source, not %s", dest.Driver(), source.Driver())
+	case !sok || !dok:
+		err = fmt.Errorf("replicate is not supported by the %s driver", dest.Driver())
+	}
+	if err != nil {
+		return handleCreateOneError(ctx, driver.Permanent(err), j, dest, sourcePath, destPath)
+	}
+	if shouldSuspend(ctx, j, dest, sourcePath, destPath) {
+		return nil
+	}
+	cancelPendingDelete(ctx, j, dest, destPath)
+
+	var r *driver.Replica
+	err = limited(ctx, source, func(ctx context.Context) error {
+		var err error
+		r, err = src.ReadReplica(ctx, sourcePath, destReplicatesVersions(dest))
+		return err
+	})
+	if err != nil {
+		return handleCreateOneError(ctx, driver.Classify(source, err), j, dest, sourcePath, destPath)
+	}
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return handleCreateOneError(ctx, driver.Permanent(err), j, dest, sourcePath, destPath)
+	}
+	hash := payloadHash(j, payload)
+	if !j.VaultEvent.Force && unchanged(j, dest, destPath, hash) {
+		l.Debug("replica unchanged, skipping write")
+		j.destinations.success(dest, destPath, hash)
+		return nil
+	}
+
+	if err := checkOwnership(ctx, j, dest, destPath, payload); err != nil {
+		return handleCreateOneError(ctx, err, j, dest, sourcePath, destPath)
+	}
+	werr := limited(ctx, dest, func(ctx context.Context) error {
+		return dst.WriteReplica(ctx, j.SyncConfig.ObjectMeta, destPath, r)
+	})
+	if werr != nil {
+		return handleCreateOneError(ctx, driver.Classify(dest, werr), j, dest, sourcePath, destPath)
+	}
+	recordWrite(j, dest, sourcePath, destPath, hash)
+	j.destinations.success(dest, destPath, hash)
+
+	return handleCreateOneSuccess(ctx, j, dest, sourcePath, destPath)
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// replicaTestClient is a test store which replicates secrets
+type replicaTestClient struct {
+	manualRegexTestClient
+	versions bool
+	replicas map[string]*driver.Replica
+	history  []bool
+}
+
+func (c *replicaTestClient) Meta() map[string]any {
+	return map[string]any{"replicate": true, "replicateVersions": c.versions}
+}
+
+func (c *replicaTestClient) ReadReplica(_ context.Context, path string, history bool) (*driver.Replica, error) {
+	c.history = append(c.history, history)
+	return c.replicas[path], nil
+}
+
+func (c *replicaTestClient) WriteReplica(_ context.Context, _ metav1.ObjectMeta, path string, r *driver.Replica) error {
+	if c.replicas == nil {
+		c.replicas = make(map[string]*driver.Replica)
+	}
+	c.replicas[path] = r
+	return nil
+}
+
+func TestCreateReplica(t *testing.T) {
+	ctx := context.Background()
+	replica := &driver.Replica{
+		MaxVersions:    5,
+		CurrentVersion: 1,
+		History:        true,
+		Versions:       []driver.SecretVersion{{Version: 1, Data: map[string]any{"a": "b"}}},
+	}
+	source := &replicaTestClient{replicas: map[string]*driver.Replica{"kv/app": replica}}
+	dest := &replicaTestClient{versions: true}
+
+	j := pruneTestJob(t, "replicate", false, false)
+	j.sources = newSourceCache()
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/dr/app"))
+	assert.Equal(t, replica, dest.replicas["kv/dr/app"])
+	assert.Equal(t, []bool{true}, source.history)
+	assert.Empty(t, dest.writes)
+
+	// an unchanged replica is not written again
+	delete(dest.replicas, "kv/dr/app")
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "kv/dr/app"))
+	assert.Nil(t, dest.replicas["kv/dr/app"])
+
+	// replicas require a source which replicates
+	other := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"a":"b"}`)}}
+	err := CreateOne(ctx, j, other, dest, "kv/app", "kv/dr/other")
+	assert.ErrorContains(t, err, "replicate is not supported by the vault driver")
+	assert.True(t, driver.IsPermanent(err))
+}
@@ -0,0 +1,225 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/metrics"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// DefaultRetryMaxAttempts is the maximum number of attempts to sync an
+	// event when a VaultSecretSync does not set spec.retry.maxAttempts
+	DefaultRetryMaxAttempts = 5
+	// DefaultRetryInitialBackoff is the delay before the first retry when a
+	// VaultSecretSync does not set spec.retry.initialBackoff
+	DefaultRetryInitialBackoff = 5 * time.Second
+	// DefaultRetryMaxBackoff is the maximum delay between retries when a
+	// VaultSecretSync does not set spec.retry.maxBackoff
+	DefaultRetryMaxBackoff = 5 * time.Minute
+
+	// retryAfter publishes the event after the delay
+	retryAfter = func(d time.Duration, evt event.VaultEvent) {
+		pendingRetries.add(d, evt)
+	}
+
+	pendingRetries = &retryTimers{timers: make(map[*time.Timer]event.VaultEvent)}
+)
+
+// retryTimers are the retries waiting out their backoff, which are
+// published immediately on shutdown rather than lost
+type retryTimers struct {
+	mu     sync.Mutex
+	timers map[*time.Timer]event.VaultEvent
+}
+
+func publishRetry(ctx context.Context, evt event.VaultEvent) {
+	if err := queue.Q.Publish(ctx, evt); err != nil {
+		log.WithError(err).WithField("syncName", evt.SyncName).Error("failed to publish retry")
+	}
+}
+
+// add publishes the event after the delay
+func (r *retryTimers) add(d time.Duration, evt event.VaultEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		_, ok := r.timers[t]
+		delete(r.timers, t)
+		r.mu.Unlock()
+		if ok {
+			publishRetry(context.Background(), evt)
+		}
+	})
+	r.timers[t] = evt
+}
+
+// flush publishes every pending retry now
+func (r *retryTimers) flush(ctx context.Context) int {
+	r.mu.Lock()
+	var evts []event.VaultEvent
+	for t, evt := range r.timers {
+		if t.Stop() {
+			evts = append(evts, evt)
+		}
+		delete(r.timers, t)
+	}
+	r.mu.Unlock()
+	for _, evt := range evts {
+		publishRetry(ctx, evt)
+	}
+	return len(evts)
+}
+
+// flushRetries publishes the pending retries without waiting out their backoff
+func flushRetries(ctx context.Context) {
+	if n := pendingRetries.flush(ctx); n > 0 {
+		log.WithFields(log.Fields{"action": "flushRetries", "retries": n}).Info("published pending retries")
+	}
+}
+
+// retryPolicy is the effective retry policy of a sync config
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// syncRetryPolicy returns the retry policy of the sync config, falling
+// back to the operator defaults for any unset fields
+func syncRetryPolicy(s v1alpha1.VaultSecretSync) retryPolicy {
+	p := retryPolicy{
+		maxAttempts:    DefaultRetryMaxAttempts,
+		initialBackoff: DefaultRetryInitialBackoff,
+		maxBackoff:     DefaultRetryMaxBackoff,
+	}
+	if r := s.Spec.Retry; r != nil {
+		if r.MaxAttempts != nil {
+			p.maxAttempts = *r.MaxAttempts
+		}
+		if r.InitialBackoff != nil {
+			p.initialBackoff = r.InitialBackoff.Duration
+		}
+		if r.MaxBackoff != nil {
+			p.maxBackoff = r.MaxBackoff.Duration
+		}
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry, starting at 1. The
+// delay doubles on each retry up to the max backoff, and is jittered
+// between half and all of the delay.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	d := p.initialBackoff
+	for i := 1; i < retry && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryEvent returns the event to publish to retry the failed job, and
+// false if the error is permanent or the job has exhausted its attempts
+func retryEvent(j SyncJob, err error) (event.VaultEvent, bool) {
+	attempts := j.VaultEvent.Attempt + 1
+	if driver.IsPermanent(err) || attempts >= syncRetryPolicy(j.SyncConfig).maxAttempts {
+		return event.VaultEvent{}, false
+	}
+	evt := j.VaultEvent
+	evt.ID = ""
+	evt.Attempt = attempts
+	// an event can match many sync configs, only retry the one which failed
+	evt.SyncName = backend.InternalName(j.SyncConfig.Namespace, j.SyncConfig.Name)
+	return evt, true
+}
+
+// scheduleRetry schedules a retry of the failed job with backoff,
+// returning false if the job will not be retried
+func scheduleRetry(j SyncJob, err error) bool {
+	evt, ok := retryEvent(j, err)
+	if !ok {
+		return false
+	}
+	d := syncRetryPolicy(j.SyncConfig).backoff(evt.Attempt)
+	log.WithFields(log.Fields{
+		"action":    "scheduleRetry",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+		"attempt":   evt.Attempt,
+		"backoff":   d,
+	}).Info("retrying failed sync")
+	metrics.SyncRetries.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Inc()
+	retryAfter(d, evt)
+	return true
+}
+
+// deadLetter records the failed job as a dead letter
+func deadLetter(ctx context.Context, j SyncJob, err error) {
+	metrics.DeadLetters.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Inc()
+	dl := v1alpha1.DeadLetter{
+		Operation: string(j.VaultEvent.Operation),
+		Path:      j.VaultEvent.Path,
+		Manual:    j.VaultEvent.Manual,
+		Attempts:  j.VaultEvent.Attempt + 1,
+		Error:     err.Error(),
+		Time:      metav1.Now(),
+		// a failed rollback is redriven as a rollback
+		SourceVersion: j.VaultEvent.SourceVersion,
+	}
+	if err := backend.AddDeadLetter(ctx, j.SyncConfig, dl); err != nil {
+		log.WithError(err).Error("failed to add dead letter")
+	}
+	backend.WriteEvent(
+		ctx,
+		j.SyncConfig.Namespace,
+		j.SyncConfig.Name,
+		"Warning",
+		"DeadLetter",
+		fmt.Sprintf("sync failed after %d attempts: %s", dl.Attempts, dl.Error),
+	)
+}
+
+// RedriveTrigger syncs a dead letter of the sync config again
+func RedriveTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, dl v1alpha1.DeadLetter) error {
+	l := log.WithFields(log.Fields{"action": "RedriveTrigger"})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l = l.WithFields(log.Fields{"name": name, "path": dl.Path, "op": dl.Operation})
+	l.Debug("redrive trigger")
+	evt := event.VaultEvent{
+		SyncName:      name,
+		Path:          dl.Path,
+		Operation:     logical.Operation(dl.Operation),
+		Manual:        dl.Manual,
+		SourceVersion: dl.SourceVersion,
+	}
+	if cfg.Spec.Source != nil {
+		evt.Address = cfg.Spec.Source.Address
+		evt.Namespace = cfg.Spec.Source.Namespace
+	}
+	// publish rather than push so that redriving many dead letters
+	// waits for the queue instead of dropping events
+	return queue.Q.Publish(ctx, evt)
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := retryPolicy{maxAttempts: 10, initialBackoff: time.Second, maxBackoff: 10 * time.Second}
+	for retry, full := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(retry)
+			assert.GreaterOrEqual(t, d, full/2, "retry %d", retry)
+			assert.LessOrEqual(t, d, full, "retry %d", retry)
+		}
+	}
+}
+
+func TestSyncRetryPolicy(t *testing.T) {
+	s := v1alpha1.VaultSecretSync{}
+	assert.Equal(t, DefaultRetryMaxAttempts, syncRetryPolicy(s).maxAttempts)
+
+	attempts := 1
+	s.Spec.Retry = &v1alpha1.RetryPolicy{
+		MaxAttempts: &attempts,
+		MaxBackoff:  &metav1.Duration{Duration: time.Minute},
+	}
+	p := syncRetryPolicy(s)
+	assert.Equal(t, 1, p.maxAttempts)
+	assert.Equal(t, DefaultRetryInitialBackoff, p.initialBackoff)
+	assert.Equal(t, time.Minute, p.maxBackoff)
+}
+
+func TestRetryEvent(t *testing.T) {
+	attempts := 3
+	j := SyncJob{
+		VaultEvent: event.VaultEvent{ID: "1", Path: "kv/data/app", Operation: logical.UpdateOperation},
+		SyncConfig: v1alpha1.VaultSecretSync{
+			ObjectMeta: metav1.ObjectMeta{Name: "retry", Namespace: "test"},
+			Spec:       v1alpha1.VaultSecretSyncSpec{Retry: &v1alpha1.RetryPolicy{MaxAttempts: &attempts}},
+		},
+	}
+	transient := joinErrors([]error{errors.New("throttled"), driver.Permanent(errors.New("denied"))})
+
+	evt, ok := retryEvent(j, transient)
+	assert.True(t, ok)
+	assert.Equal(t, 1, evt.Attempt)
+	assert.Empty(t, evt.ID)
+	assert.Equal(t, "test/retry", evt.SyncName)
+	assert.Equal(t, "kv/data/app", evt.Path)
+
+	j.VaultEvent = evt
+	evt, ok = retryEvent(j, transient)
+	assert.True(t, ok)
+	assert.Equal(t, 2, evt.Attempt)
+
+	// attempts are exhausted
+	j.VaultEvent = evt
+	_, ok = retryEvent(j, transient)
+	assert.False(t, ok)
+
+	// permanent errors are not retried
+	j.VaultEvent.Attempt = 0
+	permanent := joinErrors([]error{driver.Permanent(errors.New("denied")), driver.Permanent(errors.New("invalid"))})
+	_, ok = retryEvent(j, permanent)
+	assert.False(t, ok)
+	assert.Equal(t, "errors: [denied invalid]", permanent.Error())
+}
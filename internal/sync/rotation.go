@@ -0,0 +1,223 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/robertlestak/vault-secret-sync/internal/rotation"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateRotation returns an error if the sync config rotates its source
+// secret and the rotation is invalid
+func validateRotation(sc v1alpha1.VaultSecretSync) error {
+	rc := sc.Spec.Rotation
+	if rc == nil {
+		return nil
+	}
+	src := sc.Spec.Source
+	switch {
+	case src == nil || src.Path == "" || isRegexPath(src.Path) || isTemplatePath(src.Path):
+		return errors.New("rotation requires an exact source path")
+	case src.Version > 0:
+		return errors.New("rotation cannot be set with a pinned source version")
+	case src.Merge:
+		return errors.New("rotation cannot be set with merge")
+	}
+	return rotation.Validate(*rc)
+}
+
+// RotationTrigger runs the phase of the rotation of the source secret of
+// the sync config, then syncs every destination. The rotation schedule
+// determines when rotations run, so they are not held by sync windows.
+func RotationTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, phase backend.RotationPhase) error {
+	l := log.WithFields(log.Fields{"action": "RotationTrigger"})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l = l.WithFields(log.Fields{"name": name, "phase": phase})
+	l.Debug("rotation trigger")
+	evt := event.VaultEvent{
+		SyncName:         name,
+		Operation:        logical.UpdateOperation,
+		Manual:           true,
+		Force:            true,
+		IgnoreSyncWindow: true,
+		Rotation:         string(phase),
+	}
+	return queue.Q.Push(evt)
+}
+
+// rotationVerifyBody is the body of the verify request of a rotation. Only
+// key names are included, never values.
+type rotationVerifyBody struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Path      string    `json:"path"`
+	Keys      []string  `json:"keys"`
+	Time      time.Time `json:"time"`
+}
+
+// writeRotation writes the data to the source secret as a new version. The
+// source is not owned by the sync, so it is written without an owner.
+func writeRotation(ctx context.Context, source SyncClient, path string, data []byte) error {
+	err := limited(ctx, source, func(ctx context.Context) error {
+		_, err := source.WriteSecret(ctx, metav1.ObjectMeta{}, path, data)
+		return err
+	})
+	return driver.Classify(source, err)
+}
+
+// syncRotation runs the rotation phase of the job on the source secret,
+// writing the rotated secret to Vault as a new version, and syncs it to
+// every destination. The verify request of the rotation is then made. If
+// the sync or the verify request fail, the previous secret is written to
+// Vault again and synced, and the rotation fails. Dry runs and suspended
+// syncs are synced without rotating.
+func syncRotation(ctx context.Context, scs *SyncClients, j SyncJob) error {
+	phase := backend.RotationPhase(j.VaultEvent.Rotation)
+	l := log.WithFields(log.Fields{
+		"action":    "syncRotation",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+		"phase":     phase,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	rc := j.SyncConfig.Spec.Rotation
+	if rc == nil || isDryRun(j) || j.SyncConfig.Suspended() {
+		l.Info("not rotating, syncing without rotation")
+		return SyncCreate(ctx, scs, j)
+	}
+	source, path := scs.Source, j.SyncConfig.Spec.Source.Path
+	now := time.Now()
+
+	var previous []byte
+	err := limited(ctx, source, func(ctx context.Context) error {
+		var err error
+		previous, err = source.GetSecret(ctx, path)
+		return err
+	})
+	if err != nil {
+		return driver.Classify(source, err)
+	}
+	var secret map[string]any
+	if err := json.Unmarshal(previous, &secret); err != nil {
+		return driver.Permanent(fmt.Errorf("rotation: failed to parse source secret: %w", err))
+	}
+	var overlapUntil *metav1.Time
+	var rotated map[string]any
+	switch phase {
+	case backend.RotationPhaseRotate:
+		overlap := rc.Overlap != nil && rc.Overlap.Duration > 0
+		if rotated, err = rotation.Rotate(ctx, j.SyncConfig.Namespace, *rc, secret, overlap); err != nil {
+			recordRotation(ctx, j, phase, now, nil, err, false)
+			return err
+		}
+		if overlap {
+			t := metav1.NewTime(now.Add(rc.Overlap.Duration))
+			overlapUntil = &t
+		}
+	case backend.RotationPhaseEndOverlap:
+		rotated = rotation.EndOverlap(*rc, secret)
+	default:
+		return driver.Permanent(fmt.Errorf("invalid rotation phase %q", phase))
+	}
+	data, err := json.Marshal(rotated)
+	if err != nil {
+		return driver.Permanent(err)
+	}
+
+	if err := writeRotation(ctx, source, path, data); err != nil {
+		recordRotation(ctx, j, phase, now, nil, err, false)
+		return err
+	}
+	l.Info("rotated source secret")
+	j.sources = newSourceCache()
+	err = SyncCreate(ctx, scs, j)
+	if err == nil && phase == backend.RotationPhaseRotate && rc.Verify != nil {
+		err = verifyRotation(ctx, j, *rc, path, now)
+	}
+	if err == nil {
+		recordRotation(ctx, j, phase, now, overlapUntil, nil, false)
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Normal", "Rotated", fmt.Sprintf("%s: %s rotation synced", path, phase))
+		return nil
+	}
+
+	l.WithError(err).Warn("rotation failed, rolling back")
+	if rerr := writeRotation(ctx, source, path, previous); rerr != nil {
+		err = driver.Permanent(fmt.Errorf("rotation failed: %v, and the previous secret could not be restored: %w", err, rerr))
+		recordRotation(ctx, j, phase, now, nil, err, false)
+		return err
+	}
+	j.sources = newSourceCache()
+	if rerr := SyncCreate(ctx, scs, j); rerr != nil {
+		err = driver.Permanent(fmt.Errorf("rotation failed: %v, and the previous secret could not be synced: %w", err, rerr))
+		recordRotation(ctx, j, phase, now, nil, err, true)
+		return err
+	}
+	recordRotation(ctx, j, phase, now, nil, err, true)
+	backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Warning", "RotationRolledBack", fmt.Sprintf("%s: %s rotation rolled back: %s", path, phase, err))
+	return fmt.Errorf("rotation rolled back: %w", err)
+}
+
+// verifyRotation makes the verify request of the rotation
+func verifyRotation(ctx context.Context, j SyncJob, rc v1alpha1.RotationConfig, path string, now time.Time) error {
+	body := rotationVerifyBody{
+		Name:      j.SyncConfig.Name,
+		Namespace: j.SyncConfig.Namespace,
+		Path:      path,
+		Time:      now.UTC(),
+	}
+	for _, k := range rc.Keys {
+		body.Keys = append(body.Keys, k.Key)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if _, err := rotation.Request(ctx, j.SyncConfig.Namespace, *rc.Verify, b); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	return nil
+}
+
+// recordRotation records the attempt of the rotation phase in the status of
+// the sync config
+func recordRotation(ctx context.Context, j SyncJob, phase backend.RotationPhase, now time.Time, overlapUntil *metav1.Time, err error, rolledBack bool) {
+	t := metav1.NewTime(now)
+	uerr := backend.UpdateRotationStatus(ctx, j.SyncConfig, func(rs *v1alpha1.RotationStatus) {
+		rs.LastError, rs.RolledBack = "", rolledBack
+		if err != nil {
+			rs.LastError = err.Error()
+		}
+		switch {
+		case phase == backend.RotationPhaseEndOverlap && err == nil:
+			rs.OverlapUntil = nil
+		case phase == backend.RotationPhaseRotate:
+			rs.LastAttemptTime = &t
+			if err == nil {
+				rs.LastRotationTime = &t
+				rs.OverlapUntil = overlapUntil
+			}
+		}
+	})
+	if uerr != nil {
+		log.WithFields(log.Fields{
+			"action":    "recordRotation",
+			"name":      j.SyncConfig.Name,
+			"namespace": j.SyncConfig.Namespace,
+		}).WithError(uerr).Error("failed to record rotation")
+	}
+}
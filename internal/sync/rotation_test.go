@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rotationTestSource is a test source whose writes are read back
+type rotationTestSource struct {
+	manualRegexTestClient
+	versions [][]byte
+}
+
+func (c *rotationTestSource) WriteSecret(_ context.Context, _ metav1.ObjectMeta, path string, secret []byte) ([]byte, error) {
+	c.secrets[path] = secret
+	c.versions = append(c.versions, secret)
+	return secret, nil
+}
+
+func rotationTestJob(t *testing.T, verify string) (SyncJob, *SyncClients, *rotationTestSource, *manualRegexTestClient) {
+	t.Helper()
+	source := &rotationTestSource{manualRegexTestClient: manualRegexTestClient{
+		path:    "kv/app",
+		secrets: map[string][]byte{"kv/app": []byte(`{"user":"app","password":"old"}`)},
+	}}
+	dest := &manualRegexTestClient{path: "kv/copy"}
+	j := pruneTestJob(t, "rotation-"+t.Name(), false, false)
+	j.SyncConfig.Spec.Prune = nil
+	j.SyncConfig.Spec.Source = &vault.VaultClient{Path: "kv/app"}
+	j.SyncConfig.Spec.Rotation = &v1alpha1.RotationConfig{
+		Schedule: "0 3 * * *",
+		Keys:     []v1alpha1.RotationKey{{Key: "password", Generator: v1alpha1.RotationGeneratorPassword}},
+		Overlap:  &metav1.Duration{Duration: 1},
+	}
+	if verify != "" {
+		j.SyncConfig.Spec.Rotation.Verify = &v1alpha1.RotationHTTP{URL: verify}
+	}
+	j.VaultEvent.Force = true
+	j.VaultEvent.Rotation = string(backend.RotationPhaseRotate)
+	j.sources = newSourceCache()
+	return j, &SyncClients{Source: source, Dest: []SyncClient{dest}}, source, dest
+}
+
+func TestValidateRotation(t *testing.T) {
+	sc := bidirectionalTestConfig("kv/app", "kv/copy")
+	sc.Spec.Bidirectional = nil
+	sc.Spec.Rotation = &v1alpha1.RotationConfig{
+		Schedule: "0 3 * * *",
+		Keys:     []v1alpha1.RotationKey{{Key: "password", Generator: v1alpha1.RotationGeneratorPassword}},
+	}
+	assert.NoError(t, validateRotation(sc))
+
+	sc.Spec.Source.Path = "kv/(.*)"
+	assert.ErrorContains(t, validateRotation(sc), "exact source path")
+
+	sc.Spec.Source.Path, sc.Spec.Source.Version = "kv/app", 3
+	assert.ErrorContains(t, validateRotation(sc), "pinned source version")
+}
+
+func TestSyncRotation(t *testing.T) {
+	ctx := context.Background()
+	var verified []rotationVerifyBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b rotationVerifyBody
+		_ = json.NewDecoder(r.Body).Decode(&b)
+		verified = append(verified, b)
+	}))
+	defer server.Close()
+
+	j, scs, source, dest := rotationTestJob(t, server.URL)
+	require.NoError(t, syncRotation(ctx, scs, j))
+
+	// the rotated secret is written to the source and synced, keeping the
+	// previous password during the overlap
+	var rotated map[string]string
+	require.NoError(t, json.Unmarshal(source.secrets["kv/app"], &rotated))
+	assert.Equal(t, "app", rotated["user"])
+	assert.Len(t, rotated["password"], 32)
+	assert.Equal(t, "old", rotated["password_previous"])
+	assert.JSONEq(t, string(source.secrets["kv/app"]), string(dest.writes["kv/copy"]))
+	require.Len(t, verified, 1)
+	assert.Equal(t, "kv/app", verified[0].Path)
+	assert.Equal(t, []string{"password"}, verified[0].Keys)
+
+	// ending the overlap removes the previous password
+	j.VaultEvent.Rotation = string(backend.RotationPhaseEndOverlap)
+	j.sources = newSourceCache()
+	require.NoError(t, syncRotation(ctx, scs, j))
+	assert.JSONEq(t, `{"user":"app","password":"`+rotated["password"]+`"}`, string(dest.writes["kv/copy"]))
+	assert.Len(t, verified, 1)
+}
+
+func TestSyncRotationRollback(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	j, scs, source, dest := rotationTestJob(t, server.URL)
+	assert.ErrorContains(t, syncRotation(ctx, scs, j), "rotation rolled back: verify: request failed with status 503")
+
+	// the previous secret is written to the source again and synced
+	require.Len(t, source.versions, 2)
+	assert.JSONEq(t, `{"user":"app","password":"old"}`, string(source.secrets["kv/app"]))
+	assert.JSONEq(t, `{"user":"app","password":"old"}`, string(dest.writes["kv/copy"]))
+}
@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDrainTimeout is how long in-flight syncs are waited for on shutdown
+// before they are cancelled
+var DefaultDrainTimeout = 30 * time.Second
+
+// cancelGracePeriod is how long cancelled syncs are waited for, and their
+// events requeued, once the drain timeout has passed
+const cancelGracePeriod = 10 * time.Second
+
+// processor is the running event processor, which is shut down in stages
+var processor eventProcessor
+
+type eventProcessor struct {
+	mu                sync.Mutex
+	stopSubscriptions context.CancelFunc
+	cancelSyncs       context.CancelFunc
+	// workers are the event workers, which exit once their subscription
+	// is stopped and every event it received has been processed
+	workers sync.WaitGroup
+}
+
+func (p *eventProcessor) start(stopSubscriptions, cancelSyncs context.CancelFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopSubscriptions = stopSubscriptions
+	p.cancelSyncs = cancelSyncs
+}
+
+// Drain waits until every event received by the event processor has been
+// processed, and every other active sync is complete, returning false if
+// ctx is done first. The subscriptions must be stopped for the event
+// workers to exit.
+func Drain(ctx context.Context) bool {
+	l := log.WithFields(log.Fields{
+		"action": "Drain",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	done := make(chan struct{})
+	go func() {
+		processor.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return false
+	}
+	WaitForSyncs(ctx)
+	return ctx.Err() == nil
+}
+
+// Shutdown stops the event processor. The queue subscriptions are stopped,
+// events received but not yet processed are requeued where the queue
+// supports it, and the syncs in flight are waited for until ctx is done.
+// Syncs still running are then cancelled and their events requeued, and
+// pending retries are published immediately so that another replica can
+// process them.
+func Shutdown(ctx context.Context) {
+	l := log.WithFields(log.Fields{
+		"action": "Shutdown",
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	processor.mu.Lock()
+	stopSubscriptions, cancelSyncs := processor.stopSubscriptions, processor.cancelSyncs
+	processor.mu.Unlock()
+	if stopSubscriptions == nil {
+		return
+	}
+
+	l.Info("stopping queue subscriptions")
+	stopSubscriptions()
+	if queue.Q != nil {
+		n, err := queue.Q.Requeue(ctx)
+		if err != nil {
+			l.WithError(err).Error("failed to requeue events")
+		}
+		if n > 0 {
+			l.WithField("events", n).Info("requeued unprocessed events")
+		}
+	}
+
+	l.Info("draining in-flight syncs")
+	if !Drain(ctx) {
+		l.Warn("drain timed out, cancelling in-flight syncs")
+		cancelSyncs()
+		gctx, cancel := context.WithTimeout(context.Background(), cancelGracePeriod)
+		defer cancel()
+		if !Drain(gctx) {
+			l.Warn("cancelled syncs did not stop")
+		}
+	}
+	fctx, cancel := context.WithTimeout(context.Background(), cancelGracePeriod)
+	defer cancel()
+	flushRetries(fctx)
+}
+
+// requeueCancelled requeues the event of the job if its sync was cancelled
+// by shutdown, without counting an attempt. It returns false if the sync
+// was not cancelled.
+func requeueCancelled(ctx context.Context, j SyncJob) bool {
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		return false
+	}
+	l := log.WithFields(log.Fields{
+		"action":    "requeueCancelled",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+	})
+	evt := j.VaultEvent
+	evt.ID = ""
+	// an event can match many sync configs, only requeue the one which was cancelled
+	evt.SyncName = backend.InternalName(j.SyncConfig.Namespace, j.SyncConfig.Name)
+	pctx, cancel := context.WithTimeout(context.Background(), cancelGracePeriod)
+	defer cancel()
+	if err := queue.Q.Publish(pctx, evt); err != nil {
+		l.WithError(err).Error("failed to requeue cancelled sync")
+		return true
+	}
+	l.Info("requeued cancelled sync")
+	return true
+}
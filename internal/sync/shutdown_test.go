@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// publishTestQueue records the events published to it
+type publishTestQueue struct {
+	queue.Queue
+	mu        gosync.Mutex
+	published []event.VaultEvent
+}
+
+func (q *publishTestQueue) Publish(ctx context.Context, evt event.VaultEvent) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.published = append(q.published, evt)
+	return nil
+}
+
+func setPublishTestQueue(t *testing.T) *publishTestQueue {
+	q := &publishTestQueue{}
+	prev := queue.Q
+	queue.Q = q
+	t.Cleanup(func() { queue.Q = prev })
+	return q
+}
+
+func TestFlushRetries(t *testing.T) {
+	q := setPublishTestQueue(t)
+	r := &retryTimers{timers: make(map[*time.Timer]event.VaultEvent)}
+	r.add(time.Hour, event.VaultEvent{SyncName: "test/a", Attempt: 1})
+	r.add(time.Hour, event.VaultEvent{SyncName: "test/b", Attempt: 2})
+
+	assert.Equal(t, 2, r.flush(context.Background()))
+	assert.Len(t, q.published, 2)
+	assert.Empty(t, r.timers)
+	assert.Equal(t, 0, r.flush(context.Background()))
+}
+
+func TestRequeueCancelled(t *testing.T) {
+	q := setPublishTestQueue(t)
+	j := SyncJob{
+		VaultEvent: event.VaultEvent{ID: "1", Path: "kv/data/app", Attempt: 2},
+		SyncConfig: v1alpha1.VaultSecretSync{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"},
+		},
+	}
+
+	// syncs which time out are retried rather than requeued
+	tctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-tctx.Done()
+	assert.False(t, requeueCancelled(tctx, j))
+	assert.False(t, requeueCancelled(context.Background(), j))
+	assert.Empty(t, q.published)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, requeueCancelled(ctx, j))
+	if assert.Len(t, q.published, 1) {
+		evt := q.published[0]
+		assert.Empty(t, evt.ID)
+		assert.Equal(t, "test/app", evt.SyncName)
+		assert.Equal(t, "kv/data/app", evt.Path)
+		// the cancelled attempt isn't counted
+		assert.Equal(t, 2, evt.Attempt)
+	}
+}
@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"context"
+	"sync"
+
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+)
+
+// sourceCache holds the source secrets read by a single sync job, so that
+// a secret synced to several destinations is only read once. Transforms
+// are applied per destination after the read. The custom metadata of the
+// secrets is read along with them, if the source supports it.
+type sourceCache struct {
+	mu      sync.Mutex
+	entries map[string]*sourceEntry
+}
+
+type sourceEntry struct {
+	once     sync.Once
+	secret   []byte
+	metadata map[string]string
+	err      error
+}
+
+func newSourceCache() *sourceCache {
+	return &sourceCache{entries: make(map[string]*sourceEntry)}
+}
+
+// get returns the source secret, reading it on first use. Read errors are
+// cached too, so that a failing source is not read again by each destination.
+func (c *sourceCache) get(ctx context.Context, source SyncClient, sourcePath string) ([]byte, error) {
+	read := func() ([]byte, map[string]string, error) {
+		var secret []byte
+		var md map[string]string
+		err := limited(ctx, source, func(ctx context.Context) error {
+			var err error
+			if mr, ok := source.(driver.MetadataReader); ok {
+				secret, md, err = mr.GetSecretWithMetadata(ctx, sourcePath)
+				return err
+			}
+			secret, err = source.GetSecret(ctx, sourcePath)
+			return err
+		})
+		return secret, md, err
+	}
+	if c == nil {
+		secret, _, err := read()
+		return secret, err
+	}
+	c.mu.Lock()
+	e, ok := c.entries[sourcePath]
+	if !ok {
+		e = &sourceEntry{}
+		c.entries[sourcePath] = e
+	}
+	c.mu.Unlock()
+	e.once.Do(func() {
+		e.secret, e.metadata, e.err = read()
+	})
+	if e.err != nil {
+		return nil, e.err
+	}
+	return append([]byte(nil), e.secret...), nil
+}
+
+// metadata returns the custom metadata of a source secret which was read,
+// or nil if it was not read or has none
+func (c *sourceCache) metadata(sourcePath string) map[string]string {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	e, ok := c.entries[sourcePath]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	// wait for a concurrent read of the secret
+	e.once.Do(func() {})
+	return e.metadata
+}
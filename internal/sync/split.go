@@ -0,0 +1,233 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultKeyName is the name template of the secret of each key of a
+// destination which splits keys and does not set keyName
+const DefaultKeyName = "{{.Path}}-{{.Key}}"
+
+// splitDrivers are the destination drivers which can split keys. The other
+// drivers write a secret of keys, or one secret per key already.
+var splitDrivers = map[driver.DriverName]bool{
+	driver.DriverNameAws: true,
+	driver.DriverNameGcp: true,
+}
+
+// splitDests returns the key name template of each destination client of the
+// sync config which splits keys
+func splitDests(sc v1alpha1.VaultSecretSync, scs *SyncClients) (map[SyncClient]*template.Template, error) {
+	splits := make(map[SyncClient]*template.Template)
+	for i, d := range sc.Spec.Dest {
+		if d == nil || !d.SplitKeys || i >= len(scs.Dest) {
+			continue
+		}
+		dest := scs.Dest[i]
+		if !splitDrivers[dest.Driver()] {
+			return nil, fmt.Errorf("dest[%d]: splitKeys is not supported by the %s driver", i, dest.Driver())
+		}
+		name := d.KeyName
+		if name == "" {
+			name = DefaultKeyName
+		}
+		t, err := template.New("keyName").Option("missingkey=error").Funcs(templateFuncs).Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("dest[%d]: invalid keyName template %q: %v", i, name, err)
+		}
+		splits[dest] = t
+	}
+	return splits, nil
+}
+
+// keyPath returns the path of the secret of the key
+func keyPath(t *template.Template, destPath, key string) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, map[string]string{"Path": destPath, "Key": key}); err != nil {
+		return "", fmt.Errorf("failed to render keyName template: %v", err)
+	}
+	p := strings.TrimSpace(buf.String())
+	if p == "" {
+		return "", fmt.Errorf("keyName template rendered an empty path for key %s", key)
+	}
+	return p, nil
+}
+
+// splitSecret returns the raw value of each key of the secret. String values
+// are written as is, other values as JSON.
+func splitSecret(secret []byte) (map[string][]byte, error) {
+	var m map[string]any
+	if err := json.Unmarshal(secret, &m); err != nil || m == nil {
+		return nil, driver.Permanent(fmt.Errorf("splitKeys requires a secret of keys"))
+	}
+	values := make(map[string][]byte, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			values[k] = []byte(s)
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, driver.Permanent(fmt.Errorf("failed to encode key %s: %v", k, err))
+		}
+		values[k] = b
+	}
+	return values, nil
+}
+
+// keyPaths returns the path of the secret of each key, keyed by path
+func keyPaths(t *template.Template, destPath string, values map[string][]byte) (map[string]string, error) {
+	paths := make(map[string]string, len(values))
+	for k := range values {
+		p, err := keyPath(t, destPath, k)
+		if err != nil {
+			return nil, driver.Permanent(err)
+		}
+		if other, ok := paths[p]; ok {
+			return nil, driver.Permanent(fmt.Errorf("keys %s and %s are both written to %s", other, k, p))
+		}
+		paths[p] = k
+	}
+	return paths, nil
+}
+
+// createSplit writes each key of the source secret to its own destination
+// secret, and deletes the secrets of keys removed from the source secret
+func createSplit(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePath, destPath string, t *template.Template) error {
+	l := log.WithFields(log.Fields{
+		"action":      "createSplit",
+		"source.Path": sourcePath,
+		"dest.Path":   destPath,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	var paths map[string]string
+	ssecret, err := readSource(ctx, j, source, sourcePath)
+	var values map[string][]byte
+	if err == nil {
+		values, err = splitSecret(ssecret)
+	}
+	if err == nil {
+		paths, err = keyPaths(t, destPath, values)
+	}
+	if err != nil {
+		if isDryRun(j) {
+			j.plan.record(dest, destPath, v1alpha1.PlannedChange{Action: v1alpha1.PlanActionWrite, Error: err.Error()})
+		}
+		return handleCreateOneError(ctx, err, j, dest, sourcePath, destPath)
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		j.destinations.want(dest, p)
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	l.WithField("keys", len(sorted)).Debug("splitting secret keys")
+
+	var errs []error
+	for _, p := range sorted {
+		k := paths[p]
+		if isDryRun(j) {
+			planWrite(ctx, j, dest, p, values[k])
+			continue
+		}
+		if err := writeDest(ctx, j, dest, sourcePath, p, k, values[k]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := deleteStaleKeys(ctx, j, dest, sourcePath, destPath, t, paths); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	return nil
+}
+
+// splitRecords returns the inventory records of the key secrets written to
+// the destination for the source secret. Keys written by another destination
+// splitting the same source secret into the same store are excluded, as
+// their paths do not match the key name template of the destination.
+func splitRecords(j SyncJob, dest SyncClient, sourcePath, destPath string, t *template.Template) []backend.InventoryRecord {
+	store := destStoreKey(dest)
+	var records []backend.InventoryRecord
+	for _, r := range j.inventory.Records() {
+		if r.SourceKey == "" || r.SourcePath != sourcePath || r.StoreKey() != store {
+			continue
+		}
+		if p, err := keyPath(t, destPath, r.SourceKey); err != nil || p != r.Path {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// deleteStaleKeys deletes the secrets of keys which were written to the
+// destination but are no longer in the source secret
+func deleteStaleKeys(ctx context.Context, j SyncJob, dest SyncClient, sourcePath, destPath string, t *template.Template, paths map[string]string) error {
+	var stale []string
+	for _, r := range splitRecords(j, dest, sourcePath, destPath, t) {
+		if _, ok := paths[r.Path]; !ok {
+			stale = append(stale, r.Path)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"action":    "deleteStaleKeys",
+		"dest.Path": destPath,
+		"stale":     len(stale),
+	}).Debug("deleting secrets of removed keys")
+	if err := reserveDeletes(ctx, j, len(stale)); err != nil {
+		return err
+	}
+	return deletePaths(ctx, j, dest, sourcePath, stale)
+}
+
+// destDeletePaths returns the paths of the destination secrets to delete
+// when the source secret is deleted. A destination which splits keys
+// deletes the secret of each key written, as recorded in the inventory.
+func destDeletePaths(j SyncJob, dest SyncClient, sourcePath, destPath string) []string {
+	t, ok := j.splits[dest]
+	if !ok {
+		return []string{destPath}
+	}
+	var paths []string
+	for _, r := range splitRecords(j, dest, sourcePath, destPath, t) {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
+
+// deletePaths deletes the destination secrets, skipping them if the sync is
+// suspended or a dry run
+func deletePaths(ctx context.Context, j SyncJob, dest SyncClient, sourcePath string, paths []string) error {
+	var errs []error
+	for _, p := range paths {
+		if shouldDryRun(ctx, j, dest, sourcePath, p) {
+			continue
+		}
+		if err := deleteSecret(ctx, j, dest, p); err != nil {
+			log.WithError(err).Error("delete job failed")
+			j.destinations.failure(dest, p, err)
+			errs = append(errs, driver.Classify(dest, err))
+		}
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+)
+
+// splitTestClient is a test destination of a driver which can split keys
+type splitTestClient struct {
+	manualRegexTestClient
+}
+
+func (c *splitTestClient) Driver() driver.DriverName {
+	return driver.DriverNameAws
+}
+
+func TestSplitSecret(t *testing.T) {
+	values, err := splitSecret([]byte(`{"user":"admin","port":5432,"opts":{"ssl":true}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", string(values["user"]))
+	assert.Equal(t, "5432", string(values["port"]))
+	assert.Equal(t, `{"ssl":true}`, string(values["opts"]))
+
+	_, err = splitSecret([]byte("raw"))
+	assert.True(t, driver.IsPermanent(err))
+}
+
+func TestSplitDests(t *testing.T) {
+	cfg := manualRegexSyncJob("kv/app", false).SyncConfig
+	cfg.Spec.Dest = []*v1alpha1.StoreConfig{{SplitKeys: true}, {}}
+	split, other := &splitTestClient{}, &splitTestClient{}
+	splits, err := splitDests(cfg, &SyncClients{Dest: []SyncClient{split, other}})
+	assert.NoError(t, err)
+	assert.Len(t, splits, 1)
+	p, err := keyPath(splits[split], "prod/db", "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod/db-user", p)
+
+	cfg.Spec.Dest[0].KeyName = "{{.Path}}/{{.Key | upper}}"
+	splits, err = splitDests(cfg, &SyncClients{Dest: []SyncClient{split, other}})
+	assert.NoError(t, err)
+	p, _ = keyPath(splits[split], "prod/db", "user")
+	assert.Equal(t, "prod/db/USER", p)
+
+	cfg.Spec.Dest[0].KeyName = "{{.Path"
+	_, err = splitDests(cfg, &SyncClients{Dest: []SyncClient{split, other}})
+	assert.Error(t, err)
+
+	// vault destinations write a secret of keys
+	cfg.Spec.Dest[0].KeyName = ""
+	_, err = splitDests(cfg, &SyncClients{Dest: []SyncClient{&manualRegexTestClient{}, other}})
+	assert.Error(t, err)
+}
+
+func TestCreateSplit(t *testing.T) {
+	ctx := context.Background()
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"user":"admin","pass":"secret"}`)}}
+	dest := &splitTestClient{manualRegexTestClient{path: "prod/db"}}
+
+	j := pruneTestJob(t, "split", false, false)
+	j.deletes = &deleteBudget{}
+	j.SyncConfig.Spec.Dest = []*v1alpha1.StoreConfig{{SplitKeys: true}}
+	splits, err := splitDests(j.SyncConfig, &SyncClients{Dest: []SyncClient{dest}})
+	assert.NoError(t, err)
+	j.splits = splits
+
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "prod/db"))
+	assert.Equal(t, map[string][]byte{"prod/db-user": []byte("admin"), "prod/db-pass": []byte("secret")}, dest.writes)
+	r, ok := j.inventory.Get(destInventoryKey(dest, "prod/db-pass"))
+	assert.True(t, ok)
+	assert.Equal(t, "pass", r.SourceKey)
+	assert.True(t, j.destinations.isDesired(destInventoryKey(dest, "prod/db-user")))
+
+	// the secrets of keys removed from the source secret are deleted
+	source.secrets["kv/app"] = []byte(`{"user":"admin"}`)
+	assert.NoError(t, CreateOne(ctx, j, source, dest, "kv/app", "prod/db"))
+	assert.Equal(t, []string{"prod/db-pass"}, dest.deletes)
+
+	// a delete of the source secret deletes the secret of each key written
+	assert.Equal(t, []string{"prod/db-user"}, destDeletePaths(j, dest, "kv/app", "prod/db"))
+	assert.NoError(t, deletePaths(ctx, j, dest, "kv/app", destDeletePaths(j, dest, "kv/app", "prod/db")))
+	assert.Equal(t, []string{"prod/db-pass", "prod/db-user"}, dest.deletes)
+	assert.Empty(t, j.inventory.Records())
+}
+
+func TestCreateSplitKeyCollision(t *testing.T) {
+	ctx := context.Background()
+	source := &manualRegexTestClient{secrets: map[string][]byte{"kv/app": []byte(`{"User":"a","user":"b"}`)}}
+	dest := &splitTestClient{manualRegexTestClient{path: "prod/db"}}
+
+	j := pruneTestJob(t, "split-collision", false, false)
+	j.SyncConfig.Spec.Dest = []*v1alpha1.StoreConfig{{SplitKeys: true, KeyName: "{{.Path}}-{{lower .Key}}"}}
+	splits, err := splitDests(j.SyncConfig, &SyncClients{Dest: []SyncClient{dest}})
+	assert.NoError(t, err)
+	j.splits = splits
+
+	err = CreateOne(ctx, j, source, dest, "kv/app", "prod/db")
+	assert.True(t, driver.IsPermanent(err))
+	assert.Empty(t, dest.writes)
+}
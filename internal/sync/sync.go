@@ -3,10 +3,12 @@ package sync
 import (
 	"context"
 	"fmt"
+	"text/template"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
 	"github.com/robertlestak/vault-secret-sync/internal/event"
 	"github.com/robertlestak/vault-secret-sync/internal/metrics"
 	"github.com/robertlestak/vault-secret-sync/pkg/driver"
@@ -88,12 +90,8 @@ func processSyncJobs(ctx context.Context, jobHolder []SyncJob) error {
 	defer l.Trace("end")
 	jobs := make(chan SyncJob, len(jobHolder))
 	errChan := make(chan error, len(jobHolder))
-	workers := 100
-	if len(jobHolder) < workers {
-		workers = len(jobHolder)
-	}
-	for i := 0; i < workers; i++ {
-		go syncJobWorker(context.Background(), jobs, errChan)
+	for i := 0; i < workers(DefaultWorkers, len(jobHolder)); i++ {
+		go syncJobWorker(ctx, jobs, errChan)
 	}
 	for _, job := range jobHolder {
 		jobs <- job
@@ -106,7 +104,7 @@ func processSyncJobs(ctx context.Context, jobHolder []SyncJob) error {
 		}
 	}
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 	return nil
 }
@@ -115,6 +113,16 @@ type SyncJob struct {
 	VaultEvent event.VaultEvent
 	SyncConfig v1alpha1.VaultSecretSync
 	Error      error
+
+	drift        *driftTracker
+	inventory    *backend.Inventory
+	destinations *destinationTracker
+	plan         *planTracker
+	deletes      *deleteBudget
+	stores       map[SyncClient]*v1alpha1.StoreConfig
+	splits       map[SyncClient]*template.Template
+	sources      *sourceCache
+	reversed     bool
 }
 
 func singleSyncWorker(ctx context.Context, sc *SyncClients, j SyncJob, dest chan SyncClient, errChan chan error) {
@@ -123,7 +131,13 @@ func singleSyncWorker(ctx context.Context, sc *SyncClients, j SyncJob, dest chan
 	defer l.Trace("end")
 
 	for d := range dest {
-		if err := CreateOne(ctx, j, sc.Source, d, sc.Source.GetPath(), d.GetPath()); err != nil {
+		dp, err := destPath(j, d, sc.Source.GetPath(), nil, sc.Source.GetPath())
+		if err != nil {
+			l.Error(err)
+			errChan <- driver.Permanent(err)
+			continue
+		}
+		if err := CreateOne(ctx, j, sc.Source, d, sc.Source.GetPath(), dp); err != nil {
 			errChan <- err
 		} else {
 			errChan <- nil
@@ -131,18 +145,14 @@ func singleSyncWorker(ctx context.Context, sc *SyncClients, j SyncJob, dest chan
 	}
 }
 
-func handleSingleSync(sc *SyncClients, j SyncJob) error {
+func handleSingleSync(ctx context.Context, sc *SyncClients, j SyncJob) error {
 	l := log.WithFields(log.Fields{"action": "handleSingleSync"})
 	l.Trace("single sync")
 	var errors []error
 	dest := make(chan SyncClient, len(sc.Dest))
 	errChan := make(chan error, len(sc.Dest))
-	workers := 100
-	if len(sc.Dest) < workers {
-		workers = len(sc.Dest)
-	}
-	for i := 0; i < workers; i++ {
-		go singleSyncWorker(context.Background(), sc, j, dest, errChan)
+	for i := 0; i < workers(DefaultWorkers, len(sc.Dest)); i++ {
+		go singleSyncWorker(ctx, sc, j, dest, errChan)
 	}
 	for _, d := range sc.Dest {
 		dest <- d
@@ -154,7 +164,7 @@ func handleSingleSync(sc *SyncClients, j SyncJob) error {
 		}
 	}
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 	return nil
 }
@@ -165,34 +175,43 @@ func syncDeleteWorker(ctx context.Context, sc *SyncClients, j SyncJob, dest chan
 	defer l.Trace("end")
 
 	for d := range dest {
-		if shouldFilterSecret(j, sc.Source.GetPath(), d.GetPath()) {
+		dj := destJob(j, d)
+		if !deletesFrom(dj, d) {
 			errChan <- nil
 			continue
 		}
-		if shouldDryRun(j, d, sc.Source.GetPath(), d.GetPath()) {
-			errChan <- nil
+		dp, err := destPath(dj, d, sc.Source.GetPath(), nil, sc.Source.GetPath())
+		if err != nil {
+			l.Error(err)
+			errChan <- driver.Permanent(err)
 			continue
 		}
-		if err := d.DeleteSecret(ctx, d.GetPath()); err != nil {
-			errChan <- err
-		} else {
-			errChan <- nil
-		}
+		errChan <- deletePaths(ctx, dj, d, sc.Source.GetPath(), destDeletePaths(dj, d, sc.Source.GetPath(), dp))
 	}
 }
 
-func handleSingleDelete(sc *SyncClients, j SyncJob) error {
+func handleSingleDelete(ctx context.Context, sc *SyncClients, j SyncJob) error {
 	l := log.WithFields(log.Fields{"action": "handleSingleDelete"})
 	l.Debug("single delete")
+	deletes := make(map[SyncClient]int)
+	for _, d := range sc.Dest {
+		if !deletesFrom(destJob(j, d), d) {
+			continue
+		}
+		n := 1
+		if dp, err := destPath(j, d, sc.Source.GetPath(), nil, sc.Source.GetPath()); err == nil {
+			n = len(destDeletePaths(j, d, sc.Source.GetPath(), dp))
+		}
+		deletes[d] += n
+	}
+	if err := reserveDestDeletes(ctx, j, sc.Dest, deletes); err != nil {
+		return err
+	}
 	var errors []error
 	dest := make(chan SyncClient, len(sc.Dest))
 	errChan := make(chan error, len(sc.Dest))
-	workers := 100
-	if len(sc.Dest) < workers {
-		workers = len(sc.Dest)
-	}
-	for i := 0; i < workers; i++ {
-		go syncDeleteWorker(context.Background(), sc, j, dest, errChan)
+	for i := 0; i < workers(DefaultWorkers, len(sc.Dest)); i++ {
+		go syncDeleteWorker(ctx, sc, j, dest, errChan)
 	}
 	for _, d := range sc.Dest {
 		dest <- d
@@ -204,7 +223,7 @@ func handleSingleDelete(sc *SyncClients, j SyncJob) error {
 		}
 	}
 	if len(errors) > 0 {
-		return fmt.Errorf("errors: %v", errors)
+		return joinErrors(errors)
 	}
 	return nil
 }
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helper functions of destination path templates. The
+// piped value is the last argument, e.g. {{.team | replace "_" "-"}}.
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+	"trimPrefix": func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	},
+	"trimSuffix": func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	},
+}
+
+// templateFields are the fields of destination path templates which are
+// not capture groups
+var templateFields = []string{"Path", "Segments", "Groups", "Namespace", "Name", "Labels"}
+
+// isTemplatePath returns true if the destination path is a Go template
+func isTemplatePath(p string) bool {
+	return strings.Contains(p, "{{")
+}
+
+// renderDestPath renders the destination path template for the source path.
+// Named capture groups of rx are available by name, alongside the source
+// path, its segments, the numbered capture groups, and the namespace, name
+// and labels of the sync config.
+func renderDestPath(j SyncJob, tmpl string, rx *regexp.Regexp, srcPath string) (string, error) {
+	t, err := template.New("path").Option("missingkey=error").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination path template %q: %v", tmpl, err)
+	}
+	data := map[string]any{
+		"Path":      srcPath,
+		"Segments":  strings.Split(srcPath, "/"),
+		"Groups":    []string{},
+		"Namespace": j.SyncConfig.Namespace,
+		"Name":      j.SyncConfig.Name,
+		"Labels":    j.SyncConfig.Labels,
+	}
+	if data["Labels"] == nil {
+		data["Labels"] = map[string]string{}
+	}
+	if rx != nil {
+		if matches := rx.FindStringSubmatch(srcPath); matches != nil {
+			data["Groups"] = matches
+			for i, name := range rx.SubexpNames() {
+				if name == "" {
+					continue
+				}
+				if _, ok := data[name]; ok {
+					return "", fmt.Errorf("capture group %q conflicts with the template field of the same name", name)
+				}
+				data[name] = matches[i]
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render destination path template %q: %v", tmpl, err)
+	}
+	p := strings.TrimSpace(buf.String())
+	if p == "" {
+		return "", fmt.Errorf("destination path template %q rendered an empty path for %s", tmpl, srcPath)
+	}
+	return p, nil
+}
+
+// destPath returns the destination path of the source path for the
+// destination. Templated paths are rendered, otherwise capture groups of
+// a regex source path are substituted for $1, $2 and so on, or the source
+// path below the highest non-regex path is appended to the destination path.
+func destPath(j SyncJob, d SyncClient, sourcePath string, rx *regexp.Regexp, srcPath string) (string, error) {
+	dp := d.GetPath()
+	if isTemplatePath(dp) {
+		return renderDestPath(j, dp, rx, srcPath)
+	}
+	if rx == nil {
+		return dp, nil
+	}
+	if rx.NumSubexp() == 0 {
+		return path.Join(dp, strings.TrimPrefix(srcPath, findHighestNonRegexPath(sourcePath))), nil
+	}
+	for i, match := range rx.FindStringSubmatch(srcPath) {
+		if i == 0 {
+			continue
+		}
+		dp = strings.ReplaceAll(dp, fmt.Sprintf("$%d", i), match)
+	}
+	return dp, nil
+}
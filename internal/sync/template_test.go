@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestPathTemplate(t *testing.T) {
+	const sourcePath = "kv/teams/(?P<team>[^/]+)/(?P<env>[^/]+)/db"
+	j := manualRegexSyncJob(sourcePath, false)
+	j.SyncConfig.Labels = map[string]string{"region": "us-east-1"}
+	rx := regexp.MustCompile("^" + sourcePath + "$")
+
+	tests := []struct {
+		dest string
+		want string
+	}{
+		{"{{.env}}/{{.team}}-db", "prod/payments-db"},
+		{"{{.env | upper}}/{{.team | replace \"pay\" \"bill\"}}", "PROD/billments"},
+		{"{{.Path | trimPrefix \"kv/\"}}", "teams/payments/prod/db"},
+		{"{{index .Segments 2}}/{{index .Groups 2}}", "payments/prod"},
+		{"{{.Namespace}}/{{.Name}}/{{.Labels.region}}/{{lower \"DB\"}}", "test-namespace/test-sync/us-east-1/db"},
+		// numbered groups without a template are unchanged
+		{"$2/$1-db", "prod/payments-db"},
+	}
+	for _, tt := range tests {
+		got, err := destPath(j, &manualRegexTestClient{path: tt.dest}, sourcePath, rx, "kv/teams/payments/prod/db")
+		assert.NoError(t, err, tt.dest)
+		assert.Equal(t, tt.want, got, tt.dest)
+	}
+
+	for _, dest := range []string{"{{.missing}}", "{{.env", "{{if false}}x{{end}}", "{{.Labels.missing}}"} {
+		_, err := destPath(j, &manualRegexTestClient{path: dest}, sourcePath, rx, "kv/teams/payments/prod/db")
+		assert.Error(t, err, dest)
+	}
+
+	conflict := regexp.MustCompile("kv/(?P<Name>.+)")
+	_, err := destPath(j, &manualRegexTestClient{path: "{{.Name}}"}, "kv/(?P<Name>.+)", conflict, "kv/app")
+	assert.Error(t, err)
+}
+
+func TestDestPathSingleTemplate(t *testing.T) {
+	j := manualRegexSyncJob("kv/app/config", false)
+	got, err := destPath(j, &manualRegexTestClient{path: "{{.Namespace}}/{{index .Segments 1}}"}, "kv/app/config", nil, "kv/app/config")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-namespace/app", got)
+
+	got, err = destPath(j, &manualRegexTestClient{path: "app/config"}, "kv/app/config", nil, "kv/app/config")
+	assert.NoError(t, err)
+	assert.Equal(t, "app/config", got)
+}
@@ -156,11 +156,6 @@ func ScheduleSync(ctx context.Context, e event.VaultEvent) error {
 	return nil
 }
 
-func hasCaptureGroups(regex string) bool {
-	re := regexp.MustCompile(`\([^?][^)]*\)`)
-	return re.MatchString(regex)
-}
-
 func CreateOne(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePath, destPath string) error {
 	l := log.WithFields(log.Fields{
 		"action":      "syncCreate",
@@ -175,42 +170,124 @@ func CreateOne(ctx context.Context, j SyncJob, source, dest SyncClient, sourcePa
 		return errors.New("source path and destination path required")
 	}
 
+	j = destJob(j, dest)
 	if shouldFilterSecret(j, sourcePath, destPath) {
 		return nil
 	}
+	if t, ok := j.splits[dest]; ok {
+		return createSplit(ctx, j, source, dest, sourcePath, destPath, t)
+	}
+	j.destinations.want(dest, destPath)
+	if j.SyncConfig.Spec.Bidirectional != nil {
+		return syncPair(ctx, j, source, dest, sourcePath, destPath)
+	}
 
-	if j.SyncConfig.Spec.DryRun != nil && *j.SyncConfig.Spec.DryRun {
-		l.Info("dry run")
-		return nil
+	if isDryRun(j) {
+		return planCreate(ctx, j, source, dest, sourcePath, destPath)
+	}
+	if destReplicates(dest) {
+		return createReplica(ctx, j, source, dest, sourcePath, destPath)
 	}
 
 	l.Debug("syncing secret")
 
-	ssecret, serr := source.GetSecret(ctx, sourcePath)
-	if serr != nil {
-		return handleCreateOneError(ctx, serr, j, dest, sourcePath, destPath)
+	ssecret, err := readSource(ctx, j, source, sourcePath)
+	if err != nil {
+		return handleCreateOneError(ctx, err, j, dest, sourcePath, destPath)
 	}
+	return writeDest(ctx, j, dest, sourcePath, destPath, "", ssecret)
+}
 
+// readSource reads the source secret and applies the transforms of the job
+func readSource(ctx context.Context, j SyncJob, source SyncClient, sourcePath string) ([]byte, error) {
+	ssecret, serr := j.sources.get(ctx, source, sourcePath)
+	if serr != nil {
+		return nil, driver.Classify(source, serr)
+	}
 	ssecret, serr = transforms.ExecuteTransforms(j.SyncConfig, ssecret)
 	if serr != nil {
-		return handleCreateOneError(ctx, serr, j, dest, sourcePath, destPath)
+		return nil, driver.Permanent(serr)
+	}
+	return ssecret, nil
+}
+
+// writeDest writes the source secret to the destination secret unless it is
+// unchanged. key is the source secret key written, if the destination splits keys.
+func writeDest(ctx context.Context, j SyncJob, dest SyncClient, sourcePath, destPath, key string, ssecret []byte) error {
+	l := log.WithFields(log.Fields{
+		"action":      "writeDest",
+		"source.Path": sourcePath,
+		"dest.Path":   destPath,
+	})
+
+	md := destMetadata(j, dest, sourcePath, destPath)
+	hash := metadataHash(payloadHash(j, ssecret), md.Set)
+
+	// a drift check only compares destinations whose source is unchanged
+	// since the last write, so that a source change missed by a dropped
+	// event is still written, whatever the drift policy
+	var healing bool
+	if policy := driftPolicy(j); policy != "" && supportsDriftCheck(dest) && !changed(j, dest, destPath, hash) {
+		if !checkDrift(ctx, j, dest, sourcePath, destPath, ssecret) {
+			recordKeyWrite(j, dest, sourcePath, destPath, key, hash, metadataKeys(md.Set))
+			j.destinations.success(dest, destPath, hash)
+			return nil
+		}
+		if policy == v1alpha1.DriftPolicyReport {
+			return nil
+		}
+		healing = true
+	}
+
+	if shouldSuspend(ctx, j, dest, sourcePath, destPath) {
+		return nil
 	}
+	cancelPendingDelete(ctx, j, dest, destPath)
 
-	if shouldDryRun(j, dest, sourcePath, destPath) {
+	if !healing && !j.VaultEvent.Force && unchanged(j, dest, destPath, hash) {
+		l.Debug("secret unchanged, skipping write")
+		j.destinations.success(dest, destPath, hash)
 		return nil
 	}
 
-	_, werr := dest.WriteSecret(ctx, j.SyncConfig.ObjectMeta, destPath, ssecret)
+	if err := checkOwnership(ctx, j, dest, destPath, ssecret); err != nil {
+		return handleCreateOneError(ctx, err, j, dest, sourcePath, destPath)
+	}
+
+	werr := limited(driver.WithMetadata(ctx, md), dest, func(ctx context.Context) error {
+		_, err := dest.WriteSecret(ctx, j.SyncConfig.ObjectMeta, destPath, ssecret)
+		return err
+	})
 	if werr != nil {
-		return handleCreateOneError(ctx, werr, j, dest, sourcePath, destPath)
+		return handleCreateOneError(ctx, driver.Classify(dest, werr), j, dest, sourcePath, destPath)
 	}
+	recordKeyWrite(j, dest, sourcePath, destPath, key, hash, metadataKeys(md.Set))
+	j.destinations.success(dest, destPath, hash)
 
 	return handleCreateOneSuccess(ctx, j, dest, sourcePath, destPath)
 }
 
+// syncErrors are the errors of concurrent sync tasks. Unlike a formatted
+// error, the joined errors can still be inspected, e.g. with driver.IsPermanent.
+type syncErrors []error
+
+func (e syncErrors) Error() string {
+	return fmt.Sprintf("errors: %v", []error(e))
+}
+
+func (e syncErrors) Unwrap() []error {
+	return e
+}
+
+// joinErrors joins the errors of concurrent sync tasks
+func joinErrors(errs []error) error {
+	return syncErrors(errs)
+}
+
 func handleCreateOneError(ctx context.Context, err error, j SyncJob, dest SyncClient, sourcePath, destPath string) error {
 	l := log.WithFields(log.Fields{"action": "handleCreateOneError", "error": err})
 	l.Error("failed to sync secret")
+	j.destinations.failure(dest, destPath, err)
 	backend.WriteEvent(
 		ctx,
 		j.SyncConfig.Namespace,
@@ -242,7 +319,12 @@ func LoopWildcardRecursive(ctx context.Context, source SyncClient, sourcePath st
 
 	var fullList []string
 	sp := findHighestNonRegexPath(sourcePath)
-	list, err := source.ListSecrets(ctx, sp)
+	var list []string
+	err := limited(ctx, source, func(ctx context.Context) error {
+		var err error
+		list, err = source.ListSecrets(ctx, sp)
+		return err
+	})
 	if err != nil {
 		l.Error(err)
 		return fullList, err
@@ -277,7 +359,7 @@ func NeedsSync(sc v1alpha1.VaultSecretSync, evt event.VaultEvent) bool {
 		"eventVault": evt.Address,
 	})
 
-	if sc.Spec.Suspend != nil && *sc.Spec.Suspend {
+	if sc.Suspended() {
 		l.Trace("sync suspended")
 		return false
 	}
@@ -292,6 +374,32 @@ func NeedsSync(sc v1alpha1.VaultSecretSync, evt event.VaultEvent) bool {
 		return false
 	}
 
+	if evt.Operation == logical.DeleteOperation && !sc.SyncsDeletes() {
+		l.Trace("delete operation not allowed")
+		return false
+	}
+
+	if sourceEvent(sc, evt) || reversedEvent(sc, evt) {
+		l.Debug("found source, needs sync")
+		return true
+	}
+
+	l.Trace("no match")
+	return false
+}
+
+// sourceEvent returns true if the event is of the source of the sync config
+func sourceEvent(sc v1alpha1.VaultSecretSync, evt event.VaultEvent) bool {
+	l := log.WithFields(log.Fields{
+		"action":     "sourceEvent",
+		"eventPath":  evt.Path,
+		"eventVault": evt.Address,
+	})
+
+	if sc.Spec.Source == nil {
+		return false
+	}
+
 	if evt.Address != sc.Spec.Source.Address {
 		l.Tracef("no vault addr match. %s != %s", evt.Address, sc.Spec.Source.Address)
 		return false
@@ -304,11 +412,6 @@ func NeedsSync(sc v1alpha1.VaultSecretSync, evt event.VaultEvent) bool {
 		return false
 	}
 
-	if evt.Operation == logical.DeleteOperation && (sc.Spec.SyncDelete != nil && !*sc.Spec.SyncDelete) {
-		l.Trace("delete operation not allowed")
-		return false
-	}
-
 	sourcePath := sc.Spec.Source.Path
 	ss := strings.Split(sourcePath, "/")
 	ms := ss
@@ -324,13 +427,7 @@ func NeedsSync(sc v1alpha1.VaultSecretSync, evt event.VaultEvent) bool {
 	})
 	l.Trace("checking path")
 
-	if isPathMatch(sourcePath, evt.Path) || isPathMatch(dp, evt.Path) || isPathMatch(mp, evt.Path) {
-		l.Debug("found source, needs sync")
-		return true
-	}
-
-	l.Trace("no match")
-	return false
+	return isPathMatch(sourcePath, evt.Path) || isPathMatch(dp, evt.Path) || isPathMatch(mp, evt.Path)
 }
 
 func ManualTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical.Operation) error {
@@ -345,6 +442,8 @@ func ManualTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, op logical
 		SyncName:  name,
 		Operation: op,
 		Manual:    true,
+		// a force-sync annotation writes destinations even if unchanged
+		Force: cfg.ObjectMeta.Annotations["force-sync"] != "",
 	}
 	return queue.Q.Push(evt)
 }
@@ -380,32 +479,105 @@ func doSync(ctx context.Context, j SyncJob) error {
 	l.Trace("start")
 	defer l.Trace("end")
 	startTime := time.Now()
+	j.drift = &driftTracker{}
+	j.destinations = newDestinationTracker()
+	j.plan = newPlanTracker()
+	j.deletes = &deleteBudget{}
+	inv, err := backend.GetInventory(ctx, j.SyncConfig)
+	if err != nil {
+		// without the inventory every destination is written
+		l.WithError(err).Warn("failed to load inventory")
+		inv = nil
+	}
+	j.inventory = inv
+	// the inventory and status are recorded even if the sync is cancelled
+	// by shutdown, or times out
+	bctx := context.WithoutCancel(ctx)
+	defer func() {
+		if err := inv.Save(bctx); err != nil {
+			l.WithError(err).Error("failed to save inventory")
+		}
+	}()
 	metrics.SyncsTotal.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Inc()
 	metrics.ActiveSyncs.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Inc()
 
-	scs, err := clientGenerator(ctx, j)
+	sctx, cancel := withTimeout(ctx, syncTimeout(j.SyncConfig))
+	defer cancel()
+	fail := func(err error) error {
+		if requeueCancelled(ctx, j) {
+			metrics.ActiveSyncs.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Dec()
+			return err
+		}
+		return handleSyncError(bctx, syncTimeoutError(sctx, j, err), j, startTime)
+	}
+
+	held, err := holdOutsideWindow(bctx, j, startTime)
 	if err != nil {
-		return handleSyncError(ctx, err, j, startTime)
+		return fail(err)
 	}
-	if scs == nil || scs.Source == nil || scs.Dest == nil {
-		return handleSyncError(ctx, errors.New("failed to create clients"), j, startTime)
+	if held {
+		metrics.ActiveSyncs.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Dec()
+		return nil
+	}
+
+	j = sourceVersionJob(j)
+	j = pairJob(j)
+	if v := j.SyncConfig.Spec.Source; v != nil && v.Version > 0 {
+		l.WithField("version", v.Version).Info("syncing pinned source version")
 	}
-	defer scs.CloseClients(ctx)
-	switch j.VaultEvent.Operation {
-	case logical.CreateOperation, logical.UpdateOperation:
+	scs, err := clientGenerator(sctx, j)
+	if err != nil {
+		return fail(err)
+	}
+	if scs == nil || scs.Source == nil || scs.Dest == nil {
+		return handleSyncError(bctx, errors.New("failed to create clients"), j, startTime)
+	}
+	defer scs.CloseClients(bctx)
+	j.stores = scs.stores
+	j.splits = scs.splits
+	j.sources = newSourceCache()
+	switch {
+	case j.VaultEvent.PendingDeletes:
+		l.Trace("pending deletes")
+	case j.VaultEvent.Rotation != "":
+		l.Trace("rotation")
+		err = syncRotation(sctx, scs, j)
+	case j.VaultEvent.Operation == logical.CreateOperation, j.VaultEvent.Operation == logical.UpdateOperation:
 		l.Trace("create operation")
-		err = SyncCreate(ctx, scs, j)
-	case logical.DeleteOperation:
+		err = SyncCreate(sctx, scs, j)
+	case j.VaultEvent.Operation == logical.DeleteOperation:
 		l.Trace("delete operation")
-		err = SyncDelete(ctx, scs, j)
+		err = SyncDelete(sctx, scs, j)
 	default:
 		l.Trace("operation not defined")
-		err = errors.New("operation not defined")
+		err = driver.Permanent(errors.New("operation not defined"))
+	}
+	if err == nil {
+		err = pruneOrphans(sctx, scs, j)
+	}
+	if err == nil {
+		err = processPendingDeletes(sctx, scs, j)
 	}
 	if err != nil {
-		return handleSyncError(ctx, err, j, startTime)
+		return fail(err)
+	}
+	err = handleSyncSuccess(bctx, j, startTime)
+	finishRemoval(bctx, j)
+	return err
+}
+
+// syncTimeoutError marks err as a timeout if the sync exceeded its timeout
+func syncTimeoutError(sctx context.Context, j SyncJob, err error) error {
+	if !errors.Is(sctx.Err(), context.DeadlineExceeded) {
+		return err
 	}
-	return handleSyncSuccess(ctx, j, startTime)
+	log.WithFields(log.Fields{
+		"action":    "syncTimeoutError",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+	}).WithError(err).Warn("sync timed out")
+	metrics.SyncTimeouts.WithLabelValues(j.SyncConfig.Namespace, j.SyncConfig.Name).Inc()
+	return driver.Timeout(sctx, err)
 }
 
 func buildSyncJobs(evt event.VaultEvent) ([]SyncJob, []string, []driver.DriverName) {
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	log "github.com/sirupsen/logrus"
+)
+
+// sourceVersionJob returns the job reading the source version of its event,
+// if any, in place of the version of spec.source. The source of the sync
+// config is copied rather than modified, as it is shared with the cache.
+func sourceVersionJob(j SyncJob) SyncJob {
+	if j.VaultEvent.SourceVersion <= 0 || j.SyncConfig.Spec.Source == nil {
+		return j
+	}
+	src := *j.SyncConfig.Spec.Source
+	src.Version = j.VaultEvent.SourceVersion
+	j.SyncConfig.Spec.Source = &src
+	return j
+}
+
+// VersionTrigger syncs the version of the source secrets to every
+// destination, such as to roll back a bad secret without writing the
+// previous version back to Vault. Destinations are written even if
+// unchanged. Later syncs read the version of spec.source again.
+func VersionTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, version int) error {
+	l := log.WithFields(log.Fields{"action": "VersionTrigger"})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l = l.WithFields(log.Fields{"name": name, "version": version})
+	l.Debug("version trigger")
+	evt := event.VaultEvent{
+		SyncName:      name,
+		Operation:     logical.UpdateOperation,
+		Manual:        true,
+		Force:         true,
+		SourceVersion: version,
+	}
+	return queue.Q.Push(evt)
+}
@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/stores/vault"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSourceVersionJob(t *testing.T) {
+	source := &vault.VaultClient{Path: "kv/app", Version: 4}
+	j := SyncJob{SyncConfig: v1alpha1.VaultSecretSync{Spec: v1alpha1.VaultSecretSyncSpec{Source: source}}}
+
+	// the version of spec.source is read unless the event sets one
+	assert.Equal(t, 4, sourceVersionJob(j).SyncConfig.Spec.Source.Version)
+
+	j.VaultEvent.SourceVersion = 2
+	pinned := sourceVersionJob(j)
+	assert.Equal(t, 2, pinned.SyncConfig.Spec.Source.Version)
+	assert.Equal(t, "kv/app", pinned.SyncConfig.Spec.Source.Path)
+	// the shared sync config is not modified
+	assert.Equal(t, 4, source.Version)
+}
+
+func TestRedriveKeepsSourceVersion(t *testing.T) {
+	q := setPublishTestQueue(t)
+	cfg := v1alpha1.VaultSecretSync{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"}}
+	dl := v1alpha1.DeadLetter{Operation: "update", Manual: true, SourceVersion: 3}
+	assert.NoError(t, RedriveTrigger(context.Background(), cfg, dl))
+	if assert.Len(t, q.published, 1) {
+		assert.Equal(t, 3, q.published[0].SourceVersion)
+	}
+
+	// a rollback held outside a sync window is released as a rollback
+	he := v1alpha1.HeldEvent{Operation: "update", Manual: true, SourceVersion: 3}
+	assert.NoError(t, ReleaseTrigger(context.Background(), cfg, he, false))
+	if assert.Len(t, q.published, 2) {
+		assert.Equal(t, event.VaultEvent{SyncName: q.published[1].SyncName, Operation: "update", Manual: true, SourceVersion: 3}, q.published[1])
+	}
+}
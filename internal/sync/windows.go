@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/robertlestak/vault-secret-sync/internal/backend"
+	"github.com/robertlestak/vault-secret-sync/internal/event"
+	"github.com/robertlestak/vault-secret-sync/internal/queue"
+	"github.com/robertlestak/vault-secret-sync/internal/syncwindow"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// holdOutsideWindow holds the event of the job if the sync config is outside
+// its sync windows, returning true if the event was held. Dry runs, which
+// change nothing, and overridden events are not held. Syncs with only some
+// destinations in dry run are held.
+func holdOutsideWindow(ctx context.Context, j SyncJob, now time.Time) (bool, error) {
+	if len(j.SyncConfig.Spec.SyncWindows) == 0 || j.VaultEvent.IgnoreSyncWindow || j.SyncConfig.DryRun() {
+		return false, nil
+	}
+	l := log.WithFields(log.Fields{
+		"action":    "holdOutsideWindow",
+		"name":      j.SyncConfig.Name,
+		"namespace": j.SyncConfig.Namespace,
+		"path":      j.VaultEvent.Path,
+	})
+	open, next, err := syncwindow.Open(j.SyncConfig.Spec.SyncWindows, now)
+	if err != nil {
+		return false, driver.Permanent(err)
+	}
+	if open {
+		return false, nil
+	}
+	l.WithField("next", next).Info("outside sync window, holding event")
+	he := v1alpha1.HeldEvent{
+		Operation:      string(j.VaultEvent.Operation),
+		Path:           j.VaultEvent.Path,
+		Manual:         j.VaultEvent.Manual,
+		Force:          j.VaultEvent.Force,
+		DriftCheck:     j.VaultEvent.DriftCheck,
+		PendingDeletes: j.VaultEvent.PendingDeletes,
+		SourceVersion:  j.VaultEvent.SourceVersion,
+		Time:           metav1.NewTime(now),
+	}
+	added, err := backend.HoldEvent(ctx, j.SyncConfig, he, next)
+	if err != nil {
+		return false, err
+	}
+	if added {
+		msg := "sync held outside sync windows, no sync window is scheduled"
+		if !next.IsZero() {
+			msg = fmt.Sprintf("sync held outside sync windows until %s", next.UTC().Format(time.RFC3339))
+		}
+		backend.WriteEvent(ctx, j.SyncConfig.Namespace, j.SyncConfig.Name, "Normal", string(backend.SyncStatusHeld), msg)
+	}
+	return true, nil
+}
+
+// ReleaseTrigger syncs a held event of the sync config, regardless of the
+// sync windows if overridden
+func ReleaseTrigger(ctx context.Context, cfg v1alpha1.VaultSecretSync, he v1alpha1.HeldEvent, override bool) error {
+	l := log.WithFields(log.Fields{"action": "ReleaseTrigger"})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	name := backend.InternalName(cfg.Namespace, cfg.Name)
+	l = l.WithFields(log.Fields{"name": name, "path": he.Path, "op": he.Operation, "override": override})
+	l.Debug("release trigger")
+	evt := event.VaultEvent{
+		SyncName:         name,
+		Path:             he.Path,
+		Operation:        logical.Operation(he.Operation),
+		Manual:           he.Manual,
+		Force:            he.Force,
+		DriftCheck:       he.DriftCheck,
+		PendingDeletes:   he.PendingDeletes,
+		IgnoreSyncWindow: override,
+		SourceVersion:    he.SourceVersion,
+	}
+	if cfg.Spec.Source != nil {
+		evt.Address = cfg.Spec.Source.Address
+		evt.Namespace = cfg.Spec.Source.Namespace
+	}
+	// publish rather than push so that releasing many held events waits
+	// for the queue instead of dropping events
+	return queue.Q.Publish(ctx, evt)
+}
@@ -0,0 +1,46 @@
+package syncwindow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	valid := []string{"* * * * *", "0 22 * * mon-fri", "*/15 9-17 * jan,jul 1-5", "30 2 1 * 7", "@daily", "@hourly"}
+	for _, s := range valid {
+		_, err := Parse(s)
+		assert.NoError(t, err, s)
+	}
+	invalid := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "x * * * *"}
+	for _, s := range invalid {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// a wednesday
+	base := time.Date(2024, 5, 15, 10, 30, 20, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 5, 15, 10, 31, 0, 0, time.UTC)},
+		{"0 22 * * mon-fri", time.Date(2024, 5, 15, 22, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat", time.Date(2024, 5, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{"0 0 20 * fri", time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, s.Next(base), tt.spec)
+	}
+}
@@ -0,0 +1,130 @@
+// Package syncwindow evaluates the sync windows of a VaultSecretSync
+package syncwindow
+
+import (
+	"fmt"
+	"time"
+
+	// the operator image may not ship a time zone database
+	_ "time/tzdata"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+)
+
+// maxOpenSearch bounds the number of window boundaries checked for the next
+// time syncs are allowed
+const maxOpenSearch = 1000
+
+// window is a parsed sync window
+type window struct {
+	kind     v1alpha1.SyncWindowKind
+	schedule *Schedule
+	duration time.Duration
+	loc      *time.Location
+}
+
+func parse(windows []v1alpha1.SyncWindow) ([]window, error) {
+	var parsed []window
+	for i, w := range windows {
+		s, err := Parse(w.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("syncWindows[%d]: %v", i, err)
+		}
+		if w.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("syncWindows[%d]: duration must be positive", i)
+		}
+		if w.Kind != v1alpha1.SyncWindowAllow && w.Kind != v1alpha1.SyncWindowDeny {
+			return nil, fmt.Errorf("syncWindows[%d]: invalid kind %q", i, w.Kind)
+		}
+		loc := time.UTC
+		if w.TimeZone != "" {
+			if loc, err = time.LoadLocation(w.TimeZone); err != nil {
+				return nil, fmt.Errorf("syncWindows[%d]: invalid timeZone: %v", i, err)
+			}
+		}
+		parsed = append(parsed, window{kind: w.Kind, schedule: s, duration: w.Duration.Duration, loc: loc})
+	}
+	return parsed, nil
+}
+
+// start returns the start of the window active at t, if any. The window is
+// active from each activation of its schedule for its duration.
+func (w window) start(t time.Time) (time.Time, bool) {
+	t = t.In(w.loc)
+	a := w.schedule.Next(t.Add(-w.duration))
+	if a.IsZero() || a.After(t) {
+		return time.Time{}, false
+	}
+	return a, true
+}
+
+// allowed returns true if syncs are allowed at t: no deny window is active,
+// and either there are no allow windows or an allow window is active
+func allowed(windows []window, t time.Time) bool {
+	hasAllow, inAllow := false, false
+	for _, w := range windows {
+		_, active := w.start(t)
+		switch w.kind {
+		case v1alpha1.SyncWindowDeny:
+			if active {
+				return false
+			}
+		case v1alpha1.SyncWindowAllow:
+			hasAllow = true
+			inAllow = inAllow || active
+		}
+	}
+	return !hasAllow || inAllow
+}
+
+// nextBoundary returns the first time after t at which a window opens or
+// closes, or the zero time if there is none
+func nextBoundary(windows []window, t time.Time) time.Time {
+	var next time.Time
+	earlier := func(c time.Time) {
+		if next.IsZero() || c.Before(next) {
+			next = c
+		}
+	}
+	for _, w := range windows {
+		if s, ok := w.start(t); ok {
+			earlier(s.Add(w.duration))
+		}
+		if n := w.schedule.Next(t.In(w.loc)); !n.IsZero() {
+			earlier(n)
+		}
+	}
+	return next
+}
+
+// Open reports whether syncs are allowed at now by the sync windows, and if
+// not, the next time they are allowed. The next time is zero if the windows
+// never allow syncs.
+func Open(windows []v1alpha1.SyncWindow, now time.Time) (bool, time.Time, error) {
+	parsed, err := parse(windows)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if allowed(parsed, now) {
+		return true, now, nil
+	}
+	// syncs can only become allowed when a window opens or closes, so
+	// each boundary is checked in turn
+	t := now
+	for i := 0; i < maxOpenSearch; i++ {
+		t = nextBoundary(parsed, t)
+		if t.IsZero() {
+			break
+		}
+		if allowed(parsed, t) {
+			return false, t, nil
+		}
+	}
+	return false, time.Time{}, nil
+}
+
+// Validate returns an error if the sync windows are invalid
+func Validate(windows []v1alpha1.SyncWindow) error {
+	_, err := parse(windows)
+	return err
+}
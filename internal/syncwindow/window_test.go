@@ -0,0 +1,86 @@
+package syncwindow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robertlestak/vault-secret-sync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func syncWindow(kind v1alpha1.SyncWindowKind, schedule string, d time.Duration, tz string) v1alpha1.SyncWindow {
+	return v1alpha1.SyncWindow{Kind: kind, Schedule: schedule, Duration: metav1.Duration{Duration: d}, TimeZone: tz}
+}
+
+func TestOpenAllow(t *testing.T) {
+	windows := []v1alpha1.SyncWindow{syncWindow(v1alpha1.SyncWindowAllow, "0 22 * * mon-fri", 3*time.Hour, "")}
+
+	// a wednesday evening, within the window
+	open, _, err := Open(windows, time.Date(2024, 5, 15, 23, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, open)
+
+	// past midnight, still within the window opened the day before
+	open, _, err = Open(windows, time.Date(2024, 5, 16, 0, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, open)
+
+	open, next, err := Open(windows, time.Date(2024, 5, 16, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.False(t, open)
+	assert.Equal(t, time.Date(2024, 5, 16, 22, 0, 0, 0, time.UTC), next)
+
+	// a friday night opens the next window on monday
+	_, next, _ = Open(windows, time.Date(2024, 5, 18, 2, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 5, 20, 22, 0, 0, 0, time.UTC), next)
+}
+
+func TestOpenDeny(t *testing.T) {
+	windows := []v1alpha1.SyncWindow{
+		syncWindow(v1alpha1.SyncWindowAllow, "0 8 * * *", 10*time.Hour, ""),
+		syncWindow(v1alpha1.SyncWindowDeny, "0 12 * * *", time.Hour, ""),
+	}
+	open, _, err := Open(windows, time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, open)
+
+	// the deny window takes precedence over the allow window
+	open, next, err := Open(windows, time.Date(2024, 5, 15, 12, 15, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.False(t, open)
+	assert.Equal(t, time.Date(2024, 5, 15, 13, 0, 0, 0, time.UTC), next)
+
+	// deny windows alone allow syncs at any other time
+	open, _, _ = Open(windows[1:], time.Date(2024, 5, 15, 20, 0, 0, 0, time.UTC))
+	assert.True(t, open)
+}
+
+func TestOpenTimeZone(t *testing.T) {
+	windows := []v1alpha1.SyncWindow{syncWindow(v1alpha1.SyncWindowAllow, "0 9 * * *", time.Hour, "America/New_York")}
+	// 9:30 in new york during daylight saving time
+	open, _, err := Open(windows, time.Date(2024, 7, 1, 13, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.True(t, open)
+
+	open, next, err := Open(windows, time.Date(2024, 7, 1, 9, 30, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.False(t, open)
+	assert.True(t, next.Equal(time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC)))
+}
+
+func TestOpenNever(t *testing.T) {
+	windows := []v1alpha1.SyncWindow{syncWindow(v1alpha1.SyncWindowAllow, "0 0 30 2 *", time.Hour, "")}
+	open, next, err := Open(windows, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, open)
+	assert.True(t, next.IsZero())
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.Error(t, Validate([]v1alpha1.SyncWindow{syncWindow(v1alpha1.SyncWindowAllow, "bad", time.Hour, "")}))
+	assert.Error(t, Validate([]v1alpha1.SyncWindow{syncWindow(v1alpha1.SyncWindowAllow, "@daily", 0, "")}))
+	assert.Error(t, Validate([]v1alpha1.SyncWindow{syncWindow("maybe", "@daily", time.Hour, "")}))
+	assert.Error(t, Validate([]v1alpha1.SyncWindow{syncWindow(v1alpha1.SyncWindowDeny, "@daily", time.Hour, "Mars/Olympus")}))
+}
@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RetryClassifier is implemented by drivers which can tell transient errors,
+// such as throttling or server errors, from errors which will fail again
+// if retried, such as permission or validation errors
+type RetryClassifier interface {
+	IsRetryable(err error) bool
+}
+
+// StatusError is returned by drivers for unsuccessful HTTP responses
+type StatusError struct {
+	Op         string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to %s: %s", e.Op, e.Status)
+}
+
+// RetryableStatus returns true if the HTTP status code indicates a transient failure
+func RetryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout ||
+		code == http.StatusTooManyRequests ||
+		code >= http.StatusInternalServerError
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as an error which will not succeed if retried
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true if err was marked permanent. Joined errors are
+// only permanent if every joined error is permanent, as retrying will
+// retry the transient errors.
+func IsPermanent(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case *permanentError:
+		return true
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		for _, je := range errs {
+			if !IsPermanent(je) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	default:
+		return IsPermanent(errors.Unwrap(err))
+	}
+}
+
+type timeoutError struct {
+	err error
+}
+
+func (e *timeoutError) Error() string {
+	return "timeout: " + e.err.Error()
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+// Timeout marks err as a timeout if ctx exceeded its deadline. Drivers do not
+// always wrap context errors, so the context is checked rather than err.
+func Timeout(ctx context.Context, err error) error {
+	if err == nil || IsTimeout(err) || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &timeoutError{err: err}
+}
+
+// IsTimeout returns true if err, or any joined error, was marked a timeout
+func IsTimeout(err error) bool {
+	var te *timeoutError
+	return errors.As(err, &te)
+}
+
+// Classify marks err permanent if the driver reports that it is not retryable.
+// Errors from drivers which do not classify their errors are retryable, as
+// are timeouts.
+func Classify(d any, err error) error {
+	if err == nil || IsTimeout(err) {
+		return err
+	}
+	if c, ok := d.(RetryClassifier); ok && !c.IsRetryable(err) {
+		return Permanent(err)
+	}
+	return err
+}
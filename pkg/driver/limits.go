@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit is the concurrency and rate limit of store operations, such as
+// reading, writing, listing or deleting a secret
+type Limit struct {
+	// MaxConcurrent is the maximum number of concurrent operations, 0 is unlimited
+	MaxConcurrent int `json:"maxConcurrent" yaml:"maxConcurrent"`
+	// RequestsPerSecond is the rate at which operations are started, 0 is unlimited
+	RequestsPerSecond float64 `json:"requestsPerSecond" yaml:"requestsPerSecond"`
+	// Burst is the number of operations which can be started at once
+	// above the rate. Defaults to the rate, and at least 1.
+	Burst int `json:"burst" yaml:"burst"`
+	// APIRequestsPerSecond is the rate at which API requests are sent,
+	// including retries, for drivers which send several requests per
+	// operation. It applies on top of the operation rate. 0 is unlimited.
+	APIRequestsPerSecond float64 `json:"apiRequestsPerSecond" yaml:"apiRequestsPerSecond"`
+	// APIBurst is the number of API requests which can be sent at once
+	// above the rate. Defaults to the rate, and at least 1.
+	APIBurst int `json:"apiBurst" yaml:"apiBurst"`
+}
+
+// DefaultDriverLimits are the limits of drivers which are not configured.
+// GitHub's secondary rate limits are easily tripped, so both its operations
+// and each of their API requests are rate limited unless configured otherwise.
+var DefaultDriverLimits = map[DriverName]Limit{
+	DriverNameGitHub: {RequestsPerSecond: 0.5, Burst: 1, APIRequestsPerSecond: 0.5, APIBurst: 1},
+}
+
+// limiter enforces a Limit
+type limiter struct {
+	sem  chan struct{}
+	rate *rate.Limiter
+	api  *rate.Limiter
+}
+
+func newLimiter(l Limit) *limiter {
+	lm := &limiter{}
+	if l.MaxConcurrent > 0 {
+		lm.sem = make(chan struct{}, l.MaxConcurrent)
+	}
+	lm.rate = newRate(l.RequestsPerSecond, l.Burst)
+	lm.api = newRate(l.APIRequestsPerSecond, l.APIBurst)
+	return lm
+}
+
+// newRate returns a token bucket of the rate and burst, or nil if the rate
+// is unlimited
+func newRate(rps float64, burst int) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rps)))
+	}
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// acquire waits for a concurrency slot, returning false if the context is done
+func (lm *limiter) acquire(ctx context.Context) bool {
+	if lm == nil || lm.sem == nil {
+		return true
+	}
+	select {
+	case lm.sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (lm *limiter) release() {
+	if lm == nil || lm.sem == nil {
+		return
+	}
+	<-lm.sem
+}
+
+// wait waits for the rate limit
+func (lm *limiter) wait(ctx context.Context) error {
+	if lm == nil || lm.rate == nil {
+		return nil
+	}
+	return lm.rate.Wait(ctx)
+}
+
+var (
+	limitsMu       sync.RWMutex
+	globalLimiter  *limiter
+	driverLimiters = defaultDriverLimiters()
+)
+
+func defaultDriverLimiters() map[DriverName]*limiter {
+	lms := make(map[DriverName]*limiter)
+	for d, l := range DefaultDriverLimits {
+		lms[d] = newLimiter(l)
+	}
+	return lms
+}
+
+// SetLimits sets the global limit shared by all drivers, and the limit of
+// each driver, replacing the default driver limits. The limits are shared
+// by every sync in the process.
+func SetLimits(global Limit, drivers map[DriverName]Limit) {
+	lms := defaultDriverLimiters()
+	for d, l := range drivers {
+		lms[d] = newLimiter(l)
+	}
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	globalLimiter = newLimiter(global)
+	driverLimiters = lms
+}
+
+// Acquire waits until an operation of the driver is allowed by both the
+// driver and global limits. The returned func must be called once the
+// operation is complete.
+func Acquire(ctx context.Context, d DriverName) (func(), error) {
+	limitsMu.RLock()
+	global, dl := globalLimiter, driverLimiters[d]
+	limitsMu.RUnlock()
+	// the driver slot is always acquired first, so that operations of a
+	// saturated driver don't hold global slots needed by other drivers
+	if !dl.acquire(ctx) {
+		return nil, ctx.Err()
+	}
+	if !global.acquire(ctx) {
+		dl.release()
+		return nil, ctx.Err()
+	}
+	release := func() {
+		global.release()
+		dl.release()
+	}
+	if err := dl.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	if err := global.wait(ctx); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// APILimiter returns the API request rate limiter of the driver, shared by
+// every client of the driver, or nil if its API requests are not limited.
+// Clients must wait on it before sending each request, including retries.
+func APILimiter(d DriverName) *rate.Limiter {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	if lm := driverLimiters[d]; lm != nil {
+		return lm.api
+	}
+	return nil
+}
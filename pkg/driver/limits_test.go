@@ -0,0 +1,82 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireConcurrency(t *testing.T) {
+	SetLimits(Limit{MaxConcurrent: 2}, map[DriverName]Limit{DriverNameAws: {MaxConcurrent: 1}})
+	defer SetLimits(Limit{}, nil)
+	ctx := context.Background()
+
+	release, err := Acquire(ctx, DriverNameAws)
+	assert.NoError(t, err)
+
+	// the aws slot is taken
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = Acquire(tctx, DriverNameAws)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the second global slot is still free for other drivers
+	releaseGcp, err := Acquire(ctx, DriverNameGcp)
+	assert.NoError(t, err)
+
+	// both global slots are taken
+	tctx2, cancel2 := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel2()
+	_, err = Acquire(tctx2, DriverNameVault)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	releaseGcp()
+	release, err = Acquire(ctx, DriverNameAws)
+	assert.NoError(t, err)
+	release()
+}
+
+func TestAcquireRate(t *testing.T) {
+	SetLimits(Limit{}, map[DriverName]Limit{DriverNameVault: {RequestsPerSecond: 1, Burst: 1}})
+	defer SetLimits(Limit{}, nil)
+	ctx := context.Background()
+
+	release, err := Acquire(ctx, DriverNameVault)
+	assert.NoError(t, err)
+	release()
+
+	// the burst is used, so the next operation waits for a token
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = Acquire(tctx, DriverNameVault)
+	assert.Error(t, err)
+
+	// unconfigured drivers are not limited
+	release, err = Acquire(ctx, DriverNameAws)
+	assert.NoError(t, err)
+	release()
+}
+
+func TestSetLimitsKeepsDefaults(t *testing.T) {
+	SetLimits(Limit{}, nil)
+	assert.NotNil(t, driverLimiters[DriverNameGitHub].rate)
+
+	SetLimits(Limit{}, map[DriverName]Limit{DriverNameGitHub: {}})
+	defer SetLimits(Limit{}, nil)
+	assert.Nil(t, driverLimiters[DriverNameGitHub].rate)
+}
+
+func TestAPILimiter(t *testing.T) {
+	SetLimits(Limit{}, nil)
+	gh := APILimiter(DriverNameGitHub)
+	assert.NotNil(t, gh)
+	assert.Same(t, gh, APILimiter(DriverNameGitHub), "the limiter is shared by all clients")
+	assert.Nil(t, APILimiter(DriverNameAws))
+
+	SetLimits(Limit{}, map[DriverName]Limit{DriverNameAws: {APIRequestsPerSecond: 10}})
+	defer SetLimits(Limit{}, nil)
+	assert.Equal(t, 10, APILimiter(DriverNameAws).Burst())
+}
@@ -0,0 +1,64 @@
+package driver
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Metadata is the custom metadata of a source secret propagated to a
+// destination secret, such as tags or labels
+type Metadata struct {
+	// Set is the metadata to set on the destination secret
+	Set map[string]string
+	// Remove are the keys of metadata propagated by a previous write which
+	// are no longer set, and are removed from the destination secret
+	Remove []string
+}
+
+// Empty returns true if the metadata neither sets nor removes any keys
+func (m Metadata) Empty() bool {
+	return len(m.Set) == 0 && len(m.Remove) == 0
+}
+
+// MetadataReader is implemented by drivers which read the custom metadata
+// of a secret along with its data
+type MetadataReader interface {
+	GetSecretWithMetadata(ctx context.Context, path string) ([]byte, map[string]string, error)
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns the context of a write which propagates the metadata
+// to the destination secret
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	if md.Empty() {
+		return ctx
+	}
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFrom returns the metadata to propagate by the write of the context
+func MetadataFrom(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// MapChars maps s to the charset of a store, replacing each rune which is
+// not allowed with repl and truncating the result to max bytes if max > 0
+func MapChars(s string, allowed func(r rune) bool, repl rune, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if allowed(r) {
+			return r
+		}
+		return repl
+	}, s)
+	if max > 0 && len(s) > max {
+		s = s[:max]
+		// don't split a multi-byte rune
+		for len(s) > 0 && !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+	return s
+}
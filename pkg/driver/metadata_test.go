@@ -0,0 +1,25 @@
+package driver
+
+import (
+	"context"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapChars(t *testing.T) {
+	lower := func(r rune) bool { return unicode.IsLower(r) }
+	assert.Equal(t, "a__c", MapChars("aB-c", lower, '_', 0))
+	assert.Equal(t, "abcd", MapChars("abcdef", lower, '_', 4))
+	// multi-byte runes are not split when truncated
+	assert.Equal(t, "ab", MapChars("abé", lower, '_', 3))
+}
+
+func TestWithMetadata(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, ctx, WithMetadata(ctx, Metadata{}))
+	md := Metadata{Remove: []string{"owner"}}
+	assert.Equal(t, md, MetadataFrom(WithMetadata(ctx, md)))
+	assert.True(t, MetadataFrom(ctx).Empty())
+}
@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OwnerKey is the tag, label, or metadata key identifying the VaultSecretSync
+// which owns a destination secret
+const OwnerKey = "vault-secret-sync-owner"
+
+// maxLabelValue is the maximum length of a label value in stores such as GCP
+const maxLabelValue = 63
+
+// Owner returns the owner of destination secrets written by the sync config
+// with the given metadata, or an empty string if the metadata has no name
+func Owner(meta metav1.ObjectMeta) string {
+	if meta.Name == "" {
+		return ""
+	}
+	return meta.Namespace + "/" + meta.Name
+}
+
+// OwnerLabel returns the owner in a format valid as a label value, which
+// only allows lowercase letters, digits, dashes and underscores. Namespaces
+// and names cannot contain underscores, so the label is unique per owner.
+func OwnerLabel(owner string) string {
+	l := strings.NewReplacer("/", "_", ".", "_").Replace(owner)
+	if len(l) <= maxLabelValue {
+		return l
+	}
+	h := sha256.Sum256([]byte(owner))
+	suffix := "_" + hex.EncodeToString(h[:])[:16]
+	return l[:maxLabelValue-len(suffix)] + suffix
+}
+
+// Ownable is implemented by drivers which stamp the destination secrets they
+// write with the owning VaultSecretSync
+type Ownable interface {
+	// Owners returns the current owner of each existing secret which writing
+	// payload to path would replace or delete, keyed by secret name. Secrets owned by
+	// owner map to owner, and secrets without an owner map to "". Secrets
+	// which do not exist are not returned.
+	Owners(ctx context.Context, owner, path string, payload []byte) (map[string]string, error)
+}
@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretVersion is a version of a replicated secret
+type SecretVersion struct {
+	Version int `json:"version"`
+	// Data is the data of the version, nil if it is deleted or destroyed
+	Data      map[string]any `json:"data,omitempty"`
+	Deleted   bool           `json:"deleted,omitempty"`
+	Destroyed bool           `json:"destroyed,omitempty"`
+}
+
+// Replica is a secret replicated with its metadata configuration, custom
+// metadata and versions, rather than its data alone
+type Replica struct {
+	MaxVersions        int               `json:"maxVersions"`
+	CASRequired        bool              `json:"casRequired"`
+	DeleteVersionAfter string            `json:"deleteVersionAfter"`
+	CustomMetadata     map[string]string `json:"customMetadata,omitempty"`
+	CurrentVersion     int               `json:"currentVersion"`
+	// History is true if Versions holds every version of the secret, rather
+	// than the current version alone
+	History bool `json:"history,omitempty"`
+	// Versions are the versions of the secret, oldest first. Versions which
+	// are no longer kept are not included.
+	Versions []SecretVersion `json:"versions"`
+}
+
+// Replicator is implemented by drivers which replicate secrets between stores
+// of the same driver
+type Replicator interface {
+	// ReadReplica reads the secret at path, with every version if history
+	// is true, or the current version otherwise
+	ReadReplica(ctx context.Context, path string, history bool) (*Replica, error)
+	// WriteReplica writes the replica to the secret at path, stamped with
+	// the owner of the sync config with the given metadata
+	WriteReplica(ctx context.Context, meta metav1.ObjectMeta, path string, r *Replica) error
+}
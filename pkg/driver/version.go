@@ -0,0 +1,13 @@
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// Versioned is implemented by drivers whose secrets are versioned
+type Versioned interface {
+	// CurrentVersion returns the current version of the secret at path and
+	// when it was last updated, or 0 if the secret does not exist
+	CurrentVersion(ctx context.Context, path string) (int, time.Time, error)
+}
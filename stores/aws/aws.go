@@ -3,6 +3,10 @@ package aws
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -10,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,12 +30,28 @@ type AwsClient struct {
 
 	client *secretsmanager.Client `yaml:"-" json:"-"`
 
+	// mu guards the listed secrets, as a client is shared by the workers
+	// of a regex sync
+	mu                sync.RWMutex      `yaml:"-" json:"-"`
 	accountSecretArns map[string]string `yaml:"-" json:"-"`
+	// accountSecretOwners is the owner tag of each listed secret
+	accountSecretOwners map[string]string `yaml:"-" json:"-"`
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+// DeepCopyInto copies the receiver, writing into out. in must be non-nil.
+// The fields are copied one by one, as the lock must not be copied.
 func (in *AwsClient) DeepCopyInto(out *AwsClient) {
-	*out = *in
+	out.Name = in.Name
+	out.RoleArn = in.RoleArn
+	out.Region = in.Region
+	out.EncryptionKey = in.EncryptionKey
+	out.ReplicaRegions = in.ReplicaRegions
+	out.Tags = in.Tags
+	out.client = in.client
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	out.accountSecretArns = in.accountSecretArns
+	out.accountSecretOwners = in.accountSecretOwners
 	if in.ReplicaRegions != nil {
 		in, out := &in.ReplicaRegions, &out.ReplicaRegions
 		*out = make([]string, len(*in))
@@ -43,6 +64,13 @@ func (in *AwsClient) DeepCopyInto(out *AwsClient) {
 			(*out)[key] = val
 		}
 	}
+	if in.accountSecretOwners != nil {
+		in, out := &in.accountSecretOwners, &out.accountSecretOwners
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AwsClient.
@@ -55,6 +83,15 @@ func (in *AwsClient) DeepCopy() *AwsClient {
 	return out
 }
 
+// listed returns the arn and owner of the secret, and false if the secret
+// was not listed
+func (g *AwsClient) listed(name string) (string, string, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	arn, ok := g.accountSecretArns[name]
+	return arn, g.accountSecretOwners[name], ok
+}
+
 func (c *AwsClient) Validate() error {
 	l := log.WithFields(log.Fields{
 		"action": "Validate",
@@ -148,13 +185,33 @@ func (g *AwsClient) GetPath() string {
 	return g.Name
 }
 
+// awsRetryableCodes are client fault error codes which are transient
+var awsRetryableCodes = map[string]bool{
+	"ThrottlingException":       true,
+	"Throttling":                true,
+	"TooManyRequestsException":  true,
+	"RequestLimitExceeded":      true,
+	"RequestThrottledException": true,
+}
+
+// IsRetryable returns true if the error is transient. Server faults and
+// throttling are retryable, other client faults such as access denied or
+// invalid parameters are not.
+func (g *AwsClient) IsRetryable(err error) bool {
+	var ae smithy.APIError
+	if errors.As(err, &ae) {
+		return ae.ErrorFault() != smithy.FaultClient || awsRetryableCodes[ae.ErrorCode()]
+	}
+	return true
+}
+
 func (g *AwsClient) GetSecret(ctx context.Context, name string) ([]byte, error) {
 	l := log.WithFields(log.Fields{
 		"action": "GetSecret",
 	})
 	l.Trace("start")
 	defer l.Trace("end")
-	arn := g.accountSecretArns[name]
+	arn, _, _ := g.listed(name)
 	resp, err := g.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
 		SecretId: &arn,
 	})
@@ -165,7 +222,44 @@ func (g *AwsClient) GetSecret(ctx context.Context, name string) ([]byte, error)
 	return []byte(*resp.SecretString), nil
 }
 
-func (c *AwsClient) createSecret(ctx context.Context, name string, secret []byte) error {
+// maxTagKey and maxTagValue are the maximum lengths of secret tag keys and values
+const (
+	maxTagKey   = 128
+	maxTagValue = 256
+)
+
+// tagChar returns true if the rune is allowed in tag keys and values
+func tagChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || strings.ContainsRune("_.:/=+-@", r)
+}
+
+// tagKey maps a metadata key to the charset of tag keys. The aws: prefix
+// is reserved.
+func tagKey(k string) string {
+	k = driver.MapChars(k, tagChar, '_', maxTagKey)
+	if strings.HasPrefix(strings.ToLower(k), "aws:") {
+		k = driver.MapChars("_"+k, tagChar, '_', maxTagKey)
+	}
+	return k
+}
+
+// tags returns the tags of the secret: the configured tags, the propagated
+// metadata, and the owner, if any
+func (c *AwsClient) tags(owner string, md driver.Metadata) map[string]string {
+	tags := make(map[string]string)
+	for k, v := range md.Set {
+		tags[tagKey(k)] = driver.MapChars(v, tagChar, '_', maxTagValue)
+	}
+	for k, v := range c.Tags {
+		tags[k] = v
+	}
+	if owner != "" {
+		tags[driver.OwnerKey] = owner
+	}
+	return tags
+}
+
+func (c *AwsClient) createSecret(ctx context.Context, name string, secret []byte, owner string) error {
 	l := log.WithFields(log.Fields{
 		"action": "createSecret",
 		"name":   name,
@@ -194,16 +288,14 @@ func (c *AwsClient) createSecret(ctx context.Context, name string, secret []byte
 		}
 		csi.AddReplicaRegions = rep
 	}
-	if c.Tags != nil {
-		var tags []types.Tag
-		for k, v := range c.Tags {
-			tags = append(tags, types.Tag{
-				Key:   aws.String(k),
-				Value: aws.String(v),
-			})
-		}
-		csi.Tags = tags
+	var tags []types.Tag
+	for k, v := range c.tags(owner, driver.MetadataFrom(ctx)) {
+		tags = append(tags, types.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
 	}
+	csi.Tags = tags
 	_, err := c.client.CreateSecret(ctx, csi)
 	if err != nil {
 		l.Errorf("error: %v", err)
@@ -220,7 +312,7 @@ func (c *AwsClient) updateSecret(ctx context.Context, name string, secret []byte
 	})
 	l.Trace("start")
 	defer l.Trace("end")
-	arn := c.accountSecretArns[name]
+	arn, _, _ := c.listed(name)
 	usi := &secretsmanager.UpdateSecretInput{
 		SecretId:     &arn,
 		SecretString: aws.String(string(secret)),
@@ -236,6 +328,47 @@ func (c *AwsClient) updateSecret(ctx context.Context, name string, secret []byte
 	return nil
 }
 
+// reconcileTags tags the existing secret with its configured tags, the
+// propagated metadata, and its owner, if any, and removes the tags of
+// metadata which is no longer propagated. The owner is only tagged if it
+// changed, as the secret was adopted or overwritten.
+func (c *AwsClient) reconcileTags(ctx context.Context, name, owner string, md driver.Metadata) error {
+	arn, listedOwner, _ := c.listed(name)
+	if owner == listedOwner {
+		owner = ""
+	}
+	want := c.tags(owner, md)
+	var remove []string
+	for _, k := range md.Remove {
+		if _, ok := want[tagKey(k)]; !ok {
+			remove = append(remove, tagKey(k))
+		}
+	}
+	if len(remove) > 0 {
+		if _, err := c.client.UntagResource(ctx, &secretsmanager.UntagResourceInput{
+			SecretId: &arn,
+			TagKeys:  remove,
+		}); err != nil {
+			return err
+		}
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	var tags []types.Tag
+	for k, v := range want {
+		tags = append(tags, types.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+	_, err := c.client.TagResource(ctx, &secretsmanager.TagResourceInput{
+		SecretId: &arn,
+		Tags:     tags,
+	})
+	return err
+}
+
 func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secrets []byte) ([]byte, error) {
 	l := log.WithFields(log.Fields{
 		"action": "WriteSecret",
@@ -244,23 +377,47 @@ func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 	})
 	l.Trace("start")
 	defer l.Trace("end")
+	owner := driver.Owner(meta)
 	// if there is an existing secret, update it
-	if _, ok := g.accountSecretArns[path]; ok {
+	if _, _, ok := g.listed(path); ok {
 		err := g.updateSecret(ctx, path, secrets)
 		if err != nil {
 			l.Errorf("error: %v", err)
 			return nil, err
 		}
+		// updating the secret does not change its tags, so they are reconciled separately
+		if err := g.reconcileTags(ctx, path, owner, driver.MetadataFrom(ctx)); err != nil {
+			l.Errorf("error: %v", err)
+			return nil, err
+		}
 	} else {
-		err := g.createSecret(ctx, path, secrets)
+		err := g.createSecret(ctx, path, secrets, owner)
 		if err != nil {
 			l.Errorf("error: %v", err)
 			return nil, err
 		}
 	}
+	if owner != "" {
+		g.mu.Lock()
+		if g.accountSecretOwners == nil {
+			g.accountSecretOwners = make(map[string]string)
+		}
+		g.accountSecretOwners[path] = owner
+		g.mu.Unlock()
+	}
 	return nil, nil
 }
 
+// Owners returns the owner tag of the secret if it exists. Secrets are
+// listed with their tags when the client is initialized.
+func (g *AwsClient) Owners(ctx context.Context, owner, path string, payload []byte) (map[string]string, error) {
+	owners := make(map[string]string)
+	if _, o, ok := g.listed(path); ok {
+		owners[path] = o
+	}
+	return owners, nil
+}
+
 func (g *AwsClient) DeleteSecret(ctx context.Context, secret string) error {
 	l := log.WithFields(log.Fields{
 		"action": "DeleteSecret",
@@ -269,7 +426,7 @@ func (g *AwsClient) DeleteSecret(ctx context.Context, secret string) error {
 	})
 	l.Trace("start")
 	defer l.Trace("end")
-	arn := g.accountSecretArns[secret]
+	arn, _, _ := g.listed(secret)
 	_, err := g.client.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
 		SecretId: &arn,
 	})
@@ -289,6 +446,7 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 	var secretsList []string
 	var nextToken *string
 	arnMap := make(map[string]string)
+	ownerMap := make(map[string]string)
 	for {
 		params := &secretsmanager.ListSecretsInput{
 			NextToken: nextToken,
@@ -300,6 +458,11 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 		}
 		for _, secret := range resp.SecretList {
 			arnMap[*secret.Name] = *secret.ARN
+			for _, t := range secret.Tags {
+				if aws.ToString(t.Key) == driver.OwnerKey {
+					ownerMap[*secret.Name] = aws.ToString(t.Value)
+				}
+			}
 			secretsList = append(secretsList, *secret.Name)
 		}
 		if resp.NextToken == nil {
@@ -307,7 +470,10 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 		}
 		nextToken = resp.NextToken
 	}
+	g.mu.Lock()
 	g.accountSecretArns = arnMap
+	g.accountSecretOwners = ownerMap
+	g.mu.Unlock()
 	return secretsList, nil
 }
 
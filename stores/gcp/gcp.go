@@ -6,11 +6,15 @@ import (
 	"fmt"
 	"hash/crc32"
 	"strings"
+	"unicode"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -122,6 +126,23 @@ func (g *GcpClient) GetPath() string {
 	return g.Name
 }
 
+// IsRetryable returns true if the error is transient
+func (g *GcpClient) IsRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument,
+		codes.NotFound,
+		codes.AlreadyExists,
+		codes.PermissionDenied,
+		codes.FailedPrecondition,
+		codes.OutOfRange,
+		codes.Unimplemented,
+		codes.Unauthenticated:
+		return false
+	default:
+		return true
+	}
+}
+
 func (g *GcpClient) GetSecret(ctx context.Context, name string) ([]byte, error) {
 	l := log.WithFields(log.Fields{
 		"action": "GetSecret",
@@ -129,7 +150,7 @@ func (g *GcpClient) GetSecret(ctx context.Context, name string) ([]byte, error)
 	l.Trace("start")
 	defer l.Trace("end")
 	sv, err := g.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
-		Name: g.fullName(name),
+		Name: g.fullName(name) + "/versions/latest",
 	})
 	if err != nil {
 		l.Errorf("error: %v", err)
@@ -165,7 +186,80 @@ func (c *GcpClient) createSecretVersion(ctx context.Context, name string, secret
 	return nil
 }
 
-func (c *GcpClient) createSecretWrapper(ctx context.Context, name string) error {
+// maxLabel is the maximum length of label keys and values, and of annotation keys
+const maxLabel = 63
+
+// labelChar returns true if the rune is allowed in label keys and values
+func labelChar(r rune) bool {
+	return unicode.IsLower(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// labelKey maps a metadata key to the charset of label keys, which start
+// with a lowercase letter
+func labelKey(k string) string {
+	k = driver.MapChars(strings.ToLower(k), labelChar, '_', maxLabel)
+	if k == "" || !unicode.IsLower([]rune(k)[0]) {
+		k = driver.MapChars("m_"+k, labelChar, '_', maxLabel)
+	}
+	return k
+}
+
+// labelValue maps a metadata value to the charset of label values
+func labelValue(v string) string {
+	return driver.MapChars(strings.ToLower(v), labelChar, '_', maxLabel)
+}
+
+// annotationKey maps a metadata key to the charset of annotation keys, which
+// start and end with an alphanumeric character. Annotation values are not
+// restricted, so annotations hold the metadata values as is.
+func annotationKey(k string) string {
+	k = driver.MapChars(k, func(r rune) bool {
+		return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || strings.ContainsRune("._-", r)
+	}, '_', maxLabel)
+	return strings.Trim(k, "._-")
+}
+
+// applyMetadata applies the configured labels, the propagated metadata and
+// the owner, if any, to the labels and annotations of the secret, keeping
+// its other labels and annotations. Returns true if the secret changed.
+func (c *GcpClient) applyMetadata(sec *secretmanagerpb.Secret, owner string, md driver.Metadata) bool {
+	if sec.Labels == nil {
+		sec.Labels = make(map[string]string)
+	}
+	if sec.Annotations == nil {
+		sec.Annotations = make(map[string]string)
+	}
+	changed := false
+	set := func(m map[string]string, k, v string) {
+		if cur, ok := m[k]; k != "" && (!ok || cur != v) {
+			m[k] = v
+			changed = true
+		}
+	}
+	del := func(m map[string]string, k string) {
+		if _, ok := m[k]; ok {
+			delete(m, k)
+			changed = true
+		}
+	}
+	for _, k := range md.Remove {
+		del(sec.Labels, labelKey(k))
+		del(sec.Annotations, annotationKey(k))
+	}
+	for k, v := range md.Set {
+		set(sec.Labels, labelKey(k), labelValue(v))
+		set(sec.Annotations, annotationKey(k), v)
+	}
+	for k, v := range c.Labels {
+		set(sec.Labels, k, v)
+	}
+	if owner != "" {
+		set(sec.Labels, driver.OwnerKey, driver.OwnerLabel(owner))
+	}
+	return changed
+}
+
+func (c *GcpClient) createSecretWrapper(ctx context.Context, name, owner string) error {
 	l := log.WithFields(log.Fields{
 		"action":   "createSecretWrapper",
 		"name":     name,
@@ -179,10 +273,8 @@ func (c *GcpClient) createSecretWrapper(ctx context.Context, name string) error
 	sd.Labels = map[string]string{
 		"managed-by": "vault-secret-sync",
 	}
-	// add any additional user-provided labels
-	for k, v := range c.Labels {
-		sd.Labels[k] = v
-	}
+	// add any additional user-provided labels and the propagated metadata
+	c.applyMetadata(sd, owner, driver.MetadataFrom(ctx))
 	if len(c.ReplicationLocations) == 0 {
 		sd.Replication = &secretmanagerpb.Replication{
 			Replication: &secretmanagerpb.Replication_Automatic_{
@@ -227,7 +319,25 @@ func (c *GcpClient) fullName(name string) string {
 	return fmt.Sprintf("projects/%s/secrets/%s", c.Project, c.cleanName(name))
 }
 
-func (c *GcpClient) createSecret(ctx context.Context, name string, secret []byte) error {
+// reconcileMetadata updates the labels and annotations of the existing
+// secret if the configured labels, the propagated metadata or the owner
+// changed, such as when the secret was adopted or overwritten
+func (c *GcpClient) reconcileMetadata(ctx context.Context, sec *secretmanagerpb.Secret, owner string, md driver.Metadata) error {
+	if !c.applyMetadata(sec, owner, md) {
+		return nil
+	}
+	_, err := c.client.UpdateSecret(ctx, &secretmanagerpb.UpdateSecretRequest{
+		Secret: &secretmanagerpb.Secret{
+			Name:        sec.Name,
+			Labels:      sec.Labels,
+			Annotations: sec.Annotations,
+		},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels", "annotations"}},
+	})
+	return err
+}
+
+func (c *GcpClient) createSecret(ctx context.Context, name string, secret []byte, owner string) error {
 	l := log.WithFields(log.Fields{
 		"action":   "createSecret",
 		"name":     name,
@@ -236,23 +346,24 @@ func (c *GcpClient) createSecret(ctx context.Context, name string, secret []byte
 	})
 	l.Trace("start")
 	defer l.Trace("end")
-	var err error
 	// check if secret exists, if so create a new version
 	// if not create a new secret
-	_, err = c.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
+	sec, err := c.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
 		Name: c.fullName(name),
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			l.WithError(err).Trace("secret not found")
 			// create secret
-			if err := c.createSecretWrapper(ctx, name); err != nil {
+			if err := c.createSecretWrapper(ctx, name, owner); err != nil {
 				return err
 			}
 		} else {
 			l.WithError(err).Trace("error getting secret")
 			return err
 		}
+	} else if err := c.reconcileMetadata(ctx, sec, owner, driver.MetadataFrom(ctx)); err != nil {
+		return err
 	}
 	if err := c.createSecretVersion(ctx, name, secret); err != nil {
 		return err
@@ -302,12 +413,32 @@ func (g *GcpClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 	})
 	l.Trace("start")
 	defer l.Trace("end")
-	if err := g.createSecret(ctx, path, secrets); err != nil {
+	if err := g.createSecret(ctx, path, secrets, driver.Owner(meta)); err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
+// Owners returns the owner label of the secret if it exists
+func (g *GcpClient) Owners(ctx context.Context, owner, path string, payload []byte) (map[string]string, error) {
+	owners := make(map[string]string)
+	sec, err := g.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
+		Name: g.fullName(path),
+	})
+	if status.Code(err) == codes.NotFound {
+		return owners, nil
+	} else if err != nil {
+		return nil, err
+	}
+	// labels only hold an encoding of the owner
+	o := sec.Labels[driver.OwnerKey]
+	if o != "" && o == driver.OwnerLabel(owner) {
+		o = owner
+	}
+	owners[path] = o
+	return owners, nil
+}
+
 func (g *GcpClient) DeleteSecret(ctx context.Context, secret string) error {
 	l := log.WithFields(log.Fields{
 		"action":   "DeleteSecret",
@@ -13,6 +13,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GoKillers/libsodium-go/cryptobox"
@@ -122,7 +123,8 @@ func NewClient(cfg *GitHubClient) (*GitHubClient, error) {
 }
 
 type rateLimitedTransport struct {
-	base    http.RoundTripper
+	base http.RoundTripper
+	// limiter optionally limits the rate of requests, including retries
 	limiter *rate.Limiter
 }
 
@@ -215,8 +217,10 @@ func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, err
 
 	for retryCount < maxRetries {
 		// Rate limiter wait
-		if err = t.limiter.Wait(req.Context()); err != nil {
-			return nil, fmt.Errorf("rate limiter wait: %w", err)
+		if t.limiter != nil {
+			if err = t.limiter.Wait(req.Context()); err != nil {
+				return nil, fmt.Errorf("rate limiter wait: %w", err)
+			}
 		}
 
 		// Clone request
@@ -389,11 +393,12 @@ func (g *GitHubClient) CreateClient(ctx context.Context) error {
 
 	installationTokenSource := githubauth.NewInstallationTokenSource(int64(g.installId()), appTokenSource)
 
-	limiter := rate.NewLimiter(rate.Every(2*time.Second), 1)
-
+	// operations are rate limited by the operator's github driver limit,
+	// and each request of an operation by its api limit, both of which are
+	// shared by all clients
 	rateLimitedTransport := &rateLimitedTransport{
 		base:    http.DefaultTransport,
-		limiter: limiter,
+		limiter: driver.APILimiter(driver.DriverNameGitHub),
 	}
 
 	httpClient := &http.Client{
@@ -446,6 +451,20 @@ func (g *GitHubClient) Init(ctx context.Context) error {
 func (g *GitHubClient) Driver() driver.DriverName {
 	return driver.DriverNameGitHub
 }
+
+// IsRetryable returns true if the error is transient, such as a rate limit
+func (g *GitHubClient) IsRetryable(err error) bool {
+	var rle *github.RateLimitError
+	var are *github.AbuseRateLimitError
+	if errors.As(err, &rle) || errors.As(err, &are) {
+		return true
+	}
+	var ere *github.ErrorResponse
+	if errors.As(err, &ere) && ere.Response != nil {
+		return driver.RetryableStatus(ere.Response.StatusCode)
+	}
+	return true
+}
 func (g *GitHubClient) GetPath() string {
 	if g.Repo != "" {
 		return g.Repo
@@ -471,16 +490,57 @@ func (g *GitHubClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 		return nil, errors.New("nil client")
 	}
 
-	if g.Merge != nil && !*g.Merge {
-		// first, clear out the existing secrets
-		g.DeleteSecret(ctx, "")
-	}
-
 	secrets := make(map[string]interface{})
 	if err := json.Unmarshal(bSecrets, &secrets); err != nil {
 		return nil, err
 	}
 
+	// secrets have no metadata, so their owners are kept in a registry
+	// variable. Writes sharing the registry are serialized, so that the
+	// owners read are current while deleting and writing secrets.
+	owner := driver.Owner(meta)
+	var registry map[string]string
+	// registryChanges are the owners to record, "" removing the secret
+	registryChanges := make(map[string]string)
+	if owner != "" {
+		mu := g.ownersLock()
+		mu.Lock()
+		defer mu.Unlock()
+		var err error
+		registry, _, err = g.readOwners(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.Merge != nil && !*g.Merge {
+		if owner == "" {
+			// first, clear out the existing secrets
+			g.DeleteSecret(ctx, "")
+		} else {
+			// clear out the existing secrets which are not written, except
+			// those owned by another sync
+			names, err := g.ListSecrets(ctx, "")
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range names {
+				if _, ok := secrets[n]; ok {
+					continue
+				}
+				if o := registry[n]; o != "" && o != owner {
+					continue
+				}
+				if err := g.deleteOne(ctx, n); err != nil {
+					return nil, err
+				}
+				if _, ok := registry[n]; ok {
+					registryChanges[n] = ""
+				}
+			}
+		}
+	}
+
 	writeErrs := make(map[string]error)
 	// create secret(s) in repo for each key/value pair
 	for k, v := range secrets {
@@ -526,15 +586,176 @@ func (g *GitHubClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 		})
 		if err != nil {
 			writeErrs[k] = err
+		} else if owner != "" && registry[k] != owner {
+			registryChanges[k] = owner
 		}
 	}
 
+	if len(registryChanges) > 0 {
+		if err := g.updateOwners(ctx, registryChanges); err != nil {
+			writeErrs[ownersVariablePrefix] = err
+		}
+	}
 	if len(writeErrs) > 0 {
 		return nil, fmt.Errorf("error writing secrets: %v", writeErrs)
 	}
 	return nil, nil
 }
 
+// ownersVariablePrefix is the name of the variable holding the owner of each
+// secret written by the operator
+const ownersVariablePrefix = "VAULT_SECRET_SYNC_OWNERS"
+
+// ownersVariable returns the name of the owners registry variable. Dependabot
+// secrets share the repo variables, so use their own registry.
+func (g *GitHubClient) ownersVariable() string {
+	if g.Dependabot {
+		return ownersVariablePrefix + "_DEPENDABOT"
+	}
+	return ownersVariablePrefix
+}
+
+var (
+	// ownersLocks serialize the updates of each owners registry
+	ownersLocks   = make(map[string]*sync.Mutex)
+	ownersLocksMu sync.Mutex
+)
+
+// ownersLock returns the lock of the owners registry of the client, which
+// is shared by every client of the same org, repo or environment
+func (g *GitHubClient) ownersLock() *sync.Mutex {
+	var k string
+	switch {
+	case g.Org:
+		k = "org/" + g.Owner
+	case g.Env != "" && !g.Dependabot:
+		k = "env/" + g.Owner + "/" + g.Repo + "/" + g.Env
+	default:
+		k = "repo/" + g.Owner + "/" + g.Repo
+	}
+	k += "/" + g.ownersVariable()
+	ownersLocksMu.Lock()
+	defer ownersLocksMu.Unlock()
+	mu, ok := ownersLocks[k]
+	if !ok {
+		mu = &sync.Mutex{}
+		ownersLocks[k] = mu
+	}
+	return mu
+}
+
+// readOwners reads the owners registry, returning false if it does not exist
+func (g *GitHubClient) readOwners(ctx context.Context) (map[string]string, bool, error) {
+	owners := make(map[string]string)
+	var v *github.ActionsVariable
+	err := g.withRetry(ctx, "readOwners", func() error {
+		var err error
+		if g.Org {
+			v, _, err = g.client.Actions.GetOrgVariable(ctx, g.Owner, g.ownersVariable())
+		} else if g.Env != "" && !g.Dependabot {
+			v, _, err = g.client.Actions.GetEnvVariable(ctx, g.Owner, g.Repo, g.Env, g.ownersVariable())
+		} else {
+			v, _, err = g.client.Actions.GetRepoVariable(ctx, g.Owner, g.Repo, g.ownersVariable())
+		}
+		return err
+	})
+	var ere *github.ErrorResponse
+	if errors.As(err, &ere) && ere.Response != nil && ere.Response.StatusCode == http.StatusNotFound {
+		return owners, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read owners variable: %w", err)
+	}
+	if err := json.Unmarshal([]byte(v.Value), &owners); err != nil {
+		return nil, true, fmt.Errorf("failed to parse owners variable: %w", err)
+	}
+	return owners, true, nil
+}
+
+// updateOwners records the changed owners in the owners registry, removing
+// the secrets whose owner is "". The registry is read again just before it
+// is written, so that entries written meanwhile by others are kept. The
+// caller must hold the lock of the registry.
+func (g *GitHubClient) updateOwners(ctx context.Context, changes map[string]string) error {
+	registry, exists, err := g.readOwners(ctx)
+	if err != nil {
+		return err
+	}
+	for n, o := range changes {
+		if o == "" {
+			delete(registry, n)
+		} else {
+			registry[n] = o
+		}
+	}
+	return g.writeOwners(ctx, registry, exists)
+}
+
+// writeOwners creates or updates the owners registry
+func (g *GitHubClient) writeOwners(ctx context.Context, owners map[string]string, exists bool) error {
+	jd, err := json.Marshal(owners)
+	if err != nil {
+		return err
+	}
+	v := &github.ActionsVariable{Name: g.ownersVariable(), Value: string(jd)}
+	return g.withRetry(ctx, "writeOwners", func() error {
+		var err error
+		switch {
+		case g.Org:
+			// the registry is not needed by any repo workflows
+			v.Visibility = github.String("selected")
+			if exists {
+				_, err = g.client.Actions.UpdateOrgVariable(ctx, g.Owner, v)
+			} else {
+				_, err = g.client.Actions.CreateOrgVariable(ctx, g.Owner, v)
+			}
+		case g.Env != "" && !g.Dependabot:
+			if exists {
+				_, err = g.client.Actions.UpdateEnvVariable(ctx, g.Owner, g.Repo, g.Env, v)
+			} else {
+				_, err = g.client.Actions.CreateEnvVariable(ctx, g.Owner, g.Repo, g.Env, v)
+			}
+		default:
+			if exists {
+				_, err = g.client.Actions.UpdateRepoVariable(ctx, g.Owner, g.Repo, v)
+			} else {
+				_, err = g.client.Actions.CreateRepoVariable(ctx, g.Owner, g.Repo, v)
+			}
+		}
+		return err
+	})
+}
+
+// Owners returns the registered owner of each existing secret which
+// writing the payload would replace. Without merge, writing also deletes
+// the existing secrets missing from the payload which are not owned by
+// another sync, so their owners are returned too.
+func (g *GitHubClient) Owners(ctx context.Context, owner, path string, payload []byte) (map[string]string, error) {
+	secrets := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &secrets); err != nil {
+		return nil, err
+	}
+	names, err := g.ListSecrets(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	registry, _, err := g.readOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merge := g.Merge == nil || *g.Merge
+	owners := make(map[string]string)
+	for _, n := range names {
+		v, ok := secrets[n]
+		switch {
+		case ok && v != "":
+			owners[n] = registry[n]
+		case !ok && !merge && (registry[n] == "" || registry[n] == owner):
+			owners[n] = registry[n]
+		}
+	}
+	return owners, nil
+}
+
 func (g *GitHubClient) DeleteSecret(ctx context.Context, secret string) error {
 	l := log.WithFields(log.Fields{
 		"action": "DeleteSecret",
@@ -550,36 +771,40 @@ func (g *GitHubClient) DeleteSecret(ctx context.Context, secret string) error {
 	}
 
 	for _, s := range secretList {
-		err = g.withRetry(ctx, fmt.Sprintf("DeleteSecret-%s", s), func() error {
-			var err error
-			if g.Dependabot {
-				_, err = g.client.Dependabot.DeleteRepoSecret(ctx, g.Owner, g.Repo, s)
-			} else if g.Org {
-				_, err = g.client.Actions.DeleteOrgSecret(ctx, g.Owner, s)
-			} else if g.Env != "" {
-				rid, err := g.RepoID(ctx)
-				if err != nil {
-					return err
-				}
-				_, err = g.client.Actions.DeleteEnvSecret(ctx, int(rid), g.Env, s)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("environment %s does not exist", g.Env)
-				}
-			} else {
-				_, err = g.client.Actions.DeleteRepoSecret(ctx, g.Owner, g.Repo, s)
-				if err != nil && strings.Contains(err.Error(), "404 Not Found") {
-					return fmt.Errorf("repo %s does not exist", g.Repo)
-				}
-			}
-			return err
-		})
-		if err != nil {
+		if err := g.deleteOne(ctx, s); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// deleteOne deletes a single secret by name
+func (g *GitHubClient) deleteOne(ctx context.Context, s string) error {
+	return g.withRetry(ctx, fmt.Sprintf("DeleteSecret-%s", s), func() error {
+		var err error
+		if g.Dependabot {
+			_, err = g.client.Dependabot.DeleteRepoSecret(ctx, g.Owner, g.Repo, s)
+		} else if g.Org {
+			_, err = g.client.Actions.DeleteOrgSecret(ctx, g.Owner, s)
+		} else if g.Env != "" {
+			rid, err := g.RepoID(ctx)
+			if err != nil {
+				return err
+			}
+			_, err = g.client.Actions.DeleteEnvSecret(ctx, int(rid), g.Env, s)
+			if err != nil && strings.Contains(err.Error(), "404 Not Found") {
+				return fmt.Errorf("environment %s does not exist", g.Env)
+			}
+		} else {
+			_, err = g.client.Actions.DeleteRepoSecret(ctx, g.Owner, g.Repo, s)
+			if err != nil && strings.Contains(err.Error(), "404 Not Found") {
+				return fmt.Errorf("repo %s does not exist", g.Repo)
+			}
+		}
+		return err
+	})
+}
+
 func (g *GitHubClient) ListSecrets(ctx context.Context, p string) ([]string, error) {
 	l := log.WithFields(log.Fields{
 		"action": "ListSecrets",
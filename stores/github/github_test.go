@@ -2,16 +2,24 @@ package github
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/go-github/v62/github"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // mockTransport implements http.RoundTripper for testing
@@ -294,3 +302,108 @@ func TestRateLimitedTransport_RoundTrip(t *testing.T) {
 		})
 	}
 }
+
+// testRepoClient returns a repo client sending requests to the handler
+func testRepoClient(t *testing.T, h http.HandlerFunc) *GitHubClient {
+	t.Helper()
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	g := &GitHubClient{Owner: "o", Repo: "r", client: github.NewClient(nil)}
+	g.client.BaseURL, _ = url.Parse(server.URL + "/")
+	return g
+}
+
+func TestOwners(t *testing.T) {
+	registry := `{"A":"ns/sync","B":"ns/other","D":"ns/sync"}`
+	g := testRepoClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/actions/secrets":
+			w.Write([]byte(`{"total_count":4,"secrets":[{"name":"A"},{"name":"B"},{"name":"C"},{"name":"D"}]}`))
+		case "/repos/o/r/actions/variables/" + ownersVariablePrefix:
+			b, _ := json.Marshal(map[string]string{"name": ownersVariablePrefix, "value": registry})
+			w.Write(b)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	payload := []byte(`{"A":"1","E":"2"}`)
+
+	owners, err := g.Owners(context.Background(), "ns/sync", "r", payload)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "ns/sync"}, owners)
+
+	// without merge, the secrets which would be deleted are returned, except
+	// those owned by another sync which are not deleted
+	g.Merge = github.Bool(false)
+	owners, err = g.Owners(context.Background(), "ns/sync", "r", payload)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"A": "ns/sync", "C": "", "D": "ns/sync"}, owners)
+}
+
+// fakeRepo is an in-memory repo serving the secrets and variables API
+type fakeRepo struct {
+	mu        sync.Mutex
+	secrets   map[string]bool
+	variables map[string]string
+}
+
+func (f *fakeRepo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const secrets, variables = "/repos/o/r/actions/secrets", "/repos/o/r/actions/variables"
+	switch p := r.URL.Path; {
+	case p == secrets+"/public-key":
+		w.Write([]byte(`{"key_id":"1","key":"` + base64.StdEncoding.EncodeToString(make([]byte, 32)) + `"}`))
+	case p == secrets:
+		var names []map[string]string
+		for n := range f.secrets {
+			names = append(names, map[string]string{"name": n})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"total_count": len(names), "secrets": names})
+	case strings.HasPrefix(p, secrets+"/") && r.Method == http.MethodPut:
+		f.secrets[strings.TrimPrefix(p, secrets+"/")] = true
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(p, secrets+"/") && r.Method == http.MethodDelete:
+		delete(f.secrets, strings.TrimPrefix(p, secrets+"/"))
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(p, variables) && r.Method == http.MethodGet:
+		v, ok := f.variables[strings.TrimPrefix(p, variables+"/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"value": v})
+	case strings.HasPrefix(p, variables):
+		var v github.ActionsVariable
+		json.NewDecoder(r.Body).Decode(&v)
+		f.variables[v.Name] = v.Value
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestWriteSecretOwnersConcurrent(t *testing.T) {
+	repo := &fakeRepo{secrets: map[string]bool{}, variables: map[string]string{}}
+	server := httptest.NewServer(repo)
+	defer server.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g := &GitHubClient{Owner: "o", Repo: "r", client: github.NewClient(nil)}
+			g.client.BaseURL, _ = url.Parse(server.URL + "/")
+			meta := metav1.ObjectMeta{Namespace: "ns", Name: fmt.Sprintf("sync%d", i)}
+			_, err := g.WriteSecret(context.Background(), meta, "r", []byte(fmt.Sprintf(`{"KEY%d":"v"}`, i)))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	// every sync's owner is kept in the registry
+	owners := make(map[string]string)
+	require.NoError(t, json.Unmarshal([]byte(repo.variables[ownersVariablePrefix]), &owners))
+	assert.Len(t, owners, 5)
+	assert.Equal(t, "ns/sync3", owners["KEY3"])
+}
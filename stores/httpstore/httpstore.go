@@ -6,12 +6,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"html/template"
 	"io"
 	"net/http"
 	"net/http/httputil"
 	"slices"
+	"strings"
+	"unicode"
 
 	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	"github.com/robertlestak/vault-secret-sync/pkg/kubesecret"
@@ -19,6 +20,16 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// MetadataHeaderPrefix prefixes the headers holding the propagated metadata
+const MetadataHeaderPrefix = "X-Vault-Metadata-"
+
+// metadataHeader maps a metadata key to the charset of header names
+func metadataHeader(k string) string {
+	return MetadataHeaderPrefix + driver.MapChars(k, func(r rune) bool {
+		return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '-'
+	}, '-', 0)
+}
+
 type HTTPClient struct {
 	URL          string            `yaml:"url,omitempty" json:"url,omitempty"`
 	Headers      map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
@@ -120,6 +131,15 @@ func (h *HTTPClient) GetPath() string {
 	return h.URL
 }
 
+// IsRetryable returns true if the error is transient
+func (h *HTTPClient) IsRetryable(err error) bool {
+	var se *driver.StatusError
+	if errors.As(err, &se) {
+		return driver.RetryableStatus(se.StatusCode)
+	}
+	return true
+}
+
 // ApplyTemplate applies the configured template to the secret data
 func (h *HTTPClient) ApplyTemplate(secrets []byte) (string, error) {
 	if h.Template == "" {
@@ -173,7 +193,7 @@ func (h *HTTPClient) GetSecret(ctx context.Context, path string) ([]byte, error)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get secret: %s", resp.Status)
+		return nil, &driver.StatusError{Op: "get secret", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -215,6 +235,10 @@ func (h *HTTPClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pa
 			h.Headers[key] = string(value)
 		}
 	}
+	for key, value := range driver.MetadataFrom(ctx).Set {
+		// header values can't hold line breaks
+		req.Header.Set(metadataHeader(key), strings.NewReplacer("\r", " ", "\n", " ").Replace(value))
+	}
 	for key, value := range h.Headers {
 		req.Header.Set(key, value)
 	}
@@ -233,13 +257,13 @@ func (h *HTTPClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pa
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to write secret: %s", resp.Status)
+		return nil, &driver.StatusError{Op: "write secret", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 	if len(h.SuccessCodes) == 0 {
 		h.SuccessCodes = []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent}
 	}
 	if !slices.Contains(h.SuccessCodes, resp.StatusCode) {
-		return nil, fmt.Errorf("failed to write secret: %s", resp.Status)
+		return nil, &driver.StatusError{Op: "write secret", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 	return secrets, nil
 }
@@ -263,7 +287,7 @@ func (h *HTTPClient) DeleteSecret(ctx context.Context, path string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to delete secret: %s", resp.Status)
+		return &driver.StatusError{Op: "delete secret", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	return nil
@@ -288,7 +312,7 @@ func (h *HTTPClient) ListSecrets(ctx context.Context, path string) ([]string, er
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to list secrets: %s", resp.Status)
+		return nil, &driver.StatusError{Op: "list secrets", StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	body, err := io.ReadAll(resp.Body)
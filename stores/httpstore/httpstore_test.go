@@ -1,8 +1,14 @@
 package httpstore
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestApplyTemplate(t *testing.T) {
@@ -68,3 +74,25 @@ func TestApplyTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteSecretMetadataHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	client := &HTTPClient{client: server.Client()}
+	ctx := driver.WithMetadata(context.Background(), driver.Metadata{
+		Set: map[string]string{"cost_center": "eng\n42", "owner": "team-a"},
+	})
+	if _, err := client.WriteSecret(ctx, metav1.ObjectMeta{}, server.URL, []byte(`{"a":"b"}`)); err != nil {
+		t.Fatalf("WriteSecret() error = %v", err)
+	}
+	if v := got.Get("X-Vault-Metadata-Cost-Center"); v != "eng 42" {
+		t.Errorf("cost center header = %q, want %q", v, "eng 42")
+	}
+	if v := got.Get("X-Vault-Metadata-Owner"); v != "team-a" {
+		t.Errorf("owner header = %q, want %q", v, "team-a")
+	}
+}
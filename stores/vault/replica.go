@@ -0,0 +1,302 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// kvVersion is the state of a version in the kv metadata of a secret
+type kvVersion struct {
+	deleted   bool
+	destroyed bool
+}
+
+// kvPath returns the path of the secret under the kv v2 endpoint, such as
+// data, metadata or destroy
+func kvPath(s, endpoint string) (string, error) {
+	ss := strings.Split(s, "/")
+	if len(ss) < 2 {
+		return "", errors.New("secret path must be in kv/path/to/secret format")
+	}
+	return strings.Join(insertSliceString(ss, 1, endpoint), "/"), nil
+}
+
+// kvInt returns the integer value of a kv metadata field
+func kvInt(v interface{}) int {
+	switch n := v.(type) {
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+// kvVersions returns the state of each version in the kv metadata of a
+// secret. Versions with a deletion time in the future are not yet deleted.
+func kvVersions(md map[string]interface{}) map[int]kvVersion {
+	versions := make(map[int]kvVersion)
+	vm, _ := md["versions"].(map[string]interface{})
+	for k, v := range vm {
+		n, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		m, _ := v.(map[string]interface{})
+		var kv kvVersion
+		kv.destroyed, _ = m["destroyed"].(bool)
+		if dt, _ := m["deletion_time"].(string); dt != "" {
+			t, err := time.Parse(time.RFC3339Nano, dt)
+			kv.deleted = err != nil || !t.After(time.Now())
+		}
+		versions[n] = kv
+	}
+	return versions
+}
+
+// sortedVersions returns the version numbers in order
+func sortedVersions(versions map[int]kvVersion) []int {
+	var nums []int
+	for n := range versions {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// ReadReplica reads the kv metadata configuration, custom metadata and
+// versions of the secret, logging in again and retrying on permission denied.
+// A pinned version is ignored, as the replica mirrors the source.
+func (vc *VaultClient) ReadReplica(ctx context.Context, s string, history bool) (*driver.Replica, error) {
+	var r *driver.Replica
+	err := vc.withToken(ctx, func() error {
+		var err error
+		r, err = vc.readReplicaOnce(ctx, s, history)
+		return err
+	})
+	return r, err
+}
+
+func (vc *VaultClient) readReplicaOnce(ctx context.Context, s string, history bool) (*driver.Replica, error) {
+	md, _, err := vc.readMetadata(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil || md.Data == nil {
+		return nil, errors.New("secret not found: " + s)
+	}
+	r := &driver.Replica{
+		MaxVersions:    kvInt(md.Data["max_versions"]),
+		CustomMetadata: customMetadata(md.Data["custom_metadata"]),
+		CurrentVersion: kvInt(md.Data["current_version"]),
+		History:        history,
+	}
+	r.CASRequired, _ = md.Data["cas_required"].(bool)
+	r.DeleteVersionAfter, _ = md.Data["delete_version_after"].(string)
+	versions := kvVersions(md.Data)
+	nums := sortedVersions(versions)
+	if !history {
+		nums = nil
+		if _, ok := versions[r.CurrentVersion]; ok {
+			nums = []int{r.CurrentVersion}
+		}
+	}
+	for _, n := range nums {
+		v := driver.SecretVersion{Version: n, Deleted: versions[n].deleted, Destroyed: versions[n].destroyed}
+		if !v.Deleted && !v.Destroyed {
+			if v.Data, err = vc.readVersion(ctx, s, n); err != nil {
+				return nil, err
+			}
+			// the version was deleted since the metadata was read
+			v.Deleted = v.Data == nil
+		}
+		r.Versions = append(r.Versions, v)
+	}
+	return r, nil
+}
+
+// readVersion reads the data of a version of the secret, nil if it has none
+func (vc *VaultClient) readVersion(ctx context.Context, s string, version int) (map[string]interface{}, error) {
+	p, err := kvPath(s, "data")
+	if err != nil {
+		return nil, err
+	}
+	secret, err := vc.Client.Logical().ReadWithDataWithContext(ctx, p, map[string][]string{"version": {strconv.Itoa(version)}})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+	data, _ := secret.Data["data"].(map[string]interface{})
+	return data, nil
+}
+
+// WriteReplica writes the replica to the secret, logging in again and
+// retrying on permission denied. Replicas are written with check-and-set,
+// so a write which is retried does not duplicate versions.
+func (vc *VaultClient) WriteReplica(ctx context.Context, meta metav1.ObjectMeta, s string, r *driver.Replica) error {
+	return vc.withToken(ctx, func() error {
+		return vc.writeReplicaOnce(ctx, driver.Owner(meta), s, r)
+	})
+}
+
+// writeReplicaOnce writes the metadata configuration and custom metadata of
+// the replica, stamped with the owner, if any, and its versions.
+//
+// With history, the versions the secret does not have yet are written in
+// order, so that version numbers match the source, and the deleted and
+// destroyed state of each version is replicated. Versions no longer kept by
+// the source, and versions deleted before they were replicated, cannot be
+// read and are written as empty placeholders. Without history, the current
+// version is written as a new version of the secret.
+func (vc *VaultClient) writeReplicaOnce(ctx context.Context, owner, s string, r *driver.Replica) error {
+	l := log.WithFields(log.Fields{
+		"action":  "writeReplica",
+		"address": vc.Address,
+		"path":    s,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+	md, mp, err := vc.readMetadata(ctx, s)
+	if err != nil {
+		return err
+	}
+	cur, versions := 0, make(map[int]kvVersion)
+	if md != nil && md.Data != nil {
+		cur, versions = kvInt(md.Data["current_version"]), kvVersions(md.Data)
+	}
+	if r.History && cur > r.CurrentVersion {
+		return fmt.Errorf("secret is at version %d, ahead of source version %d, so versions cannot be replicated", cur, r.CurrentVersion)
+	}
+
+	cm := make(map[string]interface{}, len(r.CustomMetadata)+1)
+	for k, v := range r.CustomMetadata {
+		cm[k] = v
+	}
+	if owner != "" {
+		cm[driver.OwnerKey] = owner
+	}
+	// the configuration is written first, so that versions beyond
+	// max_versions are pruned as the versions are written
+	if _, err := vc.Client.Logical().WriteWithContext(ctx, mp, map[string]interface{}{
+		"max_versions":         r.MaxVersions,
+		"cas_required":         r.CASRequired,
+		"delete_version_after": r.DeleteVersionAfter,
+		"custom_metadata":      cm,
+	}); err != nil {
+		return fmt.Errorf("failed to write secret metadata: %w", err)
+	}
+
+	source := make(map[int]driver.SecretVersion, len(r.Versions))
+	for _, v := range r.Versions {
+		source[v.Version] = v
+	}
+	var deletes, undeletes, destroys []int
+	if r.History {
+		for n := cur + 1; n <= r.CurrentVersion; n++ {
+			v, ok := source[n]
+			data := v.Data
+			if data == nil {
+				data = make(map[string]interface{})
+			}
+			if _, err := vc.WriteSecretOnce(ctx, s, data, &cur); err != nil {
+				return fmt.Errorf("failed to write version %d: %w", n, err)
+			}
+			cur = n
+			if !ok {
+				destroys = append(destroys, n)
+			}
+			versions[n] = kvVersion{}
+		}
+		for _, n := range sortedVersions(versions) {
+			v, ok := source[n]
+			if !ok || versions[n].destroyed {
+				continue
+			}
+			switch {
+			case v.Destroyed:
+				destroys = append(destroys, n)
+			case v.Deleted && !versions[n].deleted:
+				deletes = append(deletes, n)
+			case !v.Deleted && versions[n].deleted:
+				undeletes = append(undeletes, n)
+			}
+		}
+	} else if len(r.Versions) > 0 {
+		v := r.Versions[len(r.Versions)-1]
+		switch {
+		case v.Data != nil:
+			if _, err := vc.WriteSecretOnce(ctx, s, v.Data, &cur); err != nil {
+				return fmt.Errorf("failed to write secret: %w", err)
+			}
+		case cur == 0 || versions[cur].destroyed:
+			// there is no version left to delete or destroy
+		case v.Destroyed:
+			destroys = append(destroys, cur)
+		case !versions[cur].deleted:
+			deletes = append(deletes, cur)
+		}
+	}
+	for _, state := range []struct {
+		endpoint string
+		versions []int
+	}{{"delete", deletes}, {"undelete", undeletes}, {"destroy", destroys}} {
+		if err := vc.setVersionState(ctx, s, state.endpoint, state.versions); err != nil {
+			return err
+		}
+	}
+	l.WithFields(log.Fields{
+		"version":   cur,
+		"deleted":   deletes,
+		"undeleted": undeletes,
+		"destroyed": destroys,
+	}).Debug("replicated secret")
+	return nil
+}
+
+// setVersionState deletes, undeletes or destroys the versions of the secret
+func (vc *VaultClient) setVersionState(ctx context.Context, s, endpoint string, versions []int) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	p, err := kvPath(s, endpoint)
+	if err != nil {
+		return err
+	}
+	if _, err := vc.Client.Logical().WriteWithContext(ctx, p, map[string]interface{}{"versions": versions}); err != nil {
+		return fmt.Errorf("failed to %s versions %v: %w", endpoint, versions, err)
+	}
+	return nil
+}
+
+// CurrentVersion returns the current version of the kv secret and when it
+// was last updated, or 0 if it does not exist
+func (vc *VaultClient) CurrentVersion(ctx context.Context, s string) (int, time.Time, error) {
+	var md *api.Secret
+	err := vc.withToken(ctx, func() error {
+		var err error
+		md, _, err = vc.readMetadata(ctx, s)
+		return err
+	})
+	if err != nil || md == nil || md.Data == nil {
+		return 0, time.Time{}, err
+	}
+	updated, _ := md.Data["updated_time"].(string)
+	t, _ := time.Parse(time.RFC3339Nano, updated)
+	return kvInt(md.Data["current_version"]), t, nil
+}
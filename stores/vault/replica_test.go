@@ -0,0 +1,190 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKVVersion struct {
+	data      map[string]interface{}
+	deleted   bool
+	destroyed bool
+}
+
+// fakeKV is an in-memory kv v2 engine holding the secret kv/app
+type fakeKV struct {
+	mu       sync.Mutex
+	config   map[string]interface{}
+	versions []*fakeKVVersion
+}
+
+func (f *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	var body map[string]interface{}
+	if r.Method != http.MethodGet {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+	}
+	respond := func(data map[string]interface{}) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}
+	endpoint := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/kv/"), "/")[0]
+	switch {
+	case endpoint == "metadata" && r.Method == http.MethodGet:
+		if f.config == nil && len(f.versions) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		versions := make(map[string]interface{})
+		for i, v := range f.versions {
+			dt := ""
+			if v.deleted {
+				dt = "2024-01-01T00:00:00Z"
+			}
+			versions[strconv.Itoa(i+1)] = map[string]interface{}{"deletion_time": dt, "destroyed": v.destroyed}
+		}
+		md := map[string]interface{}{"current_version": len(f.versions), "versions": versions}
+		for k, v := range f.config {
+			md[k] = v
+		}
+		respond(md)
+	case endpoint == "metadata":
+		f.config = body
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "data" && r.Method == http.MethodGet:
+		n, _ := strconv.Atoi(r.URL.Query().Get("version"))
+		if n < 1 || n > len(f.versions) || f.versions[n-1].data == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		respond(map[string]interface{}{"data": f.versions[n-1].data})
+	case endpoint == "data":
+		opts, _ := body["options"].(map[string]interface{})
+		if cas, ok := opts["cas"].(float64); !ok || int(cas) != len(f.versions) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["check-and-set parameter did not match the current version"]}`))
+			return
+		}
+		f.versions = append(f.versions, &fakeKVVersion{data: body["data"].(map[string]interface{})})
+		respond(nil)
+	default:
+		for _, n := range body["versions"].([]interface{}) {
+			v := f.versions[int(n.(float64))-1]
+			switch endpoint {
+			case "delete":
+				v.deleted = true
+			case "undelete":
+				v.deleted = false
+			case "destroy":
+				v.destroyed, v.data = true, nil
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func fakeKVClient(t *testing.T, f *fakeKV) *VaultClient {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	return &VaultClient{Client: client}
+}
+
+func TestReplicateVersions(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeKV{
+		config: map[string]interface{}{
+			"max_versions":         10,
+			"cas_required":         true,
+			"delete_version_after": "0s",
+			"custom_metadata":      map[string]interface{}{"team": "payments"},
+		},
+		versions: []*fakeKVVersion{
+			{data: map[string]interface{}{"a": "1"}},
+			{data: map[string]interface{}{"a": "2"}, deleted: true},
+			{destroyed: true},
+			{data: map[string]interface{}{"a": "4"}},
+		},
+	}
+	dest := &fakeKV{}
+	src, dst := fakeKVClient(t, source), fakeKVClient(t, dest)
+	replicate := func() error {
+		r, err := src.readReplicaOnce(ctx, "kv/app", true)
+		require.NoError(t, err)
+		return dst.writeReplicaOnce(ctx, "ns/app", "kv/app", r)
+	}
+
+	require.NoError(t, replicate())
+	assert.Equal(t, true, dest.config["cas_required"])
+	assert.Equal(t, float64(10), dest.config["max_versions"])
+	assert.Equal(t, map[string]interface{}{"team": "payments", driver.OwnerKey: "ns/app"}, dest.config["custom_metadata"])
+	require.Len(t, dest.versions, 4)
+	assert.Equal(t, map[string]interface{}{"a": "1"}, dest.versions[0].data)
+	// the data of deleted versions cannot be read, so they are placeholders
+	assert.Equal(t, &fakeKVVersion{data: map[string]interface{}{}, deleted: true}, dest.versions[1])
+	assert.True(t, dest.versions[2].destroyed)
+	assert.Equal(t, map[string]interface{}{"a": "4"}, dest.versions[3].data)
+
+	// new versions and state changes are replicated, without rewriting versions
+	source.versions[0].deleted = true
+	source.versions = append(source.versions, &fakeKVVersion{data: map[string]interface{}{"a": "5"}})
+	require.NoError(t, replicate())
+	require.Len(t, dest.versions, 5)
+	assert.True(t, dest.versions[0].deleted)
+	assert.Equal(t, map[string]interface{}{"a": "5"}, dest.versions[4].data)
+
+	// a destination ahead of the source cannot be replicated to
+	dest.versions = append(dest.versions, &fakeKVVersion{data: map[string]interface{}{}})
+	assert.ErrorContains(t, replicate(), "ahead of source version 5")
+}
+
+func TestReplicateCurrentVersion(t *testing.T) {
+	ctx := context.Background()
+	source := &fakeKV{
+		config: map[string]interface{}{"max_versions": 3},
+		versions: []*fakeKVVersion{
+			{data: map[string]interface{}{"a": "1"}},
+			{data: map[string]interface{}{"a": "2"}},
+		},
+	}
+	dest := &fakeKV{}
+	src, dst := fakeKVClient(t, source), fakeKVClient(t, dest)
+
+	r, err := src.readReplicaOnce(ctx, "kv/app", false)
+	require.NoError(t, err)
+	require.Len(t, r.Versions, 1)
+	require.NoError(t, dst.writeReplicaOnce(ctx, "", "kv/app", r))
+	require.Len(t, dest.versions, 1)
+	assert.Equal(t, map[string]interface{}{"a": "2"}, dest.versions[0].data)
+	assert.Equal(t, float64(3), dest.config["max_versions"])
+
+	// deleting the current source version deletes the current destination version
+	source.versions[1].deleted = true
+	r, err = src.readReplicaOnce(ctx, "kv/app", false)
+	require.NoError(t, err)
+	require.NoError(t, dst.writeReplicaOnce(ctx, "", "kv/app", r))
+	require.Len(t, dest.versions, 1)
+	assert.True(t, dest.versions[0].deleted)
+}
+
+func TestValidateReplicate(t *testing.T) {
+	vc := &VaultClient{Address: "https://vault:8200", ReplicateVersions: true}
+	assert.ErrorContains(t, vc.Validate(), "replicateVersions requires replicate")
+	vc.Replicate = true
+	assert.NoError(t, vc.Validate())
+	vc.Merge = true
+	assert.ErrorContains(t, vc.Validate(), "merge cannot be set with replicate")
+}
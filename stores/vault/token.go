@@ -0,0 +1,194 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenExpiryBuffer is subtracted from a token's lease so that a token is
+// never handed out moments before vault expires it
+const tokenExpiryBuffer = 10 * time.Second
+
+// tokenKey identifies a set of credentials which can share a single token
+type tokenKey struct {
+	address    string
+	namespace  string
+	authType   string
+	authMethod string
+	role       string
+	clientCert string
+}
+
+// managedToken is a cached vault token and the watcher renewing it
+type managedToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	stop    chan struct{}
+}
+
+// tokenManager caches vault tokens across all clients in the process so that
+// syncs sharing the same credentials only login once, renewing tokens with the
+// vault lifetime watcher until they can no longer be renewed
+type tokenManager struct {
+	mu     sync.Mutex
+	tokens map[tokenKey]*managedToken
+}
+
+var tokens = &tokenManager{
+	tokens: make(map[tokenKey]*managedToken),
+}
+
+func (vc *VaultClient) tokenKey() tokenKey {
+	return tokenKey{
+		address:    vc.Address,
+		namespace:  vc.Namespace,
+		authType:   vc.AuthType,
+		authMethod: vc.AuthMethod,
+		role:       vc.Role,
+		clientCert: vc.ClientCert,
+	}
+}
+
+func (m *tokenManager) entry(k tokenKey) *managedToken {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[k]
+	if !ok {
+		t = &managedToken{}
+		m.tokens[k] = t
+	}
+	return t
+}
+
+// valid returns true if the cached token can still be used. t.mu must be held.
+func (t *managedToken) valid() bool {
+	if t.token == "" {
+		return false
+	}
+	return t.expires.IsZero() || time.Now().Before(t.expires.Add(-tokenExpiryBuffer))
+}
+
+// reset clears the cached token and stops any renewal. t.mu must be held.
+func (t *managedToken) reset() {
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	t.token = ""
+	t.expires = time.Time{}
+}
+
+// token returns a cached token for the client, logging in if there is
+// no valid token for the client's credentials
+func (m *tokenManager) token(ctx context.Context, vc *VaultClient) (string, error) {
+	t := m.entry(vc.tokenKey())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.valid() {
+		return t.token, nil
+	}
+	t.reset()
+	secret, err := vc.login(ctx)
+	if err != nil {
+		return "", err
+	}
+	t.token = vc.Client.Token()
+	if secret == nil || secret.Auth == nil {
+		// static tokens such as VAULT_TOKEN are used until vault rejects them
+		return t.token, nil
+	}
+	if secret.Auth.LeaseDuration > 0 {
+		t.expires = time.Now().Add(time.Duration(secret.Auth.LeaseDuration) * time.Second)
+	}
+	if secret.Auth.Renewable {
+		if err := m.watch(vc, t, secret); err != nil {
+			log.WithError(err).WithField("address", vc.Address).Warn("unable to start vault token renewal")
+		}
+	}
+	return t.token, nil
+}
+
+// watch renews the token in the background, updating the cached expiry on
+// each renewal. t.mu must be held.
+func (m *tokenManager) watch(vc *VaultClient, t *managedToken, secret *api.Secret) error {
+	client, err := vc.Client.CloneWithHeaders()
+	if err != nil {
+		return err
+	}
+	client.SetToken(t.token)
+	w, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+		Secret: secret,
+	})
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	t.stop = stop
+	token := t.token
+	l := log.WithFields(log.Fields{
+		"action":  "tokenWatcher",
+		"address": vc.Address,
+		"role":    vc.Role,
+	})
+	go w.Start()
+	go func() {
+		defer w.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case err := <-w.DoneCh():
+				if err != nil {
+					l.WithError(err).Debug("token renewal stopped")
+				}
+				t.mu.Lock()
+				// the lease can no longer be extended, login again on next use
+				if t.token == token {
+					t.stop = nil
+					t.token = ""
+					t.expires = time.Time{}
+				}
+				t.mu.Unlock()
+				return
+			case r := <-w.RenewCh():
+				l.Trace("token renewed")
+				if r == nil || r.Secret == nil || r.Secret.Auth == nil {
+					continue
+				}
+				t.mu.Lock()
+				if t.token == token && r.Secret.Auth.LeaseDuration > 0 {
+					t.expires = time.Now().Add(time.Duration(r.Secret.Auth.LeaseDuration) * time.Second)
+				}
+				t.mu.Unlock()
+			}
+		}
+	}()
+	return nil
+}
+
+// invalidate drops the cached token for the client if it is the token
+// which was rejected, forcing a new login on next use
+func (m *tokenManager) invalidate(vc *VaultClient, rejected string) {
+	t := m.entry(vc.tokenKey())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == rejected {
+		t.reset()
+	}
+}
+
+// isPermissionDenied returns true if vault rejected the request's token
+func isPermissionDenied(err error) bool {
+	var re *api.ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode == http.StatusForbidden
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenTestServer(t *testing.T, denyReads *atomic.Int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	logins := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			logins.Add(1)
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"kube-token","lease_duration":3600,"renewable":false}}`))
+		case "/v1/kv/data/app":
+			if denyReads.Load() > 0 {
+				denyReads.Add(-1)
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"data":{"foo":"bar"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, logins
+}
+
+func withKubeToken(t *testing.T) {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(p, []byte("jwt"), 0600))
+	orig := kubeTokenPath
+	kubeTokenPath = p
+	t.Cleanup(func() { kubeTokenPath = orig })
+}
+
+func TestTokenManagerSharesLoginAcrossClients(t *testing.T) {
+	withKubeToken(t)
+	server, logins := newTokenTestServer(t, &atomic.Int32{})
+
+	for i := 0; i < 3; i++ {
+		vc := &VaultClient{Address: server.URL, AuthMethod: "kubernetes", Role: "sync"}
+		for j := 0; j < 5; j++ {
+			_, err := vc.GetSecret(context.Background(), "kv/app")
+			require.NoError(t, err)
+		}
+	}
+	assert.Equal(t, int32(1), logins.Load())
+}
+
+func TestTokenManagerReauthenticatesOnPermissionDenied(t *testing.T) {
+	withKubeToken(t)
+	deny := &atomic.Int32{}
+	server, logins := newTokenTestServer(t, deny)
+
+	vc := &VaultClient{Address: server.URL, AuthMethod: "kubernetes", Role: "sync"}
+	_, err := vc.GetSecret(context.Background(), "kv/app")
+	require.NoError(t, err)
+
+	deny.Store(1)
+	b, err := vc.GetSecret(context.Background(), "kv/app")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"foo":"bar"}`, string(b))
+	assert.Equal(t, int32(2), logins.Load())
+}
+
+func TestTokenManagerSeparatesRoles(t *testing.T) {
+	withKubeToken(t)
+	server, logins := newTokenTestServer(t, &atomic.Int32{})
+
+	for _, role := range []string{"a", "b"} {
+		vc := &VaultClient{Address: server.URL, AuthMethod: "kubernetes", Role: role}
+		_, err := vc.GetSecret(context.Background(), "kv/app")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), logins.Load())
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,9 +27,33 @@ type VaultClient struct {
 	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
 	TTL        string `yaml:"ttl,omitempty" json:"ttl,omitempty"`
 	Merge      bool   `yaml:"merge,omitempty" json:"merge,omitempty"`
+	// Version pins the KV v2 version read from a source, rather than the
+	// latest version. It does not apply to destinations.
+	Version int `yaml:"version,omitempty" json:"version,omitempty"`
+	// Replicate replicates the KV v2 metadata configuration and custom
+	// metadata of a vault source along with its data, for a full copy of the
+	// secret rather than its latest data. It only applies to destinations.
+	Replicate bool `yaml:"replicate,omitempty" json:"replicate,omitempty"`
+	// ReplicateVersions also replicates the version history of the source,
+	// and the deleted and destroyed state of each version, when replicating
+	ReplicateVersions bool `yaml:"replicateVersions,omitempty" json:"replicateVersions,omitempty"`
 
 	Role string `yaml:"role,omitempty" json:"role,omitempty"`
 
+	// AuthType selects the login flow used against AuthMethod, one of
+	// "kubernetes" (default) or "cert". When AuthType is "cert" and
+	// AuthMethod is empty, the "cert" mount is used.
+	AuthType string `yaml:"authType,omitempty" json:"authType,omitempty"`
+
+	CACert string `yaml:"caCert,omitempty" json:"caCert,omitempty"`
+	CAPath string `yaml:"caPath,omitempty" json:"caPath,omitempty"`
+	// ClientCert and ClientKey are presented for mTLS and are also used as
+	// the identity for the cert auth method.
+	ClientCert    string `yaml:"clientCert,omitempty" json:"clientCert,omitempty"`
+	ClientKey     string `yaml:"clientKey,omitempty" json:"clientKey,omitempty"`
+	TLSServerName string `yaml:"tlsServerName,omitempty" json:"tlsServerName,omitempty"`
+	TLSSkipVerify bool   `yaml:"tlsSkipVerify,omitempty" json:"tlsSkipVerify,omitempty"`
+
 	Client *api.Client `yaml:"-" json:"-"`
 }
 
@@ -46,6 +72,21 @@ func (in *VaultClient) DeepCopy() *VaultClient {
 	return out
 }
 
+const (
+	AuthTypeKubernetes = "kubernetes"
+	AuthTypeCert       = "cert"
+)
+
+// maxMetadataKey and maxMetadataValue are the maximum lengths of the keys
+// and values of kv custom metadata
+const (
+	maxMetadataKey   = 128
+	maxMetadataValue = 512
+)
+
+// kubeTokenPath is the projected service account token used for kubernetes auth
+var kubeTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
 func (c *VaultClient) Validate() error {
 	l := log.WithFields(log.Fields{
 		"action": "Validate",
@@ -54,6 +95,24 @@ func (c *VaultClient) Validate() error {
 	if c.Address == "" {
 		return errors.New("address required")
 	}
+	switch c.AuthType {
+	case "", AuthTypeKubernetes:
+	case AuthTypeCert:
+		if c.ClientCert == "" || c.ClientKey == "" {
+			return errors.New("clientCert and clientKey required for cert auth")
+		}
+	default:
+		return fmt.Errorf("unsupported authType: %s", c.AuthType)
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return errors.New("clientCert and clientKey must be set together")
+	}
+	if c.ReplicateVersions && !c.Replicate {
+		return errors.New("replicateVersions requires replicate")
+	}
+	if c.Replicate && c.Merge {
+		return errors.New("merge cannot be set with replicate")
+	}
 	return nil
 }
 
@@ -97,30 +156,117 @@ func (vc *VaultClient) GetPath() string {
 	return vc.Path
 }
 
+// IsRetryable returns true if the error is transient. Vault responses with
+// a client error status, such as a missing mount or a denied policy, are not
+// retryable; a rejected token is already retried with a new login.
+func (vc *VaultClient) IsRetryable(err error) bool {
+	var re *api.ResponseError
+	if errors.As(err, &re) {
+		return re.StatusCode == http.StatusPreconditionFailed || driver.RetryableStatus(re.StatusCode)
+	}
+	return true
+}
+
 // NewClients creates and returns a new vault client with a valid token or error
 func (vc *VaultClient) NewClient(ctx context.Context) (*api.Client, error) {
 	log.Tracef("vault.NewClient")
-	config := &api.Config{
-		Address: vc.Address,
+	if err := vc.newAPIClient(); err != nil {
+		return vc.Client, err
 	}
-	var err error
-	vc.Client, err = api.NewClient(config)
-	if err != nil {
+	if err := vc.NewToken(ctx); err != nil {
 		return vc.Client, err
 	}
+	return vc.Client, nil
+}
+
+// newAPIClient creates the underlying vault api client without a token
+func (vc *VaultClient) newAPIClient() error {
+	config, err := vc.apiConfig()
+	if err != nil {
+		return err
+	}
+	c, err := api.NewClient(config)
+	if err != nil {
+		return err
+	}
 	if vc.Namespace != "" {
-		vc.Client.SetNamespace(vc.Namespace)
+		c.SetNamespace(vc.Namespace)
 	}
-	terr := vc.NewToken(ctx)
-	if terr != nil {
-		return vc.Client, terr
+	c.AddHeader("x-vault-sync", "true")
+	vc.Client = c
+	return nil
+}
+
+// hasTLSConfig returns true if any TLS option has been configured
+func (vc *VaultClient) hasTLSConfig() bool {
+	return vc.CACert != "" || vc.CAPath != "" || vc.ClientCert != "" ||
+		vc.ClientKey != "" || vc.TLSServerName != "" || vc.TLSSkipVerify
+}
+
+// apiConfig builds the vault api config, including TLS settings if configured
+func (vc *VaultClient) apiConfig() (*api.Config, error) {
+	if !vc.hasTLSConfig() {
+		return &api.Config{
+			Address: vc.Address,
+		}, nil
+	}
+	config := api.DefaultConfig()
+	if config.Error != nil {
+		return nil, config.Error
+	}
+	config.Address = vc.Address
+	if err := config.ConfigureTLS(&api.TLSConfig{
+		CACert:        vc.CACert,
+		CAPath:        vc.CAPath,
+		ClientCert:    vc.ClientCert,
+		ClientKey:     vc.ClientKey,
+		TLSServerName: vc.TLSServerName,
+		Insecure:      vc.TLSSkipVerify,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to configure vault tls: %w", err)
+	}
+	return config, nil
+}
+
+// certLogin creates a vault token with the cert auth provider. The client
+// certificate is presented as part of the TLS handshake.
+func (vc *VaultClient) certLogin(ctx context.Context) (*api.Secret, error) {
+	mount := vc.AuthMethod
+	if mount == "" {
+		mount = AuthTypeCert
+	}
+	options := map[string]interface{}{}
+	if vc.Role != "" {
+		options["name"] = vc.Role
+	}
+	if vc.TTL != "" {
+		options["ttl"] = vc.TTL
+	}
+	path := fmt.Sprintf("auth/%s/login", mount)
+	log.WithFields(log.Fields{
+		"path": path,
+		"role": vc.Role,
+	}).Trace("vault.certLogin calling Write")
+	secret, err := vc.Client.Logical().WriteWithContext(ctx, path, options)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Auth == nil {
+		return nil, errors.New("cert login returned no auth data")
 	}
-	vc.Client.AddHeader("x-vault-sync", "true")
-	return vc.Client, err
+	vc.Client.SetToken(secret.Auth.ClientToken)
+	return secret, nil
 }
 
-// Login creates a vault token with the k8s auth provider
+// Login creates a vault token with the configured auth provider
 func (vc *VaultClient) Login(ctx context.Context) error {
+	_, err := vc.login(ctx)
+	return err
+}
+
+// login authenticates with the configured auth provider and sets the token on
+// the client, returning the login response if a login was performed
+func (vc *VaultClient) login(ctx context.Context) (*api.Secret, error) {
 	l := log.WithFields(log.Fields{
 		"address":   vc.Address,
 		"role":      vc.Role,
@@ -130,13 +276,15 @@ func (vc *VaultClient) Login(ctx context.Context) error {
 	})
 	l.Trace("vault.Login")
 	if vc.Client == nil {
-		_, err := vc.NewClient(ctx)
-		if err != nil {
-			return err
+		if err := vc.newAPIClient(); err != nil {
+			return nil, err
 		}
 	}
+	if vc.AuthType == AuthTypeCert {
+		return vc.certLogin(ctx)
+	}
 	var kubeTokenExists bool
-	ktp := "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	ktp := kubeTokenPath
 	if _, err := os.Stat(ktp); !os.IsNotExist(err) {
 		l.Tracef("kubeToken exists at path=%s", ktp)
 		kubeTokenExists = true
@@ -146,7 +294,7 @@ func (vc *VaultClient) Login(ctx context.Context) error {
 		l.Tracef("reading kubeToken from path=%s", ktp)
 		fd, err := os.ReadFile(ktp)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		kt = string(fd)
 	}
@@ -165,13 +313,16 @@ func (vc *VaultClient) Login(ctx context.Context) error {
 		}).Trace("vault.Login calling Write")
 		secret, err := vc.Client.Logical().WriteWithContext(ctx, path, options)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if secret == nil || secret.Auth == nil {
+			return nil, errors.New("kubernetes login returned no auth data")
 		}
 		vc.Client.SetToken(secret.Auth.ClientToken)
-	} else {
-		vc.Client.SetToken(os.Getenv("VAULT_TOKEN"))
+		return secret, nil
 	}
-	return nil
+	vc.Client.SetToken(os.Getenv("VAULT_TOKEN"))
+	return nil, nil
 }
 
 func tokenEnvTemplate(t string) string {
@@ -211,23 +362,37 @@ func (vc *VaultClient) NewToken(ctx context.Context) error {
 		"path":    vc.Path,
 		"method":  vc.AuthMethod,
 	})
-	l.Trace("vault.NewToken calling Login")
-	if os.Getenv("VAULT_TOKEN") != "" {
-		l.Trace("using VAULT_TOKEN")
-		if vc.Client == nil {
-			_, err := vc.NewClient(ctx)
-			if err != nil {
-				return err
-			}
+	l.Trace("vault.NewToken")
+	if vc.Client == nil {
+		if err := vc.newAPIClient(); err != nil {
+			return err
 		}
-		vc.Client.SetToken(os.Getenv("VAULT_TOKEN"))
 	}
-	if err := vc.Login(ctx); err != nil {
+	token, err := tokens.token(ctx, vc)
+	if err != nil {
 		return err
 	}
+	vc.Client.SetToken(token)
 	return nil
 }
 
+// withToken runs fn with a valid token, logging in again and retrying
+// once if vault rejects the cached token
+func (vc *VaultClient) withToken(ctx context.Context, fn func() error) error {
+	if err := vc.NewToken(ctx); err != nil {
+		return err
+	}
+	err := fn()
+	if !isPermissionDenied(err) {
+		return err
+	}
+	tokens.invalidate(vc, vc.Client.Token())
+	if err := vc.NewToken(ctx); err != nil {
+		return err
+	}
+	return fn()
+}
+
 func insertSliceString(a []string, index int, value string) []string {
 	if len(a) == index { // nil or empty slice or after last element
 		return append(a, value)
@@ -239,60 +404,90 @@ func insertSliceString(a []string, index int, value string) []string {
 
 // GetKVSecret retrieves a kv secret from vault
 func (vc *VaultClient) GetKVSecretOnce(ctx context.Context, s string) (map[string]interface{}, error) {
+	secret, err := vc.readKVOnce(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data["data"].(map[string]interface{}), nil
+}
+
+// readKVOnce reads the data and metadata of a kv secret from vault
+func (vc *VaultClient) readKVOnce(ctx context.Context, s string) (*api.Secret, error) {
 	l := log.WithFields(log.Fields{
 		"address": vc.Address,
 		"role":    vc.Role,
 		"path":    s,
 		"method":  vc.AuthMethod,
 	})
-	var secrets map[string]interface{}
 	if s == "" {
-		return secrets, errors.New("secret path required")
+		return nil, errors.New("secret path required")
 	}
 	ss := strings.Split(s, "/")
 	if len(ss) < 2 {
-		return secrets, errors.New("secret path must be in kv/path/to/secret format")
+		return nil, errors.New("secret path must be in kv/path/to/secret format")
 	}
 	ss = insertSliceString(ss, 1, "data")
 	//log.Debugf("headers_sent=%+v", vc.Client.Headers())
 	c := vc.Client.Logical()
 	s = strings.Join(ss, "/")
 	if c == nil {
-		return secrets, errors.New("vault client not initialized")
+		return nil, errors.New("vault client not initialized")
+	}
+	var secret *api.Secret
+	var err error
+	if vc.Version > 0 {
+		l = l.WithField("version", vc.Version)
+		secret, err = c.ReadWithDataWithContext(ctx, s, map[string][]string{"version": {strconv.Itoa(vc.Version)}})
+	} else {
+		secret, err = c.ReadWithContext(ctx, s)
 	}
-	secret, err := c.ReadWithContext(ctx, s)
 	if err != nil {
-		return secrets, err
+		return nil, err
 	}
 	if secret == nil || secret.Data == nil {
+		if vc.Version > 0 {
+			return nil, fmt.Errorf("secret version %d not found: %s", vc.Version, s)
+		}
 		return nil, errors.New("secret not found: " + s)
 	}
 	l.Tracef("secret=%+v", secret)
 	if secret.Data["data"] == nil {
+		// deleted and destroyed versions have metadata but no data
+		if vc.Version > 0 {
+			return nil, fmt.Errorf("secret version %d has no data, it may be deleted or destroyed: %s", vc.Version, s)
+		}
 		return nil, errors.New("secret data not found: " + s)
 	}
-	return secret.Data["data"].(map[string]interface{}), nil
+	return secret, nil
 }
 
-// GetKVSecret will login and retry secret access on failure
-// to gracefully handle token expiration
-func (vc *VaultClient) GetSecret(ctx context.Context, s string) ([]byte, error) {
-	var sec map[string]interface{}
-	var err error
-	terr := vc.NewToken(ctx)
-	if terr != nil {
-		return nil, terr
+// customMetadata returns the string values of the custom metadata of a kv
+// secret read from its data or metadata path
+func customMetadata(cm interface{}) map[string]string {
+	m, ok := cm.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return nil
 	}
-	sec, err = vc.GetKVSecretOnce(ctx, s)
-	if err != nil {
-		terr := vc.NewToken(ctx)
-		if terr != nil {
-			return nil, terr
+	md := make(map[string]string, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			md[k] = s
 		}
+	}
+	return md
+}
+
+// GetSecret retrieves a kv secret, logging in again and retrying
+// on permission denied to gracefully handle token revocation
+func (vc *VaultClient) GetSecret(ctx context.Context, s string) ([]byte, error) {
+	var sec map[string]interface{}
+	err := vc.withToken(ctx, func() error {
+		var err error
 		sec, err = vc.GetKVSecretOnce(ctx, s)
-		if err != nil {
-			return nil, err
-		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 	b, err := json.Marshal(sec)
 	if err != nil {
@@ -301,6 +496,29 @@ func (vc *VaultClient) GetSecret(ctx context.Context, s string) ([]byte, error)
 	return b, err
 }
 
+// GetSecretWithMetadata retrieves a kv secret and its custom metadata,
+// which is read along with the data
+func (vc *VaultClient) GetSecretWithMetadata(ctx context.Context, s string) ([]byte, map[string]string, error) {
+	var secret *api.Secret
+	err := vc.withToken(ctx, func() error {
+		var err error
+		secret, err = vc.readKVOnce(ctx, s)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := json.Marshal(secret.Data["data"])
+	if err != nil {
+		return nil, nil, err
+	}
+	var md map[string]string
+	if m, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		md = customMetadata(m["custom_metadata"])
+	}
+	return b, md, nil
+}
+
 // WriteSecret will login and retry secret write on failure
 // to gracefully handle token expiration and CAS conflicts
 func (vc *VaultClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, s string, bData []byte) ([]byte, error) {
@@ -336,12 +554,24 @@ func (vc *VaultClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 				"attempt": attempt,
 				"backoff": backoff,
 			}).Debug("retrying write after CAS conflict")
-			time.Sleep(backoff)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
 		}
 
 		// Get current version and data
 		currentVersion, currentData, err := vc.getSecretWithVersion(ctx, s)
 		if err != nil && !strings.Contains(err.Error(), "secret not found") {
+			if attempt == 0 && isPermissionDenied(err) {
+				tokens.invalidate(vc, vc.Client.Token())
+				if terr := vc.NewToken(ctx); terr != nil {
+					return nil, terr
+				}
+				attempt++
+				continue
+			}
 			// Real error reading secret - fail immediately
 			return nil, fmt.Errorf("failed to read secret: %w", err)
 		}
@@ -372,7 +602,8 @@ func (vc *VaultClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 				continue
 			}
 
-			if attempt == 0 {
+			if attempt == 0 && isPermissionDenied(err) {
+				tokens.invalidate(vc, vc.Client.Token())
 				terr := vc.NewToken(ctx)
 				if terr != nil {
 					return nil, terr
@@ -388,10 +619,108 @@ func (vc *VaultClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 			"attempt": attempt,
 			"cas":     currentVersion,
 		}).Debug("successfully wrote secret")
+		// merged secrets are shared by the syncs merging into them and have no single owner
+		owner := driver.Owner(meta)
+		if vc.Merge {
+			owner = ""
+		}
+		if md := driver.MetadataFrom(ctx); owner != "" || !md.Empty() {
+			if err := vc.setMetadata(ctx, s, owner, md); err != nil {
+				return nil, fmt.Errorf("failed to set secret metadata: %w", err)
+			}
+		}
 		return nil, nil
 	}
 }
 
+// readMetadata reads the kv metadata of the secret, returning nil if it does not exist
+func (vc *VaultClient) readMetadata(ctx context.Context, s string) (*api.Secret, string, error) {
+	ss := strings.Split(s, "/")
+	if len(ss) < 2 {
+		return nil, "", errors.New("secret path must be in kv/path/to/secret format")
+	}
+	p := strings.Join(insertSliceString(ss, 1, "metadata"), "/")
+	md, err := vc.Client.Logical().ReadWithContext(ctx, p)
+	return md, p, err
+}
+
+// metadataOwner returns the owner in the custom metadata of the secret
+func metadataOwner(md *api.Secret) (map[string]interface{}, string) {
+	cm := make(map[string]interface{})
+	if md == nil || md.Data == nil {
+		return cm, ""
+	}
+	if m, ok := md.Data["custom_metadata"].(map[string]interface{}); ok {
+		cm = m
+	}
+	o, _ := cm[driver.OwnerKey].(string)
+	return cm, o
+}
+
+// metadataKey maps a metadata key to the limits of vault custom metadata
+func metadataKey(k string) string {
+	return driver.MapChars(k, func(rune) bool { return true }, '_', maxMetadataKey)
+}
+
+// setMetadata sets the owner, if any, and the propagated metadata in the
+// custom metadata of the secret, keeping any other custom metadata
+func (vc *VaultClient) setMetadata(ctx context.Context, s, owner string, md driver.Metadata) error {
+	smd, p, err := vc.readMetadata(ctx, s)
+	if err != nil {
+		return err
+	}
+	cm, _ := metadataOwner(smd)
+	changed := false
+	set := func(k string, v interface{}) {
+		if cur, ok := cm[k]; !ok || cur != v {
+			cm[k] = v
+			changed = true
+		}
+	}
+	for _, k := range md.Remove {
+		if _, ok := cm[metadataKey(k)]; ok {
+			delete(cm, metadataKey(k))
+			changed = true
+		}
+	}
+	for k, v := range md.Set {
+		set(metadataKey(k), driver.MapChars(v, func(rune) bool { return true }, '_', maxMetadataValue))
+	}
+	if owner != "" {
+		set(driver.OwnerKey, owner)
+	}
+	if !changed {
+		return nil
+	}
+	_, err = vc.Client.Logical().WriteWithContext(ctx, p, map[string]interface{}{
+		"custom_metadata": cm,
+	})
+	return err
+}
+
+// Owners returns the owner in the custom metadata of the secret if it
+// exists. Merged secrets are shared and are never owned.
+func (vc *VaultClient) Owners(ctx context.Context, owner, path string, payload []byte) (map[string]string, error) {
+	owners := make(map[string]string)
+	if vc.Merge {
+		return owners, nil
+	}
+	var md *api.Secret
+	err := vc.withToken(ctx, func() error {
+		var err error
+		md, _, err = vc.readMetadata(ctx, path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return owners, nil
+	}
+	_, owners[path] = metadataOwner(md)
+	return owners, nil
+}
+
 // WriteSecret writes a secret to Vault VaultClient at path p with secret value s
 func (vc *VaultClient) WriteSecretOnce(ctx context.Context, p string, s map[string]interface{}, cas *int) (map[string]interface{}, error) {
 	var secrets map[string]interface{}
@@ -509,11 +838,10 @@ func (vc *VaultClient) DeleteSecret(ctx context.Context, p string) error {
 		pp = insertSliceString(pp, 1, "metadata")
 		p = strings.Join(pp, "/")
 	}
-	terr := vc.NewToken(ctx)
-	if terr != nil {
-		return terr
-	}
-	_, err := vc.Client.Logical().DeleteWithContext(ctx, p)
+	err := vc.withToken(ctx, func() error {
+		_, err := vc.Client.Logical().DeleteWithContext(ctx, p)
+		return err
+	})
 	if err != nil {
 		l.WithFields(log.Fields{
 			"error": err,
@@ -570,22 +898,11 @@ func (vc *VaultClient) ListSecretsOnce(ctx context.Context, p string) ([]string,
 
 func (vc *VaultClient) ListSecrets(ctx context.Context, p string) ([]string, error) {
 	var keys []string
-	var err error
-	terr := vc.NewToken(ctx)
-	if terr != nil {
-		return keys, terr
-	}
-	keys, err = vc.ListSecretsOnce(ctx, p)
-	if err != nil {
-		terr := vc.NewToken(ctx)
-		if terr != nil {
-			return keys, terr
-		}
+	err := vc.withToken(ctx, func() error {
+		var err error
 		keys, err = vc.ListSecretsOnce(ctx, p)
-		if err != nil {
-			return keys, err
-		}
-	}
+		return err
+	})
 	return keys, err
 }
 
@@ -622,5 +939,26 @@ func (c *VaultClient) SetDefaults(defaults any) error {
 	if c.TTL == "" && dc.TTL != "" {
 		c.TTL = dc.TTL
 	}
+	if c.AuthType == "" && dc.AuthType != "" {
+		c.AuthType = dc.AuthType
+	}
+	if c.CACert == "" && dc.CACert != "" {
+		c.CACert = dc.CACert
+	}
+	if c.CAPath == "" && dc.CAPath != "" {
+		c.CAPath = dc.CAPath
+	}
+	if c.ClientCert == "" && dc.ClientCert != "" {
+		c.ClientCert = dc.ClientCert
+	}
+	if c.ClientKey == "" && dc.ClientKey != "" {
+		c.ClientKey = dc.ClientKey
+	}
+	if c.TLSServerName == "" && dc.TLSServerName != "" {
+		c.TLSServerName = dc.TLSServerName
+	}
+	if !c.TLSSkipVerify && dc.TLSSkipVerify {
+		c.TLSSkipVerify = dc.TLSSkipVerify
+	}
 	return nil
 }
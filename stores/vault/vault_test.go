@@ -2,13 +2,26 @@ package vault
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/robertlestak/vault-secret-sync/pkg/driver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -445,3 +458,163 @@ func TestListSecretsOnceAllowsMountRoot(t *testing.T) {
 	assert.Equal(t, "list=true", requestedQuery)
 	assert.Equal(t, []string{"GLOBAL", "stores/"}, keys)
 }
+
+func TestGetKVSecretOncePinnedVersion(t *testing.T) {
+	var requestedPath string
+	var requestedQuery string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		requestedQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("version") == "2" {
+			_, _ = w.Write([]byte(`{"data":{"data":null,"metadata":{"version":2,"destroyed":true}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"password":"old"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	vc := &VaultClient{Client: client, Version: 3}
+	data, err := vc.GetKVSecretOnce(context.Background(), "kv/app")
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/kv/data/app", requestedPath)
+	assert.Equal(t, "version=3", requestedQuery)
+	assert.Equal(t, "old", data["password"])
+
+	vc.Version = 2
+	_, err = vc.GetKVSecretOnce(context.Background(), "kv/app")
+	assert.ErrorContains(t, err, "version 2 has no data")
+}
+
+func TestSetMetadata(t *testing.T) {
+	var written map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"data":{"custom_metadata":{"team":"old","rotation-date":"2024-01-01","keep":"y"}}}`))
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&written))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	vc := &VaultClient{Client: client}
+	md := driver.Metadata{Set: map[string]string{"team": "payments"}, Remove: []string{"rotation-date"}}
+	require.NoError(t, vc.setMetadata(context.Background(), "kv/app", "ns/app", md))
+	assert.Equal(t, map[string]interface{}{
+		"team":          "payments",
+		"keep":          "y",
+		driver.OwnerKey: "ns/app",
+	}, written["custom_metadata"])
+
+	// the secret read with its data holds the custom metadata
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"data":{"a":"b"},"metadata":{"version":1,"custom_metadata":{"team":"payments"}}}}`))
+	})
+	secret, err := vc.readKVOnce(context.Background(), "kv/app")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "payments"}, customMetadata(secret.Data["metadata"].(map[string]interface{})["custom_metadata"]))
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return p
+}
+
+func TestCertLoginOverMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "vault-secret-sync"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	clientCert, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	var loginPath, loginRole, readToken string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/auth/cert/login":
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			loginPath = r.URL.Path
+			loginRole, _ = body["name"].(string)
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"cert-token","lease_duration":60,"renewable":true}}`))
+		case "/v1/kv/data/app":
+			readToken = r.Header.Get("X-Vault-Token")
+			_, _ = w.Write([]byte(`{"data":{"data":{"foo":"bar"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.StartTLS()
+	defer server.Close()
+
+	vc := &VaultClient{
+		Address:       server.URL,
+		AuthType:      AuthTypeCert,
+		Role:          "sync",
+		CACert:        writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw),
+		ClientCert:    writePEM(t, dir, "client.pem", "CERTIFICATE", certDER),
+		ClientKey:     writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER),
+		TLSServerName: "example.com",
+	}
+	require.NoError(t, vc.Validate())
+	t.Setenv("VAULT_TOKEN", "")
+
+	b, err := vc.GetSecret(context.Background(), "kv/app")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"foo":"bar"}`, string(b))
+	assert.Equal(t, "/v1/auth/cert/login", loginPath)
+	assert.Equal(t, "sync", loginRole)
+	assert.Equal(t, "cert-token", readToken)
+}
+
+func TestValidateCertAuthRequiresClientCert(t *testing.T) {
+	vc := &VaultClient{Address: "https://vault.example.com", AuthType: AuthTypeCert}
+	assert.Error(t, vc.Validate())
+	vc.ClientCert = "/tmp/client.pem"
+	assert.Error(t, vc.Validate())
+	vc.ClientKey = "/tmp/client-key.pem"
+	assert.NoError(t, vc.Validate())
+}
+
+func TestSetDefaultsTLS(t *testing.T) {
+	vc := &VaultClient{CACert: "/etc/ssl/custom.pem"}
+	require.NoError(t, vc.SetDefaults(&VaultClient{
+		CACert:        "/etc/ssl/default.pem",
+		AuthType:      AuthTypeCert,
+		TLSServerName: "vault.internal",
+	}))
+	assert.Equal(t, "/etc/ssl/custom.pem", vc.CACert)
+	assert.Equal(t, AuthTypeCert, vc.AuthType)
+	assert.Equal(t, "vault.internal", vc.TLSServerName)
+}